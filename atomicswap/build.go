@@ -0,0 +1,447 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package atomicswap
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+)
+
+// Wallet is the wallet the transactions of a swap are funded and signed
+// with.
+type Wallet interface {
+	// NewAddress returns a new P2PKH or P2WPKH address of the wallet to
+	// receive refunds and redemptions.
+	NewAddress() (btcutil.Address, error)
+
+	// PayTo returns a funded and signed transaction paying the amounts,
+	// and its fee.  The transaction must not be published.
+	PayTo(amounts map[btcutil.Address]btcutil.Amount, feePerKb btcutil.Amount) (tx *wire.MsgTx, fee btcutil.Amount, err error)
+
+	// CreateSig returns the raw signature of input idx of tx, which spends
+	// an output with pkScript, by the key of addr, and the serialized
+	// compressed pubkey of that key.
+	CreateSig(tx *wire.MsgTx, idx int, pkScript []byte, addr btcutil.Address) (sig, pubkey []byte, err error)
+}
+
+// WitnessWallet is a Wallet able to sign witness inputs, which is required to
+// spend P2WSH contract outputs.
+type WitnessWallet interface {
+	Wallet
+
+	// CreateWitnessSig returns the BIP143 SIGHASH_ALL signature of input
+	// idx of tx, which spends amount from a P2WSH output with the witness
+	// script, by the key of addr, and the serialized compressed pubkey of
+	// that key.
+	CreateWitnessSig(tx *wire.MsgTx, idx int, script []byte, amount int64, addr btcutil.Address) (sig, pubkey []byte, err error)
+}
+
+// Builder builds the transactions of atomic swaps on the chain described by
+// Params.
+type Builder struct {
+	Params *chaincfg.Params
+
+	// RelayFeePerKb is the relay fee rate outputs are checked against to
+	// be relayed.  The fee rate of the transaction is used when it is zero.
+	RelayFeePerKb btcutil.Amount
+
+	// CheckFee, when not nil, is called with the fee of every transaction
+	// and the value it spends or pays to the contract before the
+	// transaction is returned, and the error it returns aborts building.
+	// The description is "contract", "redeem" or "refund".  Amounts are in
+	// satoshi.
+	CheckFee func(description string, fee, value int64) error
+
+	// ForkID selects SIGHASH_FORKID signatures and their verification, as
+	// required by Bitcoin Cash.  The wallet must then be a ForkIDWallet.
+	ForkID bool
+
+	// Hashes, when not nil, are the hashing primitives of chains that do
+	// not hash transactions and signatures with double SHA256.  The
+	// wallet must then sign with Hashes.SigHash.
+	Hashes *Hashes
+
+	// SegWit pays new contracts to P2WSH rather than P2SH outputs.
+	// Contract outputs of both kinds are spent regardless, P2WSH outputs
+	// with witnesses signed by a WitnessWallet.
+	SegWit bool
+}
+
+// ContractArgs specifies the common parameters used to create the initiator's
+// and participant's contract.
+type ContractArgs struct {
+	Them       *btcutil.AddressPubKeyHash
+	Amount     btcutil.Amount
+	LockTime   int64
+	SecretHash []byte
+}
+
+// BuiltContract houses the details regarding a contract and the contract
+// payment transaction, as well as the transaction to perform a refund.
+type BuiltContract struct {
+	Contract *Contract
+
+	// ContractAddress is the P2SH or P2WSH address paid by the contract
+	// transaction.  ContractP2SH is only set for P2SH contracts.
+	ContractAddress btcutil.Address
+	ContractP2SH    *btcutil.AddressScriptHash
+
+	ContractTxHash chainhash.Hash
+	ContractTx     *wire.MsgTx
+	ContractFee    btcutil.Amount
+	RefundTx       *wire.MsgTx
+	RefundFee      btcutil.Amount
+}
+
+// Spend is a contract output spent by a redeem or refund transaction.
+type Spend struct {
+	Contract   *Contract
+	ContractTx *wire.MsgTx
+
+	// Secret is the secret revealed to redeem the contract.  It is unused
+	// by refunds.
+	Secret []byte
+}
+
+// newAddress returns a new address of the wallet for the chain of the
+// builder.
+func (b *Builder) newAddress(w Wallet) (btcutil.Address, error) {
+	addr, err := w.NewAddress()
+	if err != nil {
+		return nil, fmt.Errorf("new address: %w", err)
+	}
+	if !addr.IsForNet(b.Params) {
+		return nil, fmt.Errorf("address %v is not intended for use on %v",
+			addr, b.Params.Name)
+	}
+	return addr, nil
+}
+
+// txHash returns the hash identifying tx on the chain of the builder.
+func (b *Builder) txHash(tx *wire.MsgTx) chainhash.Hash {
+	if b.Hashes != nil {
+		return b.Hashes.TxHash(tx)
+	}
+	return tx.TxHash()
+}
+
+// checkFee calls the CheckFee hook when it is set.
+func (b *Builder) checkFee(description string, fee btcutil.Amount, value int64) error {
+	if b.CheckFee == nil {
+		return nil
+	}
+	return b.CheckFee(description, int64(fee), value)
+}
+
+// BuildContract creates a contract for the parameters specified in args, using
+// the wallet to generate an address to redeem the refund and to fund and sign
+// the payment to the contract.  Neither transaction is published.  When an
+// error is returned after the wallet funded the contract transaction, the
+// wallet outputs it spends are not spent.
+func (b *Builder) BuildContract(w Wallet, args *ContractArgs, feePerKb btcutil.Amount) (*BuiltContract, error) {
+	refundAddr, err := b.newAddress(w)
+	if err != nil {
+		return nil, err
+	}
+	refundAddrP2PKH, err := KeyHashAddress(refundAddr, b.Params)
+	if err != nil {
+		return nil, fmt.Errorf("refund address: %v", err)
+	}
+
+	contract, err := NewContract(refundAddrP2PKH.Hash160(), args.Them.Hash160(),
+		args.LockTime, args.SecretHash)
+	if err != nil {
+		return nil, err
+	}
+	var contractAddr btcutil.Address
+	var contractP2SH *btcutil.AddressScriptHash
+	if b.SegWit {
+		contractAddr, err = contract.WitnessAddress(b.Params)
+	} else {
+		contractP2SH, err = contract.Address(b.Params)
+		contractAddr = contractP2SH
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contractTx, contractFee, err := w.PayTo(map[btcutil.Address]btcutil.Amount{
+		contractAddr: args.Amount,
+	}, feePerKb)
+	if err != nil {
+		return nil, fmt.Errorf("payTo: %w", err)
+	}
+	err = b.checkFee("contract", contractFee, int64(args.Amount))
+	if err != nil {
+		return nil, err
+	}
+
+	refundTx, refundFee, err := b.BuildRefund(w, contract, contractTx, feePerKb)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BuiltContract{
+		Contract:        contract,
+		ContractAddress: contractAddr,
+		ContractP2SH:    contractP2SH,
+		ContractTxHash:  b.txHash(contractTx),
+		ContractTx:      contractTx,
+		ContractFee:     contractFee,
+		RefundTx:        refundTx,
+		RefundFee:       refundFee,
+	}, nil
+}
+
+// BuildRedeem creates a transaction redeeming the contract output of
+// contractTx with secret to a new wallet address.
+func (b *Builder) BuildRedeem(w Wallet, contract *Contract, contractTx *wire.MsgTx, secret []byte,
+	feePerKb btcutil.Amount) (*wire.MsgTx, btcutil.Amount, error) {
+
+	spends := []*Spend{{Contract: contract, ContractTx: contractTx, Secret: secret}}
+	return b.buildSpend(w, spends, true, feePerKb)
+}
+
+// BuildRefund creates a transaction refunding the contract output of
+// contractTx to a new wallet address.  It can only be published once the
+// locktime of the contract is reached.
+func (b *Builder) BuildRefund(w Wallet, contract *Contract, contractTx *wire.MsgTx,
+	feePerKb btcutil.Amount) (*wire.MsgTx, btcutil.Amount, error) {
+
+	spends := []*Spend{{Contract: contract, ContractTx: contractTx}}
+	return b.buildSpend(w, spends, false, feePerKb)
+}
+
+// BuildBatchRedeem creates a transaction redeeming the contract outputs of all
+// spends to a single new wallet address.
+func (b *Builder) BuildBatchRedeem(w Wallet, spends []*Spend, feePerKb btcutil.Amount) (
+	*wire.MsgTx, btcutil.Amount, error) {
+
+	return b.buildSpend(w, spends, true, feePerKb)
+}
+
+// BuildBatchRefund creates a transaction refunding the contract outputs of
+// all spends to a single new wallet address.  It can only be published once
+// the latest locktime of the contracts is reached.
+func (b *Builder) BuildBatchRefund(w Wallet, spends []*Spend, feePerKb btcutil.Amount) (
+	*wire.MsgTx, btcutil.Amount, error) {
+
+	return b.buildSpend(w, spends, false, feePerKb)
+}
+
+// buildSpend creates a transaction sweeping the contract outputs of all
+// spends into a single wallet output.  The redeem path is used when redeem is
+// true, the refund path otherwise.
+func (b *Builder) buildSpend(w Wallet, spends []*Spend, redeem bool, feePerKb btcutil.Amount) (
+	tx *wire.MsgTx, fee btcutil.Amount, err error) {
+
+	description := "refund"
+	if redeem {
+		description = "redeem"
+	}
+
+	addr, err := b.newAddress(w)
+	if err != nil {
+		return nil, 0, err
+	}
+	outScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tx = wire.NewMsgTx(TxVersion)
+	contracts := make([][]byte, len(spends))
+	prevOuts := make([]*wire.TxOut, len(spends))
+	signers := make([]btcutil.Address, len(spends))
+	spent := make(map[wire.OutPoint]struct{}, len(spends))
+	var total int64
+	var witness bool
+	for i, spend := range spends {
+		contract := spend.Contract
+		contractOut, err := contract.Output(spend.ContractTx)
+		if err != nil {
+			return nil, 0, fmt.Errorf("contract %d: %w", i+1, err)
+		}
+		// The size estimates assume all inputs are of the same kind.
+		if i == 0 {
+			witness = IsWitnessOutput(spend.ContractTx, contractOut)
+		} else if IsWitnessOutput(spend.ContractTx, contractOut) != witness {
+			return nil, 0, errors.New("contracts mix P2SH and P2WSH outputs")
+		}
+		outPoint := wire.OutPoint{Hash: b.txHash(spend.ContractTx), Index: uint32(contractOut)}
+		if _, ok := spent[outPoint]; ok {
+			return nil, 0, fmt.Errorf("contract %d spends %v more than once", i+1, outPoint)
+		}
+		spent[outPoint] = struct{}{}
+
+		txIn := wire.NewTxIn(&outPoint, nil, nil)
+		signerHash := contract.RecipientHash160[:]
+		if !redeem {
+			// All refunded contracts must use the same kind of locktime
+			// since the transaction can only have one.
+			if i != 0 && contract.LockTimeIsTime() != spends[0].Contract.LockTimeIsTime() {
+				return nil, 0, errors.New("contracts mix block height " +
+					"and time based locktimes")
+			}
+			if uint32(contract.LockTime) > tx.LockTime {
+				tx.LockTime = uint32(contract.LockTime)
+			}
+			txIn.Sequence = 0
+			signerHash = contract.RefundHash160[:]
+		}
+		tx.AddTxIn(txIn)
+
+		signers[i], err = btcutil.NewAddressPubKeyHash(signerHash, b.Params)
+		if err != nil {
+			return nil, 0, err
+		}
+		contracts[i] = contract.Script
+		prevOuts[i] = spend.ContractTx.TxOut[contractOut]
+		total += prevOuts[i].Value
+	}
+
+	tx.AddTxOut(wire.NewTxOut(0, outScript)) // amount set below
+	var size int
+	switch {
+	case witness && redeem:
+		size = EstimateBatchRedeemWitnessVSize(contracts, tx.TxOut)
+	case witness:
+		size = EstimateBatchRefundWitnessVSize(contracts, tx.TxOut)
+	case redeem:
+		size = EstimateBatchRedeemSerializeSize(contracts, tx.TxOut)
+	default:
+		size = EstimateBatchRefundSerializeSize(contracts, tx.TxOut)
+	}
+	fee = txrules.FeeForSerializeSize(feePerKb, size)
+	tx.TxOut[0].Value = total - int64(fee)
+	err = b.checkFee(description, fee, total)
+	if err != nil {
+		return nil, 0, err
+	}
+	relayFeePerKb := b.RelayFeePerKb
+	if relayFeePerKb == 0 {
+		relayFeePerKb = feePerKb
+	}
+	if txrules.IsDustOutput(tx.TxOut[0], relayFeePerKb) {
+		return nil, 0, fmt.Errorf("%s output value of %v is %w", description,
+			btcutil.Amount(tx.TxOut[0].Value), ErrDust)
+	}
+
+	var forkIDWallet ForkIDWallet
+	var witnessWallet WitnessWallet
+	switch {
+	case witness && (b.ForkID || b.Hashes != nil):
+		return nil, 0, errors.New("P2WSH contract outputs are not supported on this chain")
+	case witness:
+		var ok bool
+		witnessWallet, ok = w.(WitnessWallet)
+		if !ok {
+			return nil, 0, errors.New("wallet can not create witness signatures")
+		}
+	case b.ForkID:
+		var ok bool
+		forkIDWallet, ok = w.(ForkIDWallet)
+		if !ok {
+			return nil, 0, errors.New("wallet can not create SIGHASH_FORKID signatures")
+		}
+	}
+	for i, spend := range spends {
+		var sig, pubKey []byte
+		switch {
+		case witness:
+			sig, pubKey, err = witnessWallet.CreateWitnessSig(tx, i, spend.Contract.Script,
+				prevOuts[i].Value, signers[i])
+		case b.ForkID:
+			sig, pubKey, err = forkIDWallet.CreateForkIDSig(tx, i, spend.Contract.Script,
+				prevOuts[i].Value, signers[i])
+		default:
+			sig, pubKey, err = w.CreateSig(tx, i, spend.Contract.Script, signers[i])
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if witness {
+			if redeem {
+				tx.TxIn[i].Witness = RedeemP2WSHContract(spend.Contract.Script, sig, pubKey, spend.Secret)
+			} else {
+				tx.TxIn[i].Witness = RefundP2WSHContract(spend.Contract.Script, sig, pubKey)
+			}
+			continue
+		}
+		var sigScript []byte
+		if redeem {
+			sigScript, err = RedeemP2SHContract(spend.Contract.Script, sig, pubKey, spend.Secret)
+		} else {
+			sigScript, err = RefundP2SHContract(spend.Contract.Script, sig, pubKey)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		tx.TxIn[i].SignatureScript = sigScript
+	}
+
+	switch {
+	case b.ForkID:
+		err = verifyForkIDInputs(tx, prevOuts)
+	case b.Hashes != nil:
+		err = b.Hashes.verifyInputs(tx, prevOuts)
+	default:
+		err = verifyInputs(tx, prevOuts)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s transaction: %v", description, err)
+	}
+	return tx, fee, nil
+}
+
+// verifyInputs executes the scripts of all inputs of tx, which spend
+// prevOuts.
+func verifyInputs(tx *wire.MsgTx, prevOuts []*wire.TxOut) error {
+	sigHashes := txscript.NewTxSigHashes(tx)
+	for i, prevOut := range prevOuts {
+		e, err := txscript.NewEngine(prevOut.PkScript, tx, i,
+			txscript.StandardVerifyFlags, txscript.NewSigCache(10),
+			sigHashes, prevOut.Value)
+		if err != nil {
+			return fmt.Errorf("input %d: %v", i, err)
+		}
+		err = e.Execute()
+		if err != nil {
+			return fmt.Errorf("input %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// verifyForkIDInputs executes the scripts of all inputs of tx, which spend
+// prevOuts, with the rules of chains using SIGHASH_FORKID.
+func verifyForkIDInputs(tx *wire.MsgTx, prevOuts []*wire.TxOut) error {
+	for i, prevOut := range prevOuts {
+		err := VerifyForkIDInput(tx, i, prevOut.PkScript, prevOut.Value)
+		if err != nil {
+			return fmt.Errorf("input %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// verifyInputs executes the scripts of all inputs of tx, which spend
+// prevOuts, checking signatures against the digests of h.
+func (h *Hashes) verifyInputs(tx *wire.MsgTx, prevOuts []*wire.TxOut) error {
+	for i, prevOut := range prevOuts {
+		err := h.VerifyInput(tx, i, prevOut.PkScript, prevOut.Value)
+		if err != nil {
+			return fmt.Errorf("input %d: %v", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,354 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package atomicswap implements cross-chain atomic swaps compatible with the
+// Decred swap tools for Bitcoin and the chains derived from it.  It creates and
+// audits the P2SH and P2WSH contracts and builds the transactions paying to,
+// redeeming and refunding them, funded and signed by a Wallet supplied by the
+// caller.
+//
+// The package works with the btcd types for every chain.  The chain is
+// selected by the chaincfg.Params of a Builder, which may describe any chain
+// supporting OP_SHA256 and OP_CHECKLOCKTIMEVERIFY.
+package atomicswap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// SecretSize is the size of the secrets of the contracts created by this
+// package.  Contracts requiring other secret sizes are rejected by
+// AuditContract.
+const SecretSize = 32
+
+// TxVersion is the version of the transactions built by this package.
+const TxVersion = 2
+
+var (
+	// ErrNotAtomicSwap is returned for scripts that are not atomic swap
+	// contracts.
+	ErrNotAtomicSwap = errors.New("contract is not an atomic swap script recognized by this tool")
+
+	// ErrNoContractOutput is returned when a transaction does not pay to
+	// the contract it is expected to pay to.
+	ErrNoContractOutput = errors.New("transaction does not contain the contract output")
+
+	// ErrSecretNotFound is returned by ExtractSecret when the transaction
+	// does not reveal the secret.
+	ErrSecretNotFound = errors.New("transaction does not contain the secret")
+
+	// ErrDust is wrapped by the errors returned when the output of a
+	// redeem or refund transaction would be dust.
+	ErrDust = errors.New("dust")
+)
+
+// Contract is an atomic swap contract script and the parameters encoded in
+// it.
+type Contract struct {
+	Script           []byte
+	RecipientHash160 [ripemd160.Size]byte
+	RefundHash160    [ripemd160.Size]byte
+	SecretHash       [sha256.Size]byte
+	SecretSize       int64
+	LockTime         int64
+}
+
+// NewContract creates the contract paying to the owner of recipientHash when
+// the secret hashing to secretHash is revealed, or to the owner of refundHash
+// after lockTime.
+func NewContract(refundHash, recipientHash *[ripemd160.Size]byte, lockTime int64,
+	secretHash []byte) (*Contract, error) {
+
+	script, err := AtomicSwapContract(refundHash, recipientHash, lockTime, secretHash)
+	if err != nil {
+		return nil, err
+	}
+	return ParseContract(script)
+}
+
+// ParseContract extracts the parameters of a contract script.  It returns
+// ErrNotAtomicSwap when the script is not an atomic swap contract.
+func ParseContract(script []byte) (*Contract, error) {
+	pushes, err := txscript.ExtractAtomicSwapDataPushes(0, script)
+	if err != nil {
+		return nil, err
+	}
+	if pushes == nil {
+		return nil, ErrNotAtomicSwap
+	}
+	return &Contract{
+		Script:           script,
+		RecipientHash160: pushes.RecipientHash160,
+		RefundHash160:    pushes.RefundHash160,
+		SecretHash:       pushes.SecretHash,
+		SecretSize:       pushes.SecretSize,
+		LockTime:         pushes.LockTime,
+	}, nil
+}
+
+// Address returns the P2SH address of the contract.
+func (c *Contract) Address(params *chaincfg.Params) (*btcutil.AddressScriptHash, error) {
+	return btcutil.NewAddressScriptHash(c.Script, params)
+}
+
+// WitnessAddress returns the P2WSH address of the contract.
+func (c *Contract) WitnessAddress(params *chaincfg.Params) (*btcutil.AddressWitnessScriptHash, error) {
+	h := sha256.Sum256(c.Script)
+	return btcutil.NewAddressWitnessScriptHash(h[:], params)
+}
+
+// RecipientAddress returns the address able to redeem the contract with the
+// secret.
+func (c *Contract) RecipientAddress(params *chaincfg.Params) (*btcutil.AddressPubKeyHash, error) {
+	return btcutil.NewAddressPubKeyHash(c.RecipientHash160[:], params)
+}
+
+// RefundAddress returns the address able to refund the contract after its
+// locktime.
+func (c *Contract) RefundAddress(params *chaincfg.Params) (*btcutil.AddressPubKeyHash, error) {
+	return btcutil.NewAddressPubKeyHash(c.RefundHash160[:], params)
+}
+
+// LockTimeIsTime returns whether the locktime of the contract is a unix time
+// rather than a block height.
+func (c *Contract) LockTimeIsTime() bool {
+	return c.LockTime >= int64(txscript.LockTimeThreshold)
+}
+
+// Output returns the index of the P2SH or P2WSH output of tx paying to the
+// contract, or ErrNoContractOutput when there is none.
+func (c *Contract) Output(tx *wire.MsgTx) (int, error) {
+	contractHash := btcutil.Hash160(c.Script)
+	witnessHash := sha256.Sum256(c.Script)
+	for i, out := range tx.TxOut {
+		switch txscript.GetScriptClass(out.PkScript) {
+		case txscript.ScriptHashTy:
+			// A P2SH script is OP_HASH160 OP_DATA_20 <hash> OP_EQUAL.
+			if bytes.Equal(out.PkScript[2:2+ripemd160.Size], contractHash) {
+				return i, nil
+			}
+		case txscript.WitnessV0ScriptHashTy:
+			// A P2WSH script is OP_0 OP_DATA_32 <hash>.
+			if bytes.Equal(out.PkScript[2:], witnessHash[:]) {
+				return i, nil
+			}
+		}
+	}
+	return -1, ErrNoContractOutput
+}
+
+// IsWitnessOutput returns whether the contract output of tx at index
+// contractOut is a P2WSH output, which is spent with a witness.
+func IsWitnessOutput(tx *wire.MsgTx, contractOut int) bool {
+	return txscript.IsPayToWitnessScriptHash(tx.TxOut[contractOut].PkScript)
+}
+
+// KeyHashAddress returns the P2PKH address of the key hash of a P2PKH or
+// P2WPKH address.  The contracts pay to key hashes, so either kind of address
+// can be a party of a swap.
+func KeyHashAddress(addr btcutil.Address, params *chaincfg.Params) (*btcutil.AddressPubKeyHash, error) {
+	switch addr := addr.(type) {
+	case *btcutil.AddressPubKeyHash:
+		return addr, nil
+	case *btcutil.AddressWitnessPubKeyHash:
+		return btcutil.NewAddressPubKeyHash(addr.Hash160()[:], params)
+	}
+	return nil, fmt.Errorf("address %v is not P2PKH or P2WPKH", addr)
+}
+
+// AuditResult describes a contract and the output of the contract transaction
+// paying to it.
+type AuditResult struct {
+	Contract         *Contract
+	ContractAddress  btcutil.Address
+	RecipientAddress *btcutil.AddressPubKeyHash
+	RefundAddress    *btcutil.AddressPubKeyHash
+	OutPoint         wire.OutPoint
+	Value            btcutil.Amount
+}
+
+// AuditContract checks that contractTx pays to the atomic swap contract and
+// that the contract requires a secret of SecretSize bytes, and describes the
+// contract.  The caller must still check the amount, addresses, secret hash
+// and locktime against the terms of the swap.  The contract transaction is
+// identified by its Bitcoin hash; chains hashing transactions differently
+// audit with Builder.AuditContract.
+func AuditContract(params *chaincfg.Params, contract []byte, contractTx *wire.MsgTx) (*AuditResult, error) {
+	b := &Builder{Params: params}
+	return b.AuditContract(contract, contractTx)
+}
+
+// AuditContract is like the AuditContract function for the chain of the
+// builder, identifying the contract transaction with its hashing primitives.
+func (b *Builder) AuditContract(contract []byte, contractTx *wire.MsgTx) (*AuditResult, error) {
+	params := b.Params
+	c, err := ParseContract(contract)
+	if err != nil {
+		return nil, err
+	}
+	contractOut, err := c.Output(contractTx)
+	if err != nil {
+		return nil, err
+	}
+	if c.SecretSize != SecretSize {
+		return nil, fmt.Errorf("contract specifies strange secret size %v", c.SecretSize)
+	}
+
+	var contractAddr btcutil.Address
+	if IsWitnessOutput(contractTx, contractOut) {
+		contractAddr, err = c.WitnessAddress(params)
+	} else {
+		contractAddr, err = c.Address(params)
+	}
+	if err != nil {
+		return nil, err
+	}
+	recipientAddr, err := c.RecipientAddress(params)
+	if err != nil {
+		return nil, err
+	}
+	refundAddr, err := c.RefundAddress(params)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditResult{
+		Contract:         c,
+		ContractAddress:  contractAddr,
+		RecipientAddress: recipientAddr,
+		RefundAddress:    refundAddr,
+		OutPoint:         wire.OutPoint{Hash: b.txHash(contractTx), Index: uint32(contractOut)},
+		Value:            btcutil.Amount(contractTx.TxOut[contractOut].Value),
+	}, nil
+}
+
+// ExtractSecret returns the secret hashing to secretHash revealed by a
+// redemption transaction, or ErrSecretNotFound.
+func ExtractSecret(redemptionTx *wire.MsgTx, secretHash []byte) ([]byte, error) {
+	// Loop over all pushed data and witness items from all inputs, searching
+	// for one that hashes to the expected hash.  By searching through all data
+	// pushes, we avoid any issues that could be caused by the initiator
+	// redeeming the participant's contract with some "nonstandard" or
+	// unrecognized transaction or script type.
+	for _, in := range redemptionTx.TxIn {
+		pushes, err := txscript.PushedData(in.SignatureScript)
+		if err != nil {
+			return nil, err
+		}
+		pushes = append(pushes, in.Witness...)
+		for _, push := range pushes {
+			h := sha256.Sum256(push)
+			if bytes.Equal(h[:], secretHash) {
+				return push, nil
+			}
+		}
+	}
+	return nil, ErrSecretNotFound
+}
+
+// AtomicSwapContract returns an output script that may be redeemed by one of
+// two signature scripts:
+//
+//	<their sig> <their pubkey> <initiator secret> 1
+//
+//	<my sig> <my pubkey> 0
+//
+// The first signature script is the normal redemption path done by the other
+// party and requires the initiator's secret.  The second signature script is
+// the refund path performed by us, but the refund can only be performed after
+// locktime.
+func AtomicSwapContract(pkhMe, pkhThem *[ripemd160.Size]byte, locktime int64, secretHash []byte) ([]byte, error) {
+	b := txscript.NewScriptBuilder()
+
+	b.AddOp(txscript.OP_IF) // Normal redeem path
+	{
+		// Require initiator's secret to be a known length that the redeeming
+		// party can audit.  This is used to prevent fraud attacks between two
+		// currencies that have different maximum data sizes.
+		b.AddOp(txscript.OP_SIZE)
+		b.AddInt64(SecretSize)
+		b.AddOp(txscript.OP_EQUALVERIFY)
+
+		// Require initiator's secret to be known to redeem the output.
+		b.AddOp(txscript.OP_SHA256)
+		b.AddData(secretHash)
+		b.AddOp(txscript.OP_EQUALVERIFY)
+
+		// Verify their signature is being used to redeem the output.  This
+		// would normally end with OP_EQUALVERIFY OP_CHECKSIG but this has been
+		// moved outside of the branch to save a couple bytes.
+		b.AddOp(txscript.OP_DUP)
+		b.AddOp(txscript.OP_HASH160)
+		b.AddData(pkhThem[:])
+	}
+	b.AddOp(txscript.OP_ELSE) // Refund path
+	{
+		// Verify locktime and drop it off the stack (which is not done by
+		// CLTV).
+		b.AddInt64(locktime)
+		b.AddOp(txscript.OP_CHECKLOCKTIMEVERIFY)
+		b.AddOp(txscript.OP_DROP)
+
+		// Verify our signature is being used to redeem the output.  This would
+		// normally end with OP_EQUALVERIFY OP_CHECKSIG but this has been moved
+		// outside of the branch to save a couple bytes.
+		b.AddOp(txscript.OP_DUP)
+		b.AddOp(txscript.OP_HASH160)
+		b.AddData(pkhMe[:])
+	}
+	b.AddOp(txscript.OP_ENDIF)
+
+	// Complete the signature check.
+	b.AddOp(txscript.OP_EQUALVERIFY)
+	b.AddOp(txscript.OP_CHECKSIG)
+
+	return b.Script()
+}
+
+// RedeemP2SHContract returns the signature script to redeem a contract output
+// using the redeemer's signature and the initiator's secret.  This function
+// assumes P2SH and appends the contract as the final data push.
+func RedeemP2SHContract(contract, sig, pubkey, secret []byte) ([]byte, error) {
+	b := txscript.NewScriptBuilder()
+	b.AddData(sig)
+	b.AddData(pubkey)
+	b.AddData(secret)
+	b.AddInt64(1)
+	b.AddData(contract)
+	return b.Script()
+}
+
+// RefundP2SHContract returns the signature script to refund a contract output
+// using the contract author's signature after the locktime has been reached.
+// This function assumes P2SH and appends the contract as the final data push.
+func RefundP2SHContract(contract, sig, pubkey []byte) ([]byte, error) {
+	b := txscript.NewScriptBuilder()
+	b.AddData(sig)
+	b.AddData(pubkey)
+	b.AddInt64(0)
+	b.AddData(contract)
+	return b.Script()
+}
+
+// RedeemP2WSHContract returns the witness to redeem a P2WSH contract output
+// using the redeemer's signature and the initiator's secret.  The contract is
+// the final item.
+func RedeemP2WSHContract(contract, sig, pubkey, secret []byte) wire.TxWitness {
+	return wire.TxWitness{sig, pubkey, secret, {1}, contract}
+}
+
+// RefundP2WSHContract returns the witness to refund a P2WSH contract output
+// using the contract author's signature after the locktime has been reached.
+// The contract is the final item.
+func RefundP2WSHContract(contract, sig, pubkey []byte) wire.TxWitness {
+	return wire.TxWitness{sig, pubkey, nil, contract}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package atomicswap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// newTestContractTx returns a contract and a transaction paying value to it.
+func newTestContractTx(t *testing.T, value int64) ([]byte, *wire.MsgTx) {
+	t.Helper()
+	var pkhMe, pkhThem [ripemd160.Size]byte
+	pkhMe[0], pkhThem[0] = 1, 2
+	secretHash := sha256.Sum256(bytes.Repeat([]byte{0x2a}, SecretSize))
+	contract, err := AtomicSwapContract(&pkhMe, &pkhThem,
+		time.Now().Add(48*time.Hour).Unix(), secretHash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	contractAddr, err := btcutil.NewAddressScriptHash(contract, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, err := txscript.PayToAddrScript(contractAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+	tx.AddTxOut(wire.NewTxOut(value, pkScript))
+	return contract, tx
+}
+
+func TestAuditContractTxHash(t *testing.T) {
+	contract, contractTx := newTestContractTx(t, 1e8)
+
+	tests := []struct {
+		name string
+		b    *Builder
+		want wire.OutPoint
+	}{
+		{
+			name: "bitcoin",
+			b:    &Builder{Params: &chaincfg.MainNetParams},
+			want: wire.OutPoint{Hash: contractTx.TxHash(), Index: 1},
+		},
+		{
+			name: "single sha256",
+			b:    &Builder{Params: &chaincfg.MainNetParams, Hashes: SingleSHA256},
+			want: wire.OutPoint{Hash: SingleSHA256.TxHash(contractTx), Index: 1},
+		},
+	}
+	for _, test := range tests {
+		audit, err := test.b.AuditContract(contract, contractTx)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if audit.OutPoint != test.want {
+			t.Errorf("%s: outpoint is %v, want %v", test.name, audit.OutPoint, test.want)
+		}
+		if audit.Value != 1e8 {
+			t.Errorf("%s: value is %v, want 1 BTC", test.name, audit.Value)
+		}
+	}
+
+	// The package function audits with the Bitcoin hash.
+	audit, err := AuditContract(&chaincfg.MainNetParams, contract, contractTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audit.OutPoint != tests[0].want {
+		t.Errorf("outpoint is %v, want %v", audit.OutPoint, tests[0].want)
+	}
+}
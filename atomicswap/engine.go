@@ -0,0 +1,432 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package atomicswap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// scriptEngine is a script interpreter for the inputs verified by
+// VerifyForkIDInput and VerifyInput, checking signatures against the digests
+// of sigHash.
+type scriptEngine struct {
+	tx      *wire.MsgTx
+	idx     int
+	amount  int64
+	sigHash SigHashFunc
+
+	// forkID requires all signatures to use SIGHASH_FORKID.
+	forkID bool
+
+	stack [][]byte
+}
+
+// verify executes the signature script of the input and pkScript, and the
+// redeem script of P2SH outputs.
+func (vm *scriptEngine) verify(pkScript []byte) error {
+	if vm.idx < 0 || vm.idx >= len(vm.tx.TxIn) {
+		return fmt.Errorf("input index %d out of range", vm.idx)
+	}
+	sigScript := vm.tx.TxIn[vm.idx].SignatureScript
+	if !txscript.IsPushOnlyScript(sigScript) {
+		return errors.New("signature script is not push only")
+	}
+
+	err := vm.execute(sigScript)
+	if err != nil {
+		return err
+	}
+	sigStack := append([][]byte(nil), vm.stack...)
+	err = vm.execute(pkScript)
+	if err != nil {
+		return err
+	}
+	err = vm.checkResult()
+	if err != nil {
+		return err
+	}
+
+	if txscript.GetScriptClass(pkScript) == txscript.ScriptHashTy {
+		redeemScript := sigStack[len(sigStack)-1]
+		vm.stack = sigStack[:len(sigStack)-1]
+		err = vm.execute(redeemScript)
+		if err != nil {
+			return err
+		}
+		err = vm.checkResult()
+		if err != nil {
+			return err
+		}
+	}
+	if len(vm.stack) != 1 {
+		return errors.New("stack is not clean after execution")
+	}
+	return nil
+}
+
+// scriptOp is a parsed opcode and the data it pushes.
+type scriptOp struct {
+	code byte
+	data []byte
+}
+
+// parseScript splits script into its opcodes.
+func parseScript(script []byte) ([]scriptOp, error) {
+	var ops []scriptOp
+	for i := 0; i < len(script); {
+		code := script[i]
+		i++
+		var n int
+		switch {
+		case code >= txscript.OP_DATA_1 && code <= txscript.OP_DATA_75:
+			n = int(code)
+		case code == txscript.OP_PUSHDATA1:
+			if i+1 > len(script) {
+				return nil, errors.New("truncated OP_PUSHDATA1")
+			}
+			n = int(script[i])
+			i++
+		case code == txscript.OP_PUSHDATA2:
+			if i+2 > len(script) {
+				return nil, errors.New("truncated OP_PUSHDATA2")
+			}
+			n = int(script[i]) | int(script[i+1])<<8
+			i += 2
+		case code == txscript.OP_PUSHDATA4:
+			if i+4 > len(script) {
+				return nil, errors.New("truncated OP_PUSHDATA4")
+			}
+			n = int(script[i]) | int(script[i+1])<<8 | int(script[i+2])<<16 |
+				int(script[i+3])<<24
+			i += 4
+		}
+		if n < 0 || i+n > len(script) {
+			return nil, errors.New("push exceeds the script")
+		}
+		ops = append(ops, scriptOp{code: code, data: script[i : i+n]})
+		i += n
+	}
+	return ops, nil
+}
+
+func (vm *scriptEngine) push(b []byte) {
+	vm.stack = append(vm.stack, b)
+}
+
+func (vm *scriptEngine) pop() ([]byte, error) {
+	if len(vm.stack) == 0 {
+		return nil, errors.New("stack underflow")
+	}
+	b := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return b, nil
+}
+
+// checkResult checks that a script left a true value on top of the stack.
+func (vm *scriptEngine) checkResult() error {
+	if len(vm.stack) == 0 || !asBool(vm.stack[len(vm.stack)-1]) {
+		return errors.New("script evaluated to false")
+	}
+	return nil
+}
+
+// execute runs script on the stack of the engine.
+func (vm *scriptEngine) execute(script []byte) error {
+	ops, err := parseScript(script)
+	if err != nil {
+		return err
+	}
+	// conds holds whether each enclosing OP_IF branch is executed.
+	var conds []bool
+	for _, op := range ops {
+		executing := true
+		for _, c := range conds {
+			executing = executing && c
+		}
+		switch op.code {
+		case txscript.OP_IF, txscript.OP_NOTIF:
+			cond := false
+			if executing {
+				b, err := vm.pop()
+				if err != nil {
+					return err
+				}
+				cond = asBool(b) == (op.code == txscript.OP_IF)
+			}
+			conds = append(conds, cond)
+			continue
+		case txscript.OP_ELSE:
+			if len(conds) == 0 {
+				return errors.New("OP_ELSE without OP_IF")
+			}
+			conds[len(conds)-1] = !conds[len(conds)-1]
+			continue
+		case txscript.OP_ENDIF:
+			if len(conds) == 0 {
+				return errors.New("OP_ENDIF without OP_IF")
+			}
+			conds = conds[:len(conds)-1]
+			continue
+		}
+		if !executing {
+			continue
+		}
+		err := vm.step(op, script)
+		if err != nil {
+			return err
+		}
+	}
+	if len(conds) != 0 {
+		return errors.New("unbalanced conditional")
+	}
+	return nil
+}
+
+// step executes a single opcode of script outside of the flow control.
+func (vm *scriptEngine) step(op scriptOp, script []byte) error {
+	switch {
+	case op.code <= txscript.OP_PUSHDATA4:
+		vm.push(op.data)
+		return nil
+	case op.code == txscript.OP_1NEGATE:
+		vm.push(scriptNum(-1))
+		return nil
+	case op.code >= txscript.OP_1 && op.code <= txscript.OP_16:
+		vm.push(scriptNum(int64(op.code - (txscript.OP_1 - 1))))
+		return nil
+	}
+
+	switch op.code {
+	case txscript.OP_NOP:
+	case txscript.OP_VERIFY:
+		b, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		if !asBool(b) {
+			return errors.New("OP_VERIFY failed")
+		}
+	case txscript.OP_DROP:
+		_, err := vm.pop()
+		return err
+	case txscript.OP_DUP:
+		b, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.push(b)
+		vm.push(b)
+	case txscript.OP_SIZE:
+		if len(vm.stack) == 0 {
+			return errors.New("stack underflow")
+		}
+		vm.push(scriptNum(int64(len(vm.stack[len(vm.stack)-1]))))
+	case txscript.OP_EQUAL, txscript.OP_EQUALVERIFY:
+		a, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		b, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		equal := bytes.Equal(a, b)
+		if op.code == txscript.OP_EQUALVERIFY {
+			if !equal {
+				return errors.New("OP_EQUALVERIFY failed")
+			}
+			return nil
+		}
+		vm.push(fromBool(equal))
+	case txscript.OP_SHA256:
+		b, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		h := sha256.Sum256(b)
+		vm.push(h[:])
+	case txscript.OP_HASH160:
+		b, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.push(btcutil.Hash160(b))
+	case txscript.OP_CHECKLOCKTIMEVERIFY:
+		if len(vm.stack) == 0 {
+			return errors.New("stack underflow")
+		}
+		return vm.checkLockTime(vm.stack[len(vm.stack)-1])
+	case txscript.OP_CHECKSIG, txscript.OP_CHECKSIGVERIFY:
+		pubKey, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		valid, err := vm.checkSig(sig, pubKey, script)
+		if err != nil {
+			return err
+		}
+		if op.code == txscript.OP_CHECKSIGVERIFY {
+			if !valid {
+				return errors.New("OP_CHECKSIGVERIFY failed")
+			}
+			return nil
+		}
+		vm.push(fromBool(valid))
+	default:
+		return fmt.Errorf("unsupported opcode 0x%02x", op.code)
+	}
+	return nil
+}
+
+// checkLockTime implements OP_CHECKLOCKTIMEVERIFY (BIP65).
+func (vm *scriptEngine) checkLockTime(b []byte) error {
+	lockTime, err := parseScriptNum(b, 5)
+	if err != nil {
+		return err
+	}
+	if lockTime < 0 {
+		return errors.New("negative locktime")
+	}
+	txLockTime := int64(vm.tx.LockTime)
+	threshold := int64(txscript.LockTimeThreshold)
+	if (lockTime < threshold) != (txLockTime < threshold) {
+		return errors.New("mismatched locktime types")
+	}
+	if lockTime > txLockTime {
+		return fmt.Errorf("locktime requirement not satisfied: %d > %d", lockTime, txLockTime)
+	}
+	if vm.tx.TxIn[vm.idx].Sequence == wire.MaxTxInSequenceNum {
+		return errors.New("transaction input is finalized")
+	}
+	return nil
+}
+
+// checkSig checks a signature of the input over script, which must use
+// SIGHASH_FORKID when the engine requires it.  An empty signature fails the
+// check, any other invalid signature is an error.
+func (vm *scriptEngine) checkSig(sig, pubKey, script []byte) (bool, error) {
+	if len(sig) == 0 {
+		return false, nil
+	}
+	hashType := txscript.SigHashType(sig[len(sig)-1])
+	baseType := hashType &^ txscript.SigHashAnyOneCanPay
+	if vm.forkID {
+		if hashType&SigHashForkID == 0 {
+			return false, errors.New("signature does not use SIGHASH_FORKID")
+		}
+		baseType &^= SigHashForkID
+	}
+	switch baseType {
+	case txscript.SigHashAll, txscript.SigHashNone, txscript.SigHashSingle:
+	default:
+		return false, fmt.Errorf("invalid signature hash type 0x%02x", byte(hashType))
+	}
+	signature, err := btcec.ParseDERSignature(sig[:len(sig)-1], btcec.S256())
+	if err != nil {
+		return false, err
+	}
+	if signature.S.Cmp(halfOrder) > 0 {
+		return false, errors.New("signature is not canonical due to unnecessarily high S value")
+	}
+	key, err := btcec.ParsePubKey(pubKey, btcec.S256())
+	if err != nil {
+		return false, err
+	}
+	hash, err := vm.sigHash(script, hashType, vm.tx, vm.idx, vm.amount)
+	if err != nil {
+		return false, err
+	}
+	if !signature.Verify(hash, key) {
+		return false, errors.New("signature verification failed")
+	}
+	return true, nil
+}
+
+// halfOrder is half the order of the secp256k1 group, the maximum S value of
+// canonical signatures.
+var halfOrder = new(big.Int).Rsh(btcec.S256().N, 1)
+
+func asBool(b []byte) bool {
+	for i := range b {
+		if b[i] != 0 {
+			// Negative zero is false.
+			if i == len(b)-1 && b[i] == 0x80 {
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func fromBool(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+	return nil
+}
+
+// scriptNum encodes n as a minimal script number.
+func scriptNum(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+	negative := n < 0
+	if negative {
+		n = -n
+	}
+	var b []byte
+	for n > 0 {
+		b = append(b, byte(n&0xff))
+		n >>= 8
+	}
+	if b[len(b)-1]&0x80 != 0 {
+		extra := byte(0x00)
+		if negative {
+			extra = 0x80
+		}
+		b = append(b, extra)
+	} else if negative {
+		b[len(b)-1] |= 0x80
+	}
+	return b
+}
+
+// parseScriptNum decodes a minimally encoded script number of at most
+// maxLen bytes.
+func parseScriptNum(b []byte, maxLen int) (int64, error) {
+	if len(b) > maxLen {
+		return 0, fmt.Errorf("numeric value encoded as %d bytes exceeds the maximum of %d",
+			len(b), maxLen)
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if b[len(b)-1]&0x7f == 0 && (len(b) == 1 || b[len(b)-2]&0x80 == 0) {
+		return 0, errors.New("numeric value is not minimally encoded")
+	}
+	var n int64
+	for i, v := range b {
+		n |= int64(v) << uint(8*i)
+	}
+	if b[len(b)-1]&0x80 != 0 {
+		n &^= int64(0x80) << uint(8*(len(b)-1))
+		return -n, nil
+	}
+	return n, nil
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package atomicswap
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// SigHashForkID is the flag set in the signature hash types of chains with
+// replay protected signatures such as Bitcoin Cash.  Their signatures commit
+// to a BIP143-style digest of the transaction, including the amount of the
+// spent output, for every kind of input.
+const SigHashForkID txscript.SigHashType = 0x40
+
+// ForkIDWallet is a Wallet able to sign inputs with SIGHASH_FORKID, which is
+// required by Builders with ForkID set.
+type ForkIDWallet interface {
+	Wallet
+
+	// CreateForkIDSig returns the SIGHASH_ALL|SIGHASH_FORKID signature of
+	// input idx of tx, which spends amount from an output with pkScript, by
+	// the key of addr, and the serialized compressed pubkey of that key.
+	CreateForkIDSig(tx *wire.MsgTx, idx int, pkScript []byte, amount int64, addr btcutil.Address) (sig, pubkey []byte, err error)
+}
+
+// CalcForkIDSignatureHash returns the digest signed by the hashType
+// signature of input idx of tx, spending amount from an output with script.
+// hashType must include SigHashForkID.  For P2SH outputs script is the
+// redeem script.
+func CalcForkIDSignatureHash(script []byte, hashType txscript.SigHashType, tx *wire.MsgTx,
+	idx int, amount int64) ([]byte, error) {
+
+	if hashType&SigHashForkID == 0 {
+		return nil, errors.New("signature hash type does not include SIGHASH_FORKID")
+	}
+	if idx < 0 || idx >= len(tx.TxIn) {
+		return nil, fmt.Errorf("input index %d out of range", idx)
+	}
+	// With a fork ID of zero the digest is the BIP143 digest, which btcd
+	// computes for witness programs.  The script code is the script itself
+	// as long as it is not a P2WPKH program, which an executed script never
+	// is.
+	return txscript.CalcWitnessSigHash(script, txscript.NewTxSigHashes(tx), hashType,
+		tx, idx, amount)
+}
+
+// RawTxInForkIDSignature returns the serialized SIGHASH_ALL|SIGHASH_FORKID
+// signature of input idx of tx, spending amount from an output with script,
+// by key.
+func RawTxInForkIDSignature(tx *wire.MsgTx, idx int, script []byte, amount int64,
+	key *btcec.PrivateKey) ([]byte, error) {
+
+	hashType := txscript.SigHashAll | SigHashForkID
+	hash, err := CalcForkIDSignatureHash(script, hashType, tx, idx, amount)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := key.Sign(hash)
+	if err != nil {
+		return nil, err
+	}
+	return append(sig.Serialize(), byte(hashType)), nil
+}
+
+// VerifyForkIDInput executes the scripts of input idx of tx, which spends
+// amount from an output with pkScript, with the rules of Bitcoin Cash: all
+// signatures must use SIGHASH_FORKID, be strictly DER encoded with a low S
+// value, and a failed signature check must use an empty signature.  Only the
+// opcodes of P2PKH and P2SH outputs and of the atomic swap contracts are
+// supported.
+func VerifyForkIDInput(tx *wire.MsgTx, idx int, pkScript []byte, amount int64) error {
+	vm := &scriptEngine{
+		tx:      tx,
+		idx:     idx,
+		amount:  amount,
+		sigHash: CalcForkIDSignatureHash,
+		forkID:  true,
+	}
+	return vm.verify(pkScript)
+}
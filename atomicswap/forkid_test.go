@@ -0,0 +1,192 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package atomicswap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// forkIDWallet is a ForkIDWallet keeping its keys in memory.  PayTo spends a
+// made up output, as the contract transactions are not verified.
+type forkIDWallet struct {
+	keys map[string]*btcec.PrivateKey
+}
+
+func newForkIDWallet() *forkIDWallet {
+	return &forkIDWallet{keys: make(map[string]*btcec.PrivateKey)}
+}
+
+func (w *forkIDWallet) NewAddress() (btcutil.Address, error) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+	pkh := btcutil.Hash160(key.PubKey().SerializeCompressed())
+	addr, err := btcutil.NewAddressPubKeyHash(pkh, &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, err
+	}
+	w.keys[addr.EncodeAddress()] = key
+	return addr, nil
+}
+
+func (w *forkIDWallet) PayTo(amounts map[btcutil.Address]btcutil.Amount, feePerKb btcutil.Amount) (*wire.MsgTx, btcutil.Amount, error) {
+	tx := wire.NewMsgTx(TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil, nil))
+	for addr, amount := range amounts {
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, 0, err
+		}
+		tx.AddTxOut(wire.NewTxOut(int64(amount), pkScript))
+	}
+	return tx, 1000, nil
+}
+
+func (w *forkIDWallet) CreateSig(tx *wire.MsgTx, idx int, pkScript []byte, addr btcutil.Address) (sig, pubkey []byte, err error) {
+	return nil, nil, errors.New("signature without SIGHASH_FORKID")
+}
+
+func (w *forkIDWallet) CreateForkIDSig(tx *wire.MsgTx, idx int, pkScript []byte, amount int64,
+	addr btcutil.Address) (sig, pubkey []byte, err error) {
+
+	key := w.keys[addr.EncodeAddress()]
+	sig, err = RawTxInForkIDSignature(tx, idx, pkScript, amount, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sig, key.PubKey().SerializeCompressed(), nil
+}
+
+// buildForkIDContract returns a contract built by b with a new wallet, the
+// wallet and the secret of the contract.
+func buildForkIDContract(t *testing.T, b *Builder) (*BuiltContract, *forkIDWallet, []byte) {
+	t.Helper()
+	w := newForkIDWallet()
+	them, err := w.NewAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := bytes.Repeat([]byte{0x2a}, SecretSize)
+	secretHash := sha256.Sum256(secret)
+	bc, err := b.BuildContract(w, &ContractArgs{
+		Them:       them.(*btcutil.AddressPubKeyHash),
+		Amount:     1e8,
+		LockTime:   1600000000,
+		SecretHash: secretHash[:],
+	}, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bc, w, secret
+}
+
+func TestForkIDContract(t *testing.T) {
+	b := &Builder{Params: &chaincfg.MainNetParams, ForkID: true}
+	bc, w, secret := buildForkIDContract(t, b)
+	contractOut := bc.ContractTx.TxOut[0]
+
+	redeemTx, _, err := b.BuildRedeem(w, bc.Contract, bc.ContractTx, secret, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tx := range map[string]*wire.MsgTx{"redeem": redeemTx, "refund": bc.RefundTx} {
+		err = VerifyForkIDInput(tx, 0, contractOut.PkScript, contractOut.Value)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		sigScript, err := txscript.PushedData(tx.TxIn[0].SignatureScript)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := sigScript[0]
+		if hashType := txscript.SigHashType(sig[len(sig)-1]); hashType != txscript.SigHashAll|SigHashForkID {
+			t.Errorf("%s: hash type is %#x", name, hashType)
+		}
+
+		// The signatures commit to the amount of the contract output.
+		err = VerifyForkIDInput(tx, 0, contractOut.PkScript, contractOut.Value+1)
+		if err == nil {
+			t.Errorf("%s: verified with another amount", name)
+		}
+		// They are not valid Bitcoin signatures.
+		err = verifyInputs(tx, []*wire.TxOut{contractOut})
+		if err == nil {
+			t.Errorf("%s: verified by the Bitcoin rules", name)
+		}
+	}
+	extracted, err := ExtractSecret(redeemTx, bc.Contract.SecretHash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(extracted, secret) {
+		t.Fatalf("extracted secret %x, want %x", extracted, secret)
+	}
+
+	// A redeem with the wrong secret fails verification when it is built.
+	_, _, err = b.BuildRedeem(w, bc.Contract, bc.ContractTx, bytes.Repeat([]byte{1}, SecretSize), 1000)
+	if err == nil {
+		t.Fatal("redeem with the wrong secret was built")
+	}
+}
+
+func TestForkIDRejectsLegacySignatures(t *testing.T) {
+	b := &Builder{Params: &chaincfg.MainNetParams, ForkID: true}
+	bc, w, secret := buildForkIDContract(t, b)
+	contractOut := bc.ContractTx.TxOut[0]
+	redeemTx, _, err := b.BuildRedeem(w, bc.Contract, bc.ContractTx, secret, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := btcutil.NewAddressPubKeyHash(bc.Contract.RecipientHash160[:], b.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := w.keys[signer.EncodeAddress()]
+
+	legacySig, err := txscript.RawTxInSignature(redeemTx, 0, bc.Contract.Script, txscript.SigHashAll, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A BIP143 signature without the SIGHASH_FORKID flag.
+	hash, err := txscript.CalcWitnessSigHash(bc.Contract.Script, txscript.NewTxSigHashes(redeemTx),
+		txscript.SigHashAll, redeemTx, 0, contractOut.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecSig, err := key.Sign(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bip143Sig := append(ecSig.Serialize(), byte(txscript.SigHashAll))
+
+	for name, sig := range map[string][]byte{"legacy": legacySig, "BIP143": bip143Sig} {
+		tx := redeemTx.Copy()
+		tx.TxIn[0].SignatureScript, err = RedeemP2SHContract(bc.Contract.Script, sig,
+			key.PubKey().SerializeCompressed(), secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = VerifyForkIDInput(tx, 0, contractOut.PkScript, contractOut.Value)
+		if err == nil {
+			t.Errorf("%s signature without SIGHASH_FORKID verified", name)
+		}
+	}
+
+	_, err = CalcForkIDSignatureHash(bc.Contract.Script, txscript.SigHashAll, redeemTx, 0,
+		contractOut.Value)
+	if err == nil {
+		t.Error("signature hash calculated without SIGHASH_FORKID")
+	}
+}
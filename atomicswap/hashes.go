@@ -0,0 +1,160 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package atomicswap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// SigHashFunc returns the digest signed by the hashType signature of input
+// idx of tx, spending amount from an output with script.  For P2SH outputs
+// script is the redeem script.
+type SigHashFunc func(script []byte, hashType txscript.SigHashType, tx *wire.MsgTx,
+	idx int, amount int64) ([]byte, error)
+
+// Hashes are the hashing primitives of a chain that identifies transactions
+// and hashes signatures differently than the double SHA256 of Bitcoin.  The
+// contracts and their P2SH addresses are not affected, as they only use
+// OP_SHA256 and Hash160.
+type Hashes struct {
+	// TxHash returns the hash identifying tx in the outpoints spending its
+	// outputs.
+	TxHash func(tx *wire.MsgTx) chainhash.Hash
+
+	// SigHash returns the digest signed by the signatures of the chain.
+	SigHash SigHashFunc
+}
+
+// SingleSHA256 are the hashing primitives of chains hashing transactions and
+// legacy signature hash preimages with a single SHA256, such as Groestlcoin.
+var SingleSHA256 = &Hashes{
+	TxHash:  singleSHA256TxHash,
+	SigHash: calcSingleSHA256SignatureHash,
+}
+
+func singleSHA256TxHash(tx *wire.MsgTx) chainhash.Hash {
+	var buf bytes.Buffer
+	buf.Grow(tx.SerializeSizeStripped())
+	_ = tx.SerializeNoWitness(&buf)
+	return chainhash.HashH(buf.Bytes())
+}
+
+func calcSingleSHA256SignatureHash(script []byte, hashType txscript.SigHashType, tx *wire.MsgTx,
+	idx int, amount int64) ([]byte, error) {
+
+	preimage, err := SignatureHashPreimage(script, hashType, tx, idx)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(preimage)
+	return hash[:], nil
+}
+
+// SignatureHashPreimage returns the serialization of tx hashed by the legacy
+// (pre-segwit) hashType signature of input idx, spending an output with
+// script, which Bitcoin hashes with double SHA256.  Scripts containing
+// OP_CODESEPARATOR are not supported, and SIGHASH_SINGLE requires an output
+// with the index of the input.
+func SignatureHashPreimage(script []byte, hashType txscript.SigHashType, tx *wire.MsgTx,
+	idx int) ([]byte, error) {
+
+	if idx < 0 || idx >= len(tx.TxIn) {
+		return nil, fmt.Errorf("input index %d out of range", idx)
+	}
+	ops, err := parseScript(script)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		if op.code == txscript.OP_CODESEPARATOR {
+			return nil, errors.New("script contains OP_CODESEPARATOR")
+		}
+	}
+
+	txCopy := tx.Copy()
+	for i := range txCopy.TxIn {
+		txCopy.TxIn[i].SignatureScript = nil
+		txCopy.TxIn[i].Witness = nil
+	}
+	txCopy.TxIn[idx].SignatureScript = script
+
+	switch hashType & 0x1f {
+	case txscript.SigHashNone:
+		txCopy.TxOut = txCopy.TxOut[:0]
+		for i := range txCopy.TxIn {
+			if i != idx {
+				txCopy.TxIn[i].Sequence = 0
+			}
+		}
+	case txscript.SigHashSingle:
+		if idx >= len(txCopy.TxOut) {
+			return nil, fmt.Errorf("SIGHASH_SINGLE input %d has no output", idx)
+		}
+		txCopy.TxOut = txCopy.TxOut[:idx+1]
+		for i := 0; i < idx; i++ {
+			txCopy.TxOut[i] = &wire.TxOut{Value: -1}
+		}
+		for i := range txCopy.TxIn {
+			if i != idx {
+				txCopy.TxIn[i].Sequence = 0
+			}
+		}
+	}
+	if hashType&txscript.SigHashAnyOneCanPay != 0 {
+		txCopy.TxIn = txCopy.TxIn[idx : idx+1]
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(txCopy.SerializeSizeStripped() + 4)
+	err = txCopy.SerializeNoWitness(&buf)
+	if err != nil {
+		return nil, err
+	}
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(hashType))
+	buf.Write(b[:])
+	return buf.Bytes(), nil
+}
+
+// RawTxInSignature returns the serialized SIGHASH_ALL signature of input idx
+// of tx, spending amount from an output with script, by key.
+func (h *Hashes) RawTxInSignature(tx *wire.MsgTx, idx int, script []byte, amount int64,
+	key *btcec.PrivateKey) ([]byte, error) {
+
+	hash, err := h.SigHash(script, txscript.SigHashAll, tx, idx, amount)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := key.Sign(hash)
+	if err != nil {
+		return nil, err
+	}
+	return append(sig.Serialize(), byte(txscript.SigHashAll)), nil
+}
+
+// VerifyInput executes the scripts of input idx of tx, which spends amount
+// from an output with pkScript, checking signatures against the digests of
+// the hashing primitives.  Signatures must be strictly DER encoded with a low
+// S value, and a failed signature check must use an empty signature.  Only
+// the opcodes of P2PKH and P2SH outputs and of the atomic swap contracts are
+// supported.
+func (h *Hashes) VerifyInput(tx *wire.MsgTx, idx int, pkScript []byte, amount int64) error {
+	vm := &scriptEngine{
+		tx:      tx,
+		idx:     idx,
+		amount:  amount,
+		sigHash: h.SigHash,
+	}
+	return vm.verify(pkScript)
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2016 The btcsuite developers
+// Copyright (c) 2016-2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package atomicswap
+
+import (
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Worst case script and input/output size estimates.
+const (
+	// redeemAtomicSwapSigScriptSize is the worst case (largest) serialize size
+	// of a transaction input script to redeem the atomic swap contract.  This
+	// does not include final push for the contract itself.
+	//
+	//   - OP_DATA_73
+	//   - 72 bytes DER signature + 1 byte sighash
+	//   - OP_DATA_33
+	//   - 33 bytes serialized compressed pubkey
+	//   - OP_DATA_32
+	//   - 32 bytes secret
+	//   - OP_TRUE
+	redeemAtomicSwapSigScriptSize = 1 + 73 + 1 + 33 + 1 + 32 + 1
+
+	// refundAtomicSwapSigScriptSize is the worst case (largest) serialize size
+	// of a transaction input script that refunds a P2SH atomic swap output.
+	// This does not include final push for the contract itself.
+	//
+	//   - OP_DATA_73
+	//   - 72 bytes DER signature + 1 byte sighash
+	//   - OP_DATA_33
+	//   - 33 bytes serialized compressed pubkey
+	//   - OP_FALSE
+	refundAtomicSwapSigScriptSize = 1 + 73 + 1 + 33 + 1
+
+	// redeemP2PKHSigScriptSize is the worst case (largest) serialize size
+	// of a transaction input script that redeems a compressed P2PKH output.
+	//
+	//   - OP_DATA_73
+	//   - 72 bytes DER signature + 1 byte sighash
+	//   - OP_DATA_33
+	//   - 33 bytes serialized compressed pubkey
+	redeemP2PKHSigScriptSize = 1 + 73 + 1 + 33
+
+	// redeemAtomicSwapWitnessSize is the worst case (largest) serialize
+	// size of a witness that redeems a P2WSH atomic swap output.  This does
+	// not include the final item for the contract itself.
+	//
+	//   - 1 byte compact int encoding the number of items
+	//   - 1 byte compact int encoding value 73
+	//   - 72 bytes DER signature + 1 byte sighash
+	//   - 1 byte compact int encoding value 33
+	//   - 33 bytes serialized compressed pubkey
+	//   - 1 byte compact int encoding value 32
+	//   - 32 bytes secret
+	//   - 1 byte compact int encoding value 1
+	//   - 1 byte true value selecting the redeem path
+	redeemAtomicSwapWitnessSize = 1 + 1 + 73 + 1 + 33 + 1 + 32 + 1 + 1
+
+	// refundAtomicSwapWitnessSize is the worst case (largest) serialize
+	// size of a witness that refunds a P2WSH atomic swap output.  This does
+	// not include the final item for the contract itself.
+	//
+	//   - 1 byte compact int encoding the number of items
+	//   - 1 byte compact int encoding value 73
+	//   - 72 bytes DER signature + 1 byte sighash
+	//   - 1 byte compact int encoding value 33
+	//   - 33 bytes serialized compressed pubkey
+	//   - 1 byte compact int encoding value 0, the empty false value
+	//     selecting the refund path
+	refundAtomicSwapWitnessSize = 1 + 1 + 73 + 1 + 33 + 1
+
+	// witnessScaleFactor is the weight of a non-witness byte of a
+	// transaction.  Witness bytes weigh one.
+	witnessScaleFactor = 4
+
+	// p2pkhOutputSize is the serialize size of a transaction output with a
+	// P2PKH output script.
+	//
+	//   - 8 bytes output value
+	//   - 1 byte compact int encoding value 25
+	//   - 25 bytes P2PKH output script
+	p2pkhOutputSize = 8 + 1 + 25
+
+	// p2shOutputSize is the serialize size of a transaction output with a
+	// P2SH output script.
+	//
+	//   - 8 bytes output value
+	//   - 1 byte compact int encoding value 23
+	//   - 23 bytes P2SH output script
+	p2shOutputSize = 8 + 1 + 23
+)
+
+func sumOutputSerializeSizes(outputs []*wire.TxOut) (serializeSize int) {
+	for _, txOut := range outputs {
+		serializeSize += txOut.SerializeSize()
+	}
+	return serializeSize
+}
+
+// inputSize returns the size of the transaction input needed to include a
+// signature script with size sigScriptSize.  It is calculated as:
+//
+//   - 32 bytes previous tx
+//   - 4 bytes output index
+//   - Compact int encoding sigScriptSize
+//   - sigScriptSize bytes signature script
+//   - 4 bytes sequence
+func inputSize(sigScriptSize int) int {
+	return 32 + 4 + wire.VarIntSerializeSize(uint64(sigScriptSize)) + sigScriptSize + 4
+}
+
+// EstimateRedeemSerializeSize returns a worst case serialize size estimates for
+// a transaction that redeems an atomic swap P2SH output.
+func EstimateRedeemSerializeSize(contract []byte, txOuts []*wire.TxOut) int {
+	contractPush, err := txscript.NewScriptBuilder().AddData(contract).Script()
+	if err != nil {
+		// Should never be hit since this script does exceed the limits.
+		panic(err)
+	}
+	contractPushSize := len(contractPush)
+
+	// 12 additional bytes are for version, locktime and expiry.
+	return 12 + wire.VarIntSerializeSize(1) +
+		wire.VarIntSerializeSize(uint64(len(txOuts))) +
+		inputSize(redeemAtomicSwapSigScriptSize+contractPushSize) +
+		sumOutputSerializeSizes(txOuts)
+}
+
+// EstimateRefundSerializeSize returns a worst case serialize size estimates for
+// a transaction that refunds an atomic swap P2SH output.
+func EstimateRefundSerializeSize(contract []byte, txOuts []*wire.TxOut) int {
+	contractPush, err := txscript.NewScriptBuilder().AddData(contract).Script()
+	if err != nil {
+		// Should never be hit since this script does exceed the limits.
+		panic(err)
+	}
+	contractPushSize := len(contractPush)
+
+	// 12 additional bytes are for version, locktime and expiry.
+	return 12 + wire.VarIntSerializeSize(1) +
+		wire.VarIntSerializeSize(uint64(len(txOuts))) +
+		inputSize(refundAtomicSwapSigScriptSize+contractPushSize) +
+		sumOutputSerializeSizes(txOuts)
+}
+
+// EstimateBatchRedeemSerializeSize returns a worst case serialize size
+// estimate for a transaction that redeems several atomic swap P2SH outputs.
+func EstimateBatchRedeemSerializeSize(contracts [][]byte, txOuts []*wire.TxOut) int {
+	return estimateBatchSerializeSize(contracts, redeemAtomicSwapSigScriptSize, txOuts)
+}
+
+// EstimateBatchRefundSerializeSize returns a worst case serialize size
+// estimate for a transaction that refunds several atomic swap P2SH outputs.
+func EstimateBatchRefundSerializeSize(contracts [][]byte, txOuts []*wire.TxOut) int {
+	return estimateBatchSerializeSize(contracts, refundAtomicSwapSigScriptSize, txOuts)
+}
+
+// estimateBatchSerializeSize returns a worst case serialize size estimate for
+// a transaction spending one atomic swap P2SH output per contract, each with a
+// signature script of sigScriptSize bytes excluding the contract push.
+func estimateBatchSerializeSize(contracts [][]byte, sigScriptSize int, txOuts []*wire.TxOut) int {
+	var inputsSize int
+	for _, contract := range contracts {
+		contractPush, err := txscript.NewScriptBuilder().AddData(contract).Script()
+		if err != nil {
+			// Should never be hit since this script does exceed the limits.
+			panic(err)
+		}
+		inputsSize += inputSize(sigScriptSize + len(contractPush))
+	}
+
+	// 12 additional bytes are for version, locktime and expiry.
+	return 12 + wire.VarIntSerializeSize(uint64(len(contracts))) +
+		wire.VarIntSerializeSize(uint64(len(txOuts))) +
+		inputsSize + sumOutputSerializeSizes(txOuts)
+}
+
+// EstimateContractSerializeSize returns a worst case serialize size estimate
+// for a transaction that pays a single atomic swap P2SH output from numInputs
+// P2PKH inputs, with a P2PKH change output when change is true.
+func EstimateContractSerializeSize(numInputs int, change bool) int {
+	numOutputs := 1
+	outputsSize := p2shOutputSize
+	if change {
+		numOutputs++
+		outputsSize += p2pkhOutputSize
+	}
+
+	// 12 additional bytes are for version, locktime and expiry.
+	return 12 + wire.VarIntSerializeSize(uint64(numInputs)) +
+		wire.VarIntSerializeSize(uint64(numOutputs)) +
+		numInputs*inputSize(redeemP2PKHSigScriptSize) +
+		outputsSize
+}
+
+// EstimateRedeemWitnessVSize returns a worst case virtual size estimate for a
+// transaction that redeems an atomic swap P2WSH output.
+func EstimateRedeemWitnessVSize(contract []byte, txOuts []*wire.TxOut) int {
+	return estimateBatchWitnessVSize([][]byte{contract}, redeemAtomicSwapWitnessSize, txOuts)
+}
+
+// EstimateRefundWitnessVSize returns a worst case virtual size estimate for a
+// transaction that refunds an atomic swap P2WSH output.
+func EstimateRefundWitnessVSize(contract []byte, txOuts []*wire.TxOut) int {
+	return estimateBatchWitnessVSize([][]byte{contract}, refundAtomicSwapWitnessSize, txOuts)
+}
+
+// EstimateBatchRedeemWitnessVSize returns a worst case virtual size estimate
+// for a transaction that redeems several atomic swap P2WSH outputs.
+func EstimateBatchRedeemWitnessVSize(contracts [][]byte, txOuts []*wire.TxOut) int {
+	return estimateBatchWitnessVSize(contracts, redeemAtomicSwapWitnessSize, txOuts)
+}
+
+// EstimateBatchRefundWitnessVSize returns a worst case virtual size estimate
+// for a transaction that refunds several atomic swap P2WSH outputs.
+func EstimateBatchRefundWitnessVSize(contracts [][]byte, txOuts []*wire.TxOut) int {
+	return estimateBatchWitnessVSize(contracts, refundAtomicSwapWitnessSize, txOuts)
+}
+
+// estimateBatchWitnessVSize returns a worst case virtual size estimate for a
+// transaction spending one atomic swap P2WSH output per contract, each with a
+// witness of witnessSize bytes excluding the contract item.
+func estimateBatchWitnessVSize(contracts [][]byte, witnessSize int, txOuts []*wire.TxOut) int {
+	// 8 bytes are for version and locktime.  The inputs have empty
+	// signature scripts.
+	baseSize := 8 + wire.VarIntSerializeSize(uint64(len(contracts))) +
+		wire.VarIntSerializeSize(uint64(len(txOuts))) +
+		len(contracts)*inputSize(0) + sumOutputSerializeSizes(txOuts)
+
+	// 2 bytes are for the segwit marker and flag.
+	witnessesSize := 2
+	for _, contract := range contracts {
+		witnessesSize += witnessSize +
+			wire.VarIntSerializeSize(uint64(len(contract))) + len(contract)
+	}
+
+	weight := baseSize*witnessScaleFactor + witnessesSize
+	return (weight + witnessScaleFactor - 1) / witnessScaleFactor
+}
+
+// VirtualSize returns the virtual size of tx, which fee rates of chains with
+// segregated witness apply to.  It is the weight of tx, three times its size
+// without witnesses plus its size with them, divided by four and rounded up.
+func VirtualSize(tx *wire.MsgTx) int {
+	weight := tx.SerializeSizeStripped()*(witnessScaleFactor-1) + tx.SerializeSize()
+	return (weight + witnessScaleFactor - 1) / witnessScaleFactor
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package cashaddr encodes and decodes Bitcoin Cash addresses in the cashaddr
+// format, such as bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a.
+package cashaddr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/bech32"
+)
+
+// Prefixes of the Bitcoin Cash networks.
+const (
+	MainNetPrefix = "bitcoincash"
+	TestNetPrefix = "bchtest"
+	RegTestPrefix = "bchreg"
+)
+
+// Address types.
+const (
+	P2PKH byte = 0
+	P2SH  byte = 1
+)
+
+// charset is the alphabet of the base32 encoded payload.
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// checksumLen is the number of base32 characters of the checksum.
+const checksumLen = 8
+
+// hashSizes are the hash sizes in bytes selected by the size bits of the
+// version byte.
+var hashSizes = [8]int{20, 24, 28, 32, 40, 48, 56, 64}
+
+// polymod is the BCH code generating the checksum.
+func polymod(values []byte) uint64 {
+	c := uint64(1)
+	for _, d := range values {
+		c0 := byte(c >> 35)
+		c = ((c & 0x07ffffffff) << 5) ^ uint64(d)
+		if c0&0x01 != 0 {
+			c ^= 0x98f2bc8e61
+		}
+		if c0&0x02 != 0 {
+			c ^= 0x79b76d99e2
+		}
+		if c0&0x04 != 0 {
+			c ^= 0xf33e5fb3c4
+		}
+		if c0&0x08 != 0 {
+			c ^= 0xae2eabe2a8
+		}
+		if c0&0x10 != 0 {
+			c ^= 0x1e4f43e470
+		}
+	}
+	return c ^ 1
+}
+
+// checksumInput returns the values the checksum of payload, in base32
+// values, is calculated over.
+func checksumInput(prefix string, payload []byte) []byte {
+	values := make([]byte, 0, len(prefix)+1+len(payload)+checksumLen)
+	for i := 0; i < len(prefix); i++ {
+		values = append(values, prefix[i]&0x1f)
+	}
+	values = append(values, 0)
+	return append(values, payload...)
+}
+
+// Encode returns the cashaddr address of type addrType for hash, including
+// the prefix.
+func Encode(prefix string, addrType byte, hash []byte) (string, error) {
+	sizeBits := -1
+	for i, size := range hashSizes {
+		if size == len(hash) {
+			sizeBits = i
+		}
+	}
+	if sizeBits < 0 {
+		return "", fmt.Errorf("invalid hash size %d", len(hash))
+	}
+	if addrType > 0x0f {
+		return "", fmt.Errorf("invalid address type %d", addrType)
+	}
+	prefix = strings.ToLower(prefix)
+
+	data := append([]byte{addrType<<3 | byte(sizeBits)}, hash...)
+	payload, err := bech32.ConvertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	mod := polymod(append(checksumInput(prefix, payload), make([]byte, checksumLen)...))
+	for i := 0; i < checksumLen; i++ {
+		payload = append(payload, byte(mod>>uint(5*(checksumLen-1-i)))&0x1f)
+	}
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	b.WriteByte(':')
+	for _, v := range payload {
+		b.WriteByte(charset[v])
+	}
+	return b.String(), nil
+}
+
+// Decode returns the prefix, type and hash of a cashaddr address.  The
+// address may omit the prefix, in which case defaultPrefix is assumed.
+func Decode(addr, defaultPrefix string) (prefix string, addrType byte, hash []byte, err error) {
+	if strings.ToLower(addr) != addr && strings.ToUpper(addr) != addr {
+		return "", 0, nil, errors.New("address uses mixed case")
+	}
+	addr = strings.ToLower(addr)
+	prefix = strings.ToLower(defaultPrefix)
+	if i := strings.LastIndexByte(addr, ':'); i >= 0 {
+		prefix, addr = addr[:i], addr[i+1:]
+	}
+	if prefix == "" {
+		return "", 0, nil, errors.New("address has no prefix")
+	}
+	if len(addr) <= checksumLen {
+		return "", 0, nil, errors.New("address is too short")
+	}
+
+	payload := make([]byte, len(addr))
+	for i := 0; i < len(addr); i++ {
+		v := strings.IndexByte(charset, addr[i])
+		if v < 0 {
+			return "", 0, nil, fmt.Errorf("invalid character %q", addr[i])
+		}
+		payload[i] = byte(v)
+	}
+	if polymod(checksumInput(prefix, payload)) != 0 {
+		return "", 0, nil, errors.New("invalid checksum")
+	}
+
+	data, err := bech32.ConvertBits(payload[:len(payload)-checksumLen], 5, 8, false)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	if len(data) == 0 {
+		return "", 0, nil, errors.New("address has no version byte")
+	}
+	version := data[0]
+	if version&0x80 != 0 {
+		return "", 0, nil, errors.New("invalid version byte")
+	}
+	hash = data[1:]
+	if len(hash) != hashSizes[version&0x07] {
+		return "", 0, nil, errors.New("hash size does not match the version byte")
+	}
+	return prefix, version >> 3, hash, nil
+}
+
+// EncodeAddress returns the cashaddr encoding of a P2PKH or P2SH address.
+func EncodeAddress(addr btcutil.Address, prefix string) (string, error) {
+	switch addr := addr.(type) {
+	case *btcutil.AddressPubKeyHash:
+		return Encode(prefix, P2PKH, addr.Hash160()[:])
+	case *btcutil.AddressScriptHash:
+		return Encode(prefix, P2SH, addr.Hash160()[:])
+	}
+	return "", fmt.Errorf("address %v can not be encoded as cashaddr", addr)
+}
+
+// DecodeAddress decodes a cashaddr address of the network with prefix and
+// returns it as the P2PKH or P2SH address of params.  The address may omit
+// the prefix.
+func DecodeAddress(addr, prefix string, params *chaincfg.Params) (btcutil.Address, error) {
+	addrPrefix, addrType, hash, err := Decode(addr, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if addrPrefix != strings.ToLower(prefix) {
+		return nil, fmt.Errorf("address is for the %s network, not %s", addrPrefix, prefix)
+	}
+	switch addrType {
+	case P2PKH:
+		return btcutil.NewAddressPubKeyHash(hash, params)
+	case P2SH:
+		return btcutil.NewAddressScriptHashFromHash(hash, params)
+	}
+	return nil, fmt.Errorf("unknown address type %d", addrType)
+}
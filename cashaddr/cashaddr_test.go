@@ -0,0 +1,120 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cashaddr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+)
+
+// The vectors of the cashaddr specification, and the legacy addresses of the
+// same hashes.
+var vectors = []struct {
+	addr     string
+	legacy   string
+	addrType byte
+	hash     string
+}{
+	{
+		addr:     "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a",
+		legacy:   "1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu",
+		addrType: P2PKH,
+		hash:     "76a04053bda0a88bda5177b86a15c3b29f559873",
+	},
+	{
+		addr:     "bitcoincash:ppm2qsznhks23z7629mms6s4cwef74vcwvn0h829pq",
+		legacy:   "3CWFddi6m4ndiGyKqzYvsFYagqDLPVMTzC",
+		addrType: P2SH,
+		hash:     "76a04053bda0a88bda5177b86a15c3b29f559873",
+	},
+	{
+		addr:     "bchtest:pr6m7j9njldwwzlg9v7v53unlr4jkmx6eyvwc0uz5t",
+		addrType: P2SH,
+		hash:     "f5bf48b397dae70be82b3cca4793f8eb2b6cdac9",
+	},
+}
+
+func TestEncodeDecode(t *testing.T) {
+	for _, v := range vectors {
+		hash, _ := hex.DecodeString(v.hash)
+		prefix := v.addr[:strings.IndexByte(v.addr, ':')]
+
+		addr, err := Encode(prefix, v.addrType, hash)
+		if err != nil {
+			t.Fatalf("%s: %v", v.addr, err)
+		}
+		if addr != v.addr {
+			t.Errorf("encoded %s, want %s", addr, v.addr)
+		}
+
+		// The prefix may be omitted and the address may be upper case.
+		for _, s := range []string{v.addr, v.addr[len(prefix)+1:], strings.ToUpper(v.addr)} {
+			gotPrefix, addrType, gotHash, err := Decode(s, prefix)
+			if err != nil {
+				t.Errorf("%s: %v", s, err)
+				continue
+			}
+			if gotPrefix != prefix || addrType != v.addrType || !bytes.Equal(gotHash, hash) {
+				t.Errorf("%s decoded to %s %d %x", s, gotPrefix, addrType, gotHash)
+			}
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		addr string
+	}{
+		{"checksum", "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6b"},
+		{"prefix", "bchtest:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"},
+		{"mixed case", "bitcoincash:Qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"},
+		{"character", "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6o"},
+		{"no prefix", "qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"},
+	}
+	for _, test := range tests {
+		_, _, _, err := Decode(test.addr, "")
+		if err == nil {
+			t.Errorf("%s: invalid address %s decoded", test.name, test.addr)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	for _, v := range vectors {
+		if v.legacy == "" {
+			continue
+		}
+		addr, err := DecodeAddress(v.addr, MainNetPrefix, &chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatalf("%s: %v", v.addr, err)
+		}
+		if addr.EncodeAddress() != v.legacy {
+			t.Errorf("%s decoded to %s, want %s", v.addr, addr.EncodeAddress(), v.legacy)
+		}
+		legacy, err := btcutil.DecodeAddress(v.legacy, &chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := EncodeAddress(legacy, MainNetPrefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != v.addr {
+			t.Errorf("%s encoded to %s, want %s", v.legacy, s, v.addr)
+		}
+	}
+
+	// Addresses of other networks are rejected.
+	_, err := DecodeAddress(vectors[2].addr, MainNetPrefix, &chaincfg.MainNetParams)
+	if err == nil {
+		t.Error("testnet address decoded for mainnet")
+	}
+}
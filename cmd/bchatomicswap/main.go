@@ -0,0 +1,17 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Command bchatomicswap performs atomic swaps of Bitcoin Cash with Electron
+// Cash.  It is btcatomicswap with the Bitcoin Cash definition of the coins
+// directory.
+package main
+
+import (
+	"github.com/robvanmieghem/electrumatomicswap/cmd/btcatomicswap/swapcli"
+	"github.com/robvanmieghem/electrumatomicswap/coins"
+)
+
+func main() {
+	swapcli.Main("bchatomicswap", coins.BitcoinCash)
+}
@@ -0,0 +1,191 @@
+package rpcclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// UnsupportedVersionError describes an Electrum daemon version the client has
+// no adapter for.
+type UnsupportedVersionError struct {
+	Version string
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("unsupported Electrum version %s (supported are "+
+		"versions 3.0 up to 4.x)", e.Version)
+}
+
+// adapter encodes requests and decodes responses for a range of Electrum
+// versions.  Responses are normalized to the shapes returned by the oldest
+// supported version, which are the ones decoded by the futures.
+type adapter struct {
+	// name describes the Electrum versions handled by the adapter.
+	name string
+
+	// namedParams sends every command with named parameters, instead of
+	// the parameter style the command was registered with.
+	namedParams bool
+
+	// normalize, when set, converts the result of a successful call of
+	// method to the shape returned by the oldest supported version.
+	normalize func(method string, result []byte) ([]byte, error)
+}
+
+var (
+	// electrum30 handles Electrum 3.0 and 3.1, whose responses are the
+	// ones decoded by the futures.
+	electrum30 = &adapter{name: "3.0-3.1"}
+
+	// electrum32 handles Electrum 3.2 and 3.3, which return the txid from
+	// broadcast instead of a [success, txid] array and report failures as
+	// errors.
+	electrum32 = &adapter{
+		name:      "3.2-3.3",
+		normalize: normalizeBroadcast,
+	}
+
+	// electrum4 handles Electrum 4, which prefers named parameters and
+	// returns bare serialized transactions from payto, a PSBT when the
+	// transaction is not signed.
+	electrum4 = &adapter{
+		name:        "4.x",
+		namedParams: true,
+		normalize: func(method string, result []byte) ([]byte, error) {
+			switch method {
+			case "payto", "paytomany":
+				return normalizePayTo(result)
+			}
+			return normalizeBroadcast(method, result)
+		},
+	}
+)
+
+// adapterForVersion returns the adapter for the passed Electrum version, or
+// an UnsupportedVersionError when there is none.
+func adapterForVersion(version string) (*adapter, error) {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return nil, &UnsupportedVersionError{version}
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, &UnsupportedVersionError{version}
+	}
+	// The minor version may carry a suffix, as in 4.0b1.
+	minor, err := strconv.Atoi(leadingDigits(parts[1]))
+	if err != nil {
+		return nil, &UnsupportedVersionError{version}
+	}
+
+	switch {
+	case major == 3 && minor < 2:
+		return electrum30, nil
+	case major == 3:
+		return electrum32, nil
+	case major == 4:
+		return electrum4, nil
+	}
+	return nil, &UnsupportedVersionError{version}
+}
+
+// leadingDigits returns the decimal digits s starts with.
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+// normalizeBroadcast converts a bare txid returned by broadcast to the
+// [true, txid] array of Electrum 3.0.
+func normalizeBroadcast(method string, result []byte) ([]byte, error) {
+	if method != "broadcast" {
+		return result, nil
+	}
+	var txID string
+	if json.Unmarshal(result, &txID) != nil {
+		return result, nil
+	}
+	return json.Marshal([]interface{}{true, txID})
+}
+
+// normalizePayTo converts the serialized transaction or PSBT returned by
+// payto to the object holding the transaction and whether it is complete
+// returned by Electrum 3.
+func normalizePayTo(result []byte) ([]byte, error) {
+	var serialized string
+	if json.Unmarshal(result, &serialized) != nil {
+		return result, nil
+	}
+
+	resp := struct {
+		Complete bool   `json:"complete"`
+		Final    bool   `json:"final"`
+		Hex      string `json:"hex"`
+	}{Final: true}
+	if _, err := hex.DecodeString(serialized); err == nil {
+		resp.Complete = true
+		resp.Hex = serialized
+		return json.Marshal(resp)
+	}
+
+	psbt, err := base64.StdEncoding.DecodeString(serialized)
+	if err != nil {
+		return nil, fmt.Errorf("unrecognized payto result %q", serialized)
+	}
+	unsignedTx, err := psbtUnsignedTx(psbt)
+	if err != nil {
+		return nil, err
+	}
+	resp.Hex = hex.EncodeToString(unsignedTx)
+	return json.Marshal(resp)
+}
+
+// psbtMagic are the bytes a serialized PSBT starts with.
+var psbtMagic = []byte{'p', 's', 'b', 't', 0xff}
+
+// psbtUnsignedTx returns the serialized unsigned transaction of a BIP 174
+// partially signed transaction.  Only the global map is decoded.
+func psbtUnsignedTx(psbt []byte) ([]byte, error) {
+	if !bytes.HasPrefix(psbt, psbtMagic) {
+		return nil, errors.New("invalid PSBT magic")
+	}
+	r := bytes.NewReader(psbt[len(psbtMagic):])
+	for {
+		keyLen, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PSBT: %v", err)
+		}
+		if keyLen == 0 {
+			// The separator ends the global map.
+			return nil, errors.New("PSBT has no unsigned transaction")
+		}
+		if keyLen > uint64(r.Len()) {
+			return nil, errors.New("invalid PSBT: key exceeds data")
+		}
+		key := make([]byte, keyLen)
+		_, err = io.ReadFull(r, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PSBT: %v", err)
+		}
+		value, err := wire.ReadVarBytes(r, 0, uint32(len(psbt)), "value")
+		if err != nil {
+			return nil, fmt.Errorf("invalid PSBT: %v", err)
+		}
+		// PSBT_GLOBAL_UNSIGNED_TX has key type 0 and no key data.
+		if len(key) == 1 && key[0] == 0 {
+			return value, nil
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package rpcclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestAdapterForVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		adapter *adapter
+	}{
+		{"3.0.6", electrum30},
+		{"3.1.3", electrum30},
+		{"3.2.3", electrum32},
+		{"3.3.8", electrum32},
+		{"4.0b1", electrum4},
+		{"4.0.9", electrum4},
+		{"4.1.5", electrum4},
+		{"2.9", nil},
+		{"2.9.3", nil},
+		{"5.0", nil},
+		{"4", nil},
+		{"4.b1", nil},
+		{"v4.1.5", nil},
+		{"", nil},
+		{"garbage", nil},
+	}
+	for _, test := range tests {
+		a, err := adapterForVersion(test.version)
+		if test.adapter == nil {
+			var versionErr *UnsupportedVersionError
+			if !errors.As(err, &versionErr) || versionErr.Version != test.version {
+				t.Errorf("%q: error is %v, want an unsupported version error", test.version, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.version, err)
+			continue
+		}
+		if a != test.adapter {
+			t.Errorf("%q: adapter for %s, want %s", test.version, a.name, test.adapter.name)
+		}
+	}
+}
+
+func TestNormalizeBroadcast(t *testing.T) {
+	const txID = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	tests := []struct {
+		name   string
+		method string
+		result string
+		want   string
+	}{
+		{"bare txid", "broadcast", `"` + txID + `"`, `[true,"` + txID + `"]`},
+		{"txid array", "broadcast", `[true,"` + txID + `"]`, `[true,"` + txID + `"]`},
+		{"rejection array", "broadcast", `[false,"TX decode failed"]`, `[false,"TX decode failed"]`},
+		{"other method", "getunusedaddress", `"` + txID + `"`, `"` + txID + `"`},
+	}
+	for _, test := range tests {
+		result, err := normalizeBroadcast(test.method, []byte(test.result))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if string(result) != test.want {
+			t.Errorf("%s: result is %s, want %s", test.name, result, test.want)
+		}
+	}
+}
+
+// testPSBT returns a PSBT with the passed global key-value pairs.
+func testPSBT(pairs ...[2][]byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(psbtMagic)
+	for _, pair := range pairs {
+		wire.WriteVarBytes(&buf, 0, pair[0])
+		wire.WriteVarBytes(&buf, 0, pair[1])
+	}
+	buf.WriteByte(0)
+	return buf.Bytes()
+}
+
+// testUnsignedTx returns a serialized transaction without signatures.
+func testUnsignedTx(t *testing.T) []byte {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1e5, []byte{0x51}))
+	var buf bytes.Buffer
+	err := tx.Serialize(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNormalizePayTo(t *testing.T) {
+	unsignedTx := testUnsignedTx(t)
+	txHex := hex.EncodeToString(unsignedTx)
+	psbt := base64.StdEncoding.EncodeToString(testPSBT([2][]byte{{0}, unsignedTx}))
+
+	type payToResult struct {
+		Complete bool   `json:"complete"`
+		Final    bool   `json:"final"`
+		Hex      string `json:"hex"`
+	}
+	tests := []struct {
+		name   string
+		result string
+		want   *payToResult
+	}{
+		{
+			name:   "signed transaction",
+			result: `"` + txHex + `"`,
+			want:   &payToResult{Complete: true, Final: true, Hex: txHex},
+		},
+		{
+			name:   "PSBT",
+			result: `"` + psbt + `"`,
+			want:   &payToResult{Final: true, Hex: txHex},
+		},
+		{
+			name:   "Electrum 3 object",
+			result: `{"complete":true,"final":false,"hex":"` + txHex + `"}`,
+			want:   &payToResult{Complete: true, Hex: txHex},
+		},
+		{
+			name:   "neither hex nor base64",
+			result: `"not a transaction"`,
+		},
+		{
+			name:   "base64 without PSBT magic",
+			result: `"` + base64.StdEncoding.EncodeToString([]byte("not a psbt")) + `"`,
+		},
+	}
+	for _, test := range tests {
+		// payto and paytomany are normalized alike by the adapter of
+		// Electrum 4.
+		for _, method := range []string{"payto", "paytomany"} {
+			result, err := electrum4.normalize(method, []byte(test.result))
+			if test.want == nil {
+				if err == nil {
+					t.Errorf("%s: %s result %s was accepted", test.name, method, result)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: %s: %v", test.name, method, err)
+				continue
+			}
+			var got payToResult
+			err = json.Unmarshal(result, &got)
+			if err != nil {
+				t.Errorf("%s: %s: %v", test.name, method, err)
+				continue
+			}
+			if got != *test.want {
+				t.Errorf("%s: %s result is %+v, want %+v", test.name, method, got, *test.want)
+			}
+		}
+	}
+}
+
+func TestPSBTUnsignedTx(t *testing.T) {
+	unsignedTx := testUnsignedTx(t)
+	valid := testPSBT([2][]byte{{0xfc, 0x01}, {0xaa}}, [2][]byte{{0}, unsignedTx})
+
+	tests := []struct {
+		name string
+		psbt []byte
+		err  bool
+	}{
+		{name: "unsigned transaction after a proprietary key", psbt: valid},
+		{name: "empty", psbt: nil, err: true},
+		{name: "invalid magic", psbt: append([]byte("psbu\xff"), valid[len(psbtMagic):]...), err: true},
+		{name: "magic only", psbt: psbtMagic, err: true},
+		{name: "truncated key", psbt: valid[:len(psbtMagic)+2], err: true},
+		{name: "truncated value", psbt: valid[:len(valid)-10], err: true},
+		{name: "no unsigned transaction", psbt: testPSBT([2][]byte{{0xfc, 0x01}, {0xaa}}), err: true},
+		{name: "key exceeding the data", psbt: append(append([]byte{}, psbtMagic...), 0xfd, 0xff, 0xff, 0), err: true},
+		{name: "value exceeding the data", psbt: append(append([]byte{}, psbtMagic...), 1, 0, 0xfe, 0xff, 0xff, 0xff, 0xff), err: true},
+	}
+	for _, test := range tests {
+		tx, err := psbtUnsignedTx(test.psbt)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: PSBT was accepted", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(tx, unsignedTx) {
+			t.Errorf("%s: unsigned transaction is %x, want %x", test.name, tx, unsignedTx)
+		}
+	}
+}
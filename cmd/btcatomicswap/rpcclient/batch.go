@@ -0,0 +1,223 @@
+package rpcclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+)
+
+// errBatchRejected is returned for a batch request the server did not answer
+// with a JSON-RPC batch response, which is taken to mean batches are not
+// supported.
+type errBatchRejected struct {
+	statusCode int
+	response   []byte
+}
+
+func (e *errBatchRejected) Error() string {
+	return fmt.Sprintf("batch request rejected: status code: %d, response: %q",
+		e.statusCode, string(e.response))
+}
+
+// batchResult returns the raw response array of a batch request, or an
+// errBatchRejected error when the server did not answer with an array.
+func batchResult(statusCode int, respBytes []byte) (result []byte, retry bool, err error) {
+	trimmed := bytes.TrimSpace(respBytes)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return nil, false, &errBatchRejected{statusCode, respBytes}
+	}
+	return respBytes, false, nil
+}
+
+// Batch collects commands to send to the server in a single JSON-RPC batch
+// request.  The futures returned when queueing commands are resolved
+// individually once Send is called.  A Batch must not be used concurrently.
+//
+// When the server rejects batch requests, which older Electrum daemons do,
+// the commands are sent as individual requests instead and the client no
+// longer attempts batches.
+type Batch struct {
+	client   *Client
+	ctx      context.Context
+	requests []*jsonRequest
+}
+
+// NewBatch returns an empty batch of commands for the client.
+func (c *Client) NewBatch() *Batch {
+	return c.NewBatchContext(context.Background())
+}
+
+// NewBatchContext returns an empty batch of commands for the client.  The
+// batch is canceled and the futures return the context error when ctx is
+// done.
+func (c *Client) NewBatchContext(ctx context.Context) *Batch {
+	return &Batch{client: c, ctx: ctx}
+}
+
+// Len returns the number of commands queued in the batch.
+func (b *Batch) Len() int {
+	return len(b.requests)
+}
+
+// queue adds the passed command to the batch and returns the channel on which
+// its response is delivered after Send.
+func (b *Batch) queue(cmd interface{}) chan *response {
+	jReq, err := b.client.newJSONRequest(b.ctx, cmd)
+	if err != nil {
+		return newFutureError(err)
+	}
+	b.requests = append(b.requests, jReq)
+	return withContext(b.ctx, jReq.responseChan)
+}
+
+// GetTransactionAsync queues a gettransaction command.
+//
+// See Client.GetTransaction for more details.
+func (b *Batch) GetTransactionAsync(txHash *chainhash.Hash) FutureGetTransactionResult {
+	cmd := NewGetTransactionCmd(txHash)
+	cmd.Wallet = b.client.wallet()
+	return b.queue(cmd)
+}
+
+// GetAddressHistoryAsync queues a getaddresshistory command.
+//
+// See Client.GetAddressHistory for more details.
+func (b *Batch) GetAddressHistoryAsync(address btcutil.Address) FutureGetAddressHistoryResult {
+	return b.queue(NewGetAddressHistoryCmd(address))
+}
+
+// ListUnspentAsync queues a listunspent command.
+//
+// See Client.ListUnspent for more details.
+func (b *Batch) ListUnspentAsync() FutureListUnspentResult {
+	cmd := NewListUnspentCmd()
+	cmd.Wallet = b.client.wallet()
+	return b.queue(cmd)
+}
+
+// GetBalanceAsync queues a getbalance command.
+//
+// See Client.GetBalance for more details.
+func (b *Batch) GetBalanceAsync() FutureGetBalanceResult {
+	cmd := NewGetBalanceCmd()
+	cmd.Wallet = b.client.wallet()
+	return b.queue(cmd)
+}
+
+// GetInfoAsync queues a getinfo command.
+//
+// See Client.GetInfo for more details.
+func (b *Batch) GetInfoAsync() FutureGetInfoResult {
+	return b.queue(NewGetInfoCmd())
+}
+
+// Send sends the queued commands and resolves their futures.  An error is
+// returned when the batch as a whole failed, in which case every future
+// returns it as well.  Errors of individual commands are only returned by
+// their futures.  The batch is empty after Send returns.
+func (b *Batch) Send() error {
+	requests := b.requests
+	b.requests = nil
+	if len(requests) == 0 {
+		return nil
+	}
+
+	c := b.client
+	if len(requests) == 1 || atomic.LoadInt32(&c.noBatch) != 0 {
+		b.sendIndividually(requests)
+		return nil
+	}
+
+	for _, jReq := range requests {
+		err := c.marshalRequest(jReq)
+		if err != nil {
+			for _, jReq := range requests {
+				jReq.responseChan <- &response{err: err}
+			}
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, jReq := range requests {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(jReq.marshalledJSON)
+	}
+	buf.WriteByte(']')
+
+	batchReq := &jsonRequest{
+		ctx:            b.ctx,
+		id:             c.NextID(),
+		method:         "batch",
+		marshalledJSON: buf.Bytes(),
+		responseChan:   make(chan *response, 1),
+		batch:          true,
+	}
+	c.sendPost(batchReq)
+	r := <-withContext(b.ctx, batchReq.responseChan)
+
+	if _, ok := r.err.(*errBatchRejected); ok {
+		log.Debugf("Falling back to individual requests: %v", r.err)
+		atomic.StoreInt32(&c.noBatch, 1)
+		b.sendIndividually(requests)
+		return nil
+	}
+	if r.err != nil {
+		for _, jReq := range requests {
+			jReq.responseChan <- &response{err: r.err}
+		}
+		return r.err
+	}
+
+	var responses []struct {
+		ID *uint64 `json:"id"`
+		rawResponse
+	}
+	err := json.Unmarshal(r.result, &responses)
+	if err != nil {
+		err = fmt.Errorf("invalid batch response: %v", err)
+		for _, jReq := range requests {
+			jReq.responseChan <- &response{err: err}
+		}
+		return err
+	}
+
+	pending := make(map[uint64]*jsonRequest, len(requests))
+	for _, jReq := range requests {
+		pending[jReq.id] = jReq
+	}
+	for _, resp := range responses {
+		if resp.ID == nil {
+			continue
+		}
+		jReq, ok := pending[*resp.ID]
+		if !ok {
+			continue
+		}
+		delete(pending, *resp.ID)
+		res, err := c.decodeResult(jReq.method, resp.rawResponse)
+		jReq.responseChan <- &response{result: res, err: err, client: c}
+	}
+	for id, jReq := range pending {
+		jReq.responseChan <- &response{
+			err: fmt.Errorf("no response for request %d in batch", id),
+		}
+	}
+	return nil
+}
+
+// sendIndividually sends each of the passed requests on its own.  They are
+// dispatched by the workers of the client like any other request.
+func (b *Batch) sendIndividually(requests []*jsonRequest) {
+	for _, jReq := range requests {
+		b.client.sendPost(jReq)
+	}
+}
@@ -2,6 +2,7 @@ package rpcclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -23,23 +24,34 @@ type FutureGetUnusedAddressResult chan *response
 // Receive waits for the response promised by the future and returns a new
 // address.
 func (r FutureGetUnusedAddressResult) Receive() (btcutil.Address, error) {
-	res, err := receiveFuture(r)
-	if err != nil {
-		return nil, err
+	resp := <-r
+	if resp.err != nil {
+		return nil, resp.err
 	}
 
 	// Unmarshal result as a string.
 	var addr string
-	err = json.Unmarshal(res, &addr)
+	err := json.Unmarshal(resp.result, &addr)
 	if err != nil {
 		return nil, err
 	}
 
-	return btcutil.DecodeAddress(addr, &chaincfg.MainNetParams)
+	return resp.decodeAddress(addr)
+}
+
+// decodeAddress decodes an address returned by the daemon, which may be a
+// mainnet or testnet address.
+func decodeAddress(addr string) (btcutil.Address, error) {
+	a, err := btcutil.DecodeAddress(addr, &chaincfg.MainNetParams)
+	if err != nil {
+		return btcutil.DecodeAddress(addr, &chaincfg.TestNet3Params)
+	}
+	return a, nil
 }
 
 // GetUnusedAddressCmd defines the getunusedaddress JSON-RPC command.
 type GetUnusedAddressCmd struct {
+	Wallet *string `json:"wallet,omitempty"`
 }
 
 // NewGetUnusedAddressCmd returns a new instance which can be used to issue a
@@ -54,8 +66,15 @@ func NewGetUnusedAddressCmd() *GetUnusedAddressCmd {
 //
 // See GetUnusedAddress for the blocking version and more details.
 func (c *Client) GetUnusedAddressAsync() FutureGetUnusedAddressResult {
+	return c.GetUnusedAddressAsyncContext(context.Background())
+}
+
+// GetUnusedAddressAsyncContext is like GetUnusedAddressAsync but the request is
+// canceled and the future returns the context error when ctx is done.
+func (c *Client) GetUnusedAddressAsyncContext(ctx context.Context) FutureGetUnusedAddressResult {
 	cmd := NewGetUnusedAddressCmd()
-	return c.sendCmd(cmd)
+	cmd.Wallet = c.wallet()
+	return c.sendCmdContext(ctx, cmd)
 }
 
 // GetUnusedAddress returns the first unused address of the wallet,
@@ -66,6 +85,12 @@ func (c *Client) GetUnusedAddress() (btcutil.Address, error) {
 	return c.GetUnusedAddressAsync().Receive()
 }
 
+// GetUnusedAddressContext is like GetUnusedAddress but gives up and returns the
+// context error when ctx is done.
+func (c *Client) GetUnusedAddressContext(ctx context.Context) (btcutil.Address, error) {
+	return c.GetUnusedAddressAsyncContext(ctx).Receive()
+}
+
 // FutureDumpPrivKeyResult is a future promise to deliver the result of a
 // DumpPrivKeyAsync RPC invocation (or an applicable error).
 type FutureDumpPrivKeyResult chan *response
@@ -74,20 +99,22 @@ type FutureDumpPrivKeyResult chan *response
 // key corresponding to the passed address encoded in the wallet import format
 // (WIF)
 func (r FutureDumpPrivKeyResult) Receive() (*btcutil.WIF, error) {
-	res, err := receiveFuture(r)
-	if err != nil {
-		return nil, err
+	resp := <-r
+	if resp.err != nil {
+		return nil, resp.err
 	}
 
 	// Unmarshal result as a string.
 	var rawprivKeyWIF string
-	err = json.Unmarshal(res, &rawprivKeyWIF)
+	err := json.Unmarshal(resp.result, &rawprivKeyWIF)
 	if err != nil {
 		return nil, err
 	}
-	//Drop the "p2pkh:" prefix
-	rawprivKeyWIF = strings.TrimPrefix(rawprivKeyWIF, "p2pkh:")
-	return btcutil.DecodeWIF(rawprivKeyWIF)
+	//Drop the script type prefix, such as "p2pkh:" or "p2wpkh:"
+	if i := strings.Index(rawprivKeyWIF, ":"); i != -1 {
+		rawprivKeyWIF = rawprivKeyWIF[i+1:]
+	}
+	return resp.decodeWIF(rawprivKeyWIF)
 }
 
 // DumpPrivKeyAsync returns an instance of a type that can be used to get the
@@ -96,9 +123,17 @@ func (r FutureDumpPrivKeyResult) Receive() (*btcutil.WIF, error) {
 //
 // See DumpPrivKey for the blocking version and more details.
 func (c *Client) DumpPrivKeyAsync(address btcutil.Address) FutureDumpPrivKeyResult {
-	addr := address.EncodeAddress()
+	return c.DumpPrivKeyAsyncContext(context.Background(), address)
+}
+
+// DumpPrivKeyAsyncContext is like DumpPrivKeyAsync but the request is canceled
+// and the future returns the context error when ctx is done.
+func (c *Client) DumpPrivKeyAsyncContext(ctx context.Context, address btcutil.Address) FutureDumpPrivKeyResult {
+	addr := c.encodeAddress(address)
 	cmd := NewGetPrivateKeysCmd(addr)
-	return c.sendCmd(cmd)
+	cmd.Password = c.walletPass()
+	cmd.Wallet = c.wallet()
+	return c.sendCmdContext(ctx, cmd)
 }
 
 // DumpPrivKey gets the private key corresponding to the passed address encoded
@@ -108,9 +143,17 @@ func (c *Client) DumpPrivKey(address btcutil.Address) (*btcutil.WIF, error) {
 	return c.DumpPrivKeyAsync(address).Receive()
 }
 
+// DumpPrivKeyContext is like DumpPrivKey but gives up and returns the context
+// error when ctx is done.
+func (c *Client) DumpPrivKeyContext(ctx context.Context, address btcutil.Address) (*btcutil.WIF, error) {
+	return c.DumpPrivKeyAsyncContext(ctx, address).Receive()
+}
+
 // GetPrivateKeysCmd defines the getprivatekeys JSON-RPC command.
 type GetPrivateKeysCmd struct {
-	Address string
+	Address  string  `json:"address"`
+	Password *string `json:"password,omitempty"`
+	Wallet   *string `json:"wallet,omitempty"`
 }
 
 // NewGetPrivateKeysCmd returns a new instance which can be used to issue a
@@ -144,6 +187,8 @@ func (r FutureGetFeeRateResult) Receive() (feerate btcutil.Amount, err error) {
 
 // GetFeeRateCmd defines the getfeerate RPC command.
 type GetFeeRateCmd struct {
+	FeeMethod *string  `json:"fee_method,omitempty"`
+	FeeLevel  *float64 `json:"fee_level,omitempty"`
 }
 
 // NewGetFeeRateCmd returns a new instance which can be used to issue a
@@ -152,14 +197,30 @@ func NewGetFeeRateCmd() *GetFeeRateCmd {
 	return &GetFeeRateCmd{}
 }
 
+// NewEstimateFeeRateCmd returns a new instance which can be used to issue a
+// getfeerate JSON-RPC command for an explicit fee estimation method ("static",
+// "eta" or "mempool") and fee level between 0 and 1.
+func NewEstimateFeeRateCmd(feeMethod string, feeLevel float64) *GetFeeRateCmd {
+	return &GetFeeRateCmd{
+		FeeMethod: &feeMethod,
+		FeeLevel:  &feeLevel,
+	}
+}
+
 // GetFeeRateAsync returns an instance of a type that can be used to
 // get the result of the RPC at some future time by invoking the Receive
 // function on the returned instance.
 //
 // See GetUnusedAddress for the blocking version and more details.
 func (c *Client) GetFeeRateAsync() FutureGetFeeRateResult {
+	return c.GetFeeRateAsyncContext(context.Background())
+}
+
+// GetFeeRateAsyncContext is like GetFeeRateAsync but the request is canceled
+// and the future returns the context error when ctx is done.
+func (c *Client) GetFeeRateAsyncContext(ctx context.Context) FutureGetFeeRateResult {
 	cmd := NewGetFeeRateCmd()
-	return c.sendCmd(cmd)
+	return c.sendCmdContext(ctx, cmd)
 }
 
 // GetFeeRate Returns the  current optimal fee rate per kilobyte, according to config settings(static/dynamic)returns the first unused address of the wallet,
@@ -167,6 +228,40 @@ func (c *Client) GetFeeRate() (btcutil.Amount, error) {
 	return c.GetFeeRateAsync().Receive()
 }
 
+// GetFeeRateContext is like GetFeeRate but gives up and returns the context
+// error when ctx is done.
+func (c *Client) GetFeeRateContext(ctx context.Context) (btcutil.Amount, error) {
+	return c.GetFeeRateAsyncContext(ctx).Receive()
+}
+
+// EstimateFeeRateAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See EstimateFeeRate for the blocking version and more details.
+func (c *Client) EstimateFeeRateAsync(feeMethod string, feeLevel float64) FutureGetFeeRateResult {
+	return c.EstimateFeeRateAsyncContext(context.Background(), feeMethod, feeLevel)
+}
+
+// EstimateFeeRateAsyncContext is like EstimateFeeRateAsync but the request is
+// canceled and the future returns the context error when ctx is done.
+func (c *Client) EstimateFeeRateAsyncContext(ctx context.Context, feeMethod string, feeLevel float64) FutureGetFeeRateResult {
+	cmd := NewEstimateFeeRateCmd(feeMethod, feeLevel)
+	return c.sendCmdContext(ctx, cmd)
+}
+
+// EstimateFeeRate returns the fee rate per kilobyte for the passed fee
+// estimation method and fee level, regardless of the wallet configuration.
+func (c *Client) EstimateFeeRate(feeMethod string, feeLevel float64) (btcutil.Amount, error) {
+	return c.EstimateFeeRateAsync(feeMethod, feeLevel).Receive()
+}
+
+// EstimateFeeRateContext is like EstimateFeeRate but gives up and returns the
+// context error when ctx is done.
+func (c *Client) EstimateFeeRateContext(ctx context.Context, feeMethod string, feeLevel float64) (btcutil.Amount, error) {
+	return c.EstimateFeeRateAsyncContext(ctx, feeMethod, feeLevel).Receive()
+}
+
 // FuturePayToResult is a future promise to deliver the result of
 // a payto  RPC invocation (or an applicable error).
 type FuturePayToResult chan *response
@@ -204,34 +299,127 @@ func (r FuturePayToResult) Receive() (tx *wire.MsgTx, complete bool, err error)
 
 // PayToCmd defines the payto RPC command.
 type PayToCmd struct {
-	Destination string  `json:"destination"`
-	Amount      float64 `json:"amount"`
-	UnSigned    bool    `json:"unsigned"`
+	Destination string   `json:"destination"`
+	Amount      float64  `json:"amount"`
+	FeeRate     *float64 `json:"feerate,omitempty"`
+	UnSigned    bool     `json:"unsigned"`
+	Password    *string  `json:"password,omitempty"`
+	Wallet      *string  `json:"wallet,omitempty"`
 }
 
 // NewPayToCmd returns a new instance which can be used to issue a
-// payto JSON-RPC command.
-func NewPayToCmd(destination btcutil.Address, amount btcutil.Amount, unsigned bool) *PayToCmd {
+// payto JSON-RPC command.  A zero feePerKb leaves the fee to the wallet
+// configuration.
+func NewPayToCmd(destination btcutil.Address, amount, feePerKb btcutil.Amount, unsigned bool) *PayToCmd {
 	return &PayToCmd{
 		Destination: destination.EncodeAddress(),
 		Amount:      amount.ToBTC(),
+		FeeRate:     feeRateParam(feePerKb),
 		UnSigned:    unsigned,
 	}
 }
 
+// feeRateParam converts a fee rate per kilobyte to the satoshi per byte
+// feerate parameter of the payto commands, or nil when feePerKb is zero.
+func feeRateParam(feePerKb btcutil.Amount) *float64 {
+	if feePerKb == 0 {
+		return nil
+	}
+	feeRate := float64(feePerKb) / 1000
+	return &feeRate
+}
+
 // PayToAsync returns an instance of a type that can be used to
 // get the result of the RPC at some future time by invoking the Receive
 // function on the returned instance.
 //
 // See PayTo for the blocking version and more details.
-func (c *Client) PayToAsync(destination btcutil.Address, amount btcutil.Amount, unsigned bool) FuturePayToResult {
-	cmd := NewPayToCmd(destination, amount, unsigned)
-	return c.sendCmd(cmd)
+func (c *Client) PayToAsync(destination btcutil.Address, amount, feePerKb btcutil.Amount, unsigned bool) FuturePayToResult {
+	return c.PayToAsyncContext(context.Background(), destination, amount, feePerKb, unsigned)
 }
 
-// PayTo returns a funded transaction
-func (c *Client) PayTo(destination btcutil.Address, amount btcutil.Amount, unsigned bool) (tx *wire.MsgTx, complete bool, err error) {
-	return c.PayToAsync(destination, amount, unsigned).Receive()
+// PayToAsyncContext is like PayToAsync but the request is canceled and the
+// future returns the context error when ctx is done.
+func (c *Client) PayToAsyncContext(ctx context.Context, destination btcutil.Address, amount, feePerKb btcutil.Amount, unsigned bool) FuturePayToResult {
+	cmd := NewPayToCmd(destination, amount, feePerKb, unsigned)
+	cmd.Destination = c.encodeAddress(destination)
+	cmd.Password = c.walletPass()
+	cmd.Wallet = c.wallet()
+	return c.sendCmdContext(ctx, cmd)
+}
+
+// PayTo returns a funded transaction paying at the fee rate feePerKb, or at
+// the rate configured in the wallet when feePerKb is zero.
+func (c *Client) PayTo(destination btcutil.Address, amount, feePerKb btcutil.Amount, unsigned bool) (tx *wire.MsgTx, complete bool, err error) {
+	return c.PayToAsync(destination, amount, feePerKb, unsigned).Receive()
+}
+
+// PayToContext is like PayTo but gives up and returns the context error when
+// ctx is done.
+func (c *Client) PayToContext(ctx context.Context, destination btcutil.Address, amount, feePerKb btcutil.Amount, unsigned bool) (tx *wire.MsgTx, complete bool, err error) {
+	return c.PayToAsyncContext(ctx, destination, amount, feePerKb, unsigned).Receive()
+}
+
+// PayToManyCmd defines the paytomany RPC command.
+type PayToManyCmd struct {
+	Outputs  [][]interface{} `json:"outputs"`
+	FeeRate  *float64        `json:"feerate,omitempty"`
+	UnSigned bool            `json:"unsigned"`
+	Password *string         `json:"password,omitempty"`
+	Wallet   *string         `json:"wallet,omitempty"`
+}
+
+// NewPayToManyCmd returns a new instance which can be used to issue a
+// paytomany JSON-RPC command.  A zero feePerKb leaves the fee to the wallet
+// configuration.
+func NewPayToManyCmd(amounts map[btcutil.Address]btcutil.Amount, feePerKb btcutil.Amount, unsigned bool) *PayToManyCmd {
+	return &PayToManyCmd{
+		Outputs:  payToManyOutputs(amounts, btcutil.Address.EncodeAddress),
+		FeeRate:  feeRateParam(feePerKb),
+		UnSigned: unsigned,
+	}
+}
+
+// payToManyOutputs returns the outputs parameter of the paytomany command,
+// encoding the destination addresses with encode.
+func payToManyOutputs(amounts map[btcutil.Address]btcutil.Amount, encode func(btcutil.Address) string) [][]interface{} {
+	outputs := make([][]interface{}, 0, len(amounts))
+	for destination, amount := range amounts {
+		outputs = append(outputs, []interface{}{encode(destination), amount.ToBTC()})
+	}
+	return outputs
+}
+
+// PayToManyAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See PayToMany for the blocking version and more details.
+func (c *Client) PayToManyAsync(amounts map[btcutil.Address]btcutil.Amount, feePerKb btcutil.Amount, unsigned bool) FuturePayToResult {
+	return c.PayToManyAsyncContext(context.Background(), amounts, feePerKb, unsigned)
+}
+
+// PayToManyAsyncContext is like PayToManyAsync but the request is canceled and
+// the future returns the context error when ctx is done.
+func (c *Client) PayToManyAsyncContext(ctx context.Context, amounts map[btcutil.Address]btcutil.Amount, feePerKb btcutil.Amount, unsigned bool) FuturePayToResult {
+	cmd := NewPayToManyCmd(amounts, feePerKb, unsigned)
+	cmd.Outputs = payToManyOutputs(amounts, c.encodeAddress)
+	cmd.Password = c.walletPass()
+	cmd.Wallet = c.wallet()
+	return c.sendCmdContext(ctx, cmd)
+}
+
+// PayToMany returns a single funded transaction paying each of the passed
+// destination addresses the associated amount at the fee rate feePerKb, or at
+// the rate configured in the wallet when feePerKb is zero.
+func (c *Client) PayToMany(amounts map[btcutil.Address]btcutil.Amount, feePerKb btcutil.Amount, unsigned bool) (tx *wire.MsgTx, complete bool, err error) {
+	return c.PayToManyAsync(amounts, feePerKb, unsigned).Receive()
+}
+
+// PayToManyContext is like PayToMany but gives up and returns the context error
+// when ctx is done.
+func (c *Client) PayToManyContext(ctx context.Context, amounts map[btcutil.Address]btcutil.Amount, feePerKb btcutil.Amount, unsigned bool) (tx *wire.MsgTx, complete bool, err error) {
+	return c.PayToManyAsyncContext(ctx, amounts, feePerKb, unsigned).Receive()
 }
 
 //UnspentOutput represents an unspent output
@@ -244,6 +432,7 @@ type UnspentOutput struct {
 
 // ListUnspentCmd defines the listunspent RPC command.
 type ListUnspentCmd struct {
+	Wallet *string `json:"wallet,omitempty"`
 }
 
 // NewListUnspentCmd returns a new instance which can be used to issue a
@@ -258,10 +447,11 @@ type FutureListUnspentResult chan *response
 
 // Receive waits for the response promised by the future and returns the decode unspent outputs.
 func (r FutureListUnspentResult) Receive() (utxos []*UnspentOutput, err error) {
-	rawResp, err := receiveFuture(r)
-	if err != nil {
-		return
+	resp := <-r
+	if resp.err != nil {
+		return nil, resp.err
 	}
+	rawResp := resp.result
 	type respUtxo struct {
 		Address     string `json:"address"`
 		Value       string `json:"value"`
@@ -270,14 +460,14 @@ func (r FutureListUnspentResult) Receive() (utxos []*UnspentOutput, err error) {
 		Height      int64  `json:"height"`
 		Coinbase    bool   `json:"coinbase"`
 	}
-	var resp []respUtxo
+	var respUtxos []respUtxo
 	// Unmarshal result
-	err = json.Unmarshal(rawResp, &resp)
+	err = json.Unmarshal(rawResp, &respUtxos)
 	if err != nil {
 		return
 	}
-	utxos = make([]*UnspentOutput, len(resp))
-	for i, respUtxo := range resp {
+	utxos = make([]*UnspentOutput, len(respUtxos))
+	for i, respUtxo := range respUtxos {
 		utxo := &UnspentOutput{
 			Height: respUtxo.Height,
 		}
@@ -289,15 +479,15 @@ func (r FutureListUnspentResult) Receive() (utxos []*UnspentOutput, err error) {
 		if err != nil {
 			return nil, err
 		}
-		utxo.Address, err = btcutil.DecodeAddress(respUtxo.Address, nil)
-		hash, err := chainhash.NewHashFromStr(respUtxo.PrevoutHash)
+		utxo.Address, err = resp.decodeAddress(respUtxo.Address)
 		if err != nil {
 			return nil, err
 		}
-		utxo.OutPoint = wire.NewOutPoint(hash, respUtxo.PrevoutN)
+		hash, err := chainhash.NewHashFromStr(respUtxo.PrevoutHash)
 		if err != nil {
 			return nil, err
 		}
+		utxo.OutPoint = wire.NewOutPoint(hash, respUtxo.PrevoutN)
 		utxos[i] = utxo
 	}
 
@@ -310,8 +500,15 @@ func (r FutureListUnspentResult) Receive() (utxos []*UnspentOutput, err error) {
 //
 // See ListUnspent for the blocking version and more details.
 func (c *Client) ListUnspentAsync() FutureListUnspentResult {
+	return c.ListUnspentAsyncContext(context.Background())
+}
+
+// ListUnspentAsyncContext is like ListUnspentAsync but the request is canceled
+// and the future returns the context error when ctx is done.
+func (c *Client) ListUnspentAsyncContext(ctx context.Context) FutureListUnspentResult {
 	cmd := NewListUnspentCmd()
-	return c.sendCmd(cmd)
+	cmd.Wallet = c.wallet()
+	return c.sendCmdContext(ctx, cmd)
 }
 
 //ListUnspent returns the list of unspent transaction outputs in the
@@ -320,6 +517,12 @@ func (c *Client) ListUnspent() ([]*UnspentOutput, error) {
 	return c.ListUnspentAsync().Receive()
 }
 
+// ListUnspentContext is like ListUnspent but gives up and returns the context
+// error when ctx is done.
+func (c *Client) ListUnspentContext(ctx context.Context) ([]*UnspentOutput, error) {
+	return c.ListUnspentAsyncContext(ctx).Receive()
+}
+
 // FutureBroadcastResult is a future promise to deliver the result of
 // a broadcast RPC invocation (or an applicable error).
 type FutureBroadcastResult chan *response
@@ -327,6 +530,9 @@ type FutureBroadcastResult chan *response
 // Receive waits for the response promised by the future and returns a the feerate.
 func (r FutureBroadcastResult) Receive() (*chainhash.Hash, error) {
 	rawResponse, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
 	var resp []interface{}
 	err = json.Unmarshal(rawResponse, &resp)
 	if err != nil {
@@ -335,6 +541,12 @@ func (r FutureBroadcastResult) Receive() (*chainhash.Hash, error) {
 	if len(resp) < 2 {
 		return nil, fmt.Errorf("Invalid response: %s", string(rawResponse))
 	}
+	// Electrum reports a rejected transaction as [false, <reason>] instead
+	// of a JSON-RPC error.
+	if success, ok := resp[0].(bool); ok && !success {
+		reason, _ := resp[1].(string)
+		return nil, &RPCError{Message: reason}
+	}
 	txID, ok := resp[1].(string)
 	if !ok {
 		return nil, fmt.Errorf("Invalid response: %s", string(rawResponse))
@@ -344,7 +556,7 @@ func (r FutureBroadcastResult) Receive() (*chainhash.Hash, error) {
 
 // BroadcastCmd defines the  broadcast RPC command.
 type BroadcastCmd struct {
-	SerializedTransaction string
+	SerializedTransaction string `json:"tx"`
 }
 
 // NewBroadcastCmd returns a new instance which can be used to issue a
@@ -370,8 +582,14 @@ func NewBroadcastCmd(tx *wire.MsgTx) (cmd *BroadcastCmd) {
 //
 // See Broadcast for the blocking version and more details.
 func (c *Client) BroadcastAsync(tx *wire.MsgTx) FutureBroadcastResult {
+	return c.BroadcastAsyncContext(context.Background(), tx)
+}
+
+// BroadcastAsyncContext is like BroadcastAsync but the request is canceled and
+// the future returns the context error when ctx is done.
+func (c *Client) BroadcastAsyncContext(ctx context.Context, tx *wire.MsgTx) FutureBroadcastResult {
 	cmd := NewBroadcastCmd(tx)
-	return c.sendCmd(cmd)
+	return c.sendCmdContext(ctx, cmd)
 }
 
 //Broadcast a transaction to the network
@@ -380,6 +598,604 @@ func (c *Client) Broadcast(tx *wire.MsgTx) (*chainhash.Hash, error) {
 	return c.BroadcastAsync(tx).Receive()
 }
 
+// BroadcastContext is like Broadcast but gives up and returns the context error
+// when ctx is done.
+func (c *Client) BroadcastContext(ctx context.Context, tx *wire.MsgTx) (*chainhash.Hash, error) {
+	return c.BroadcastAsyncContext(ctx, tx).Receive()
+}
+
+// FutureVersionResult is a future promise to deliver the result of a
+// version RPC invocation (or an applicable error).
+type FutureVersionResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// version of the Electrum daemon.
+func (r FutureVersionResult) Receive() (string, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return "", err
+	}
+
+	var version string
+	err = json.Unmarshal(res, &version)
+	return version, err
+}
+
+// VersionCmd defines the version JSON-RPC command.
+type VersionCmd struct {
+}
+
+// NewVersionCmd returns a new instance which can be used to issue a
+// version JSON-RPC command.
+func NewVersionCmd() *VersionCmd {
+	return &VersionCmd{}
+}
+
+// VersionAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See Version for the blocking version and more details.
+func (c *Client) VersionAsync() FutureVersionResult {
+	return c.VersionAsyncContext(context.Background())
+}
+
+// VersionAsyncContext is like VersionAsync but the request is canceled and the
+// future returns the context error when ctx is done.
+func (c *Client) VersionAsyncContext(ctx context.Context) FutureVersionResult {
+	cmd := NewVersionCmd()
+	return c.sendCmdContext(ctx, cmd)
+}
+
+// Version returns the version of the Electrum daemon.
+func (c *Client) Version() (string, error) {
+	return c.VersionAsync().Receive()
+}
+
+// VersionContext is like Version but gives up and returns the context error
+// when ctx is done.
+func (c *Client) VersionContext(ctx context.Context) (string, error) {
+	return c.VersionAsyncContext(ctx).Receive()
+}
+
+// InfoResult models the data returned by the getinfo command.  Network is
+// only reported by recent Electrum versions.
+type InfoResult struct {
+	Path             string `json:"path"`
+	Server           string `json:"server"`
+	BlockchainHeight int64  `json:"blockchain_height"`
+	ServerHeight     int64  `json:"server_height"`
+	Connected        bool   `json:"connected"`
+	Version          string `json:"version"`
+	Network          string `json:"network"`
+}
+
+// FutureGetInfoResult is a future promise to deliver the result of a
+// getinfo RPC invocation (or an applicable error).
+type FutureGetInfoResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// network status of the Electrum daemon.
+func (r FutureGetInfoResult) Receive() (*InfoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var info InfoResult
+	err = json.Unmarshal(res, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetInfoCmd defines the getinfo JSON-RPC command.
+type GetInfoCmd struct {
+}
+
+// NewGetInfoCmd returns a new instance which can be used to issue a
+// getinfo JSON-RPC command.
+func NewGetInfoCmd() *GetInfoCmd {
+	return &GetInfoCmd{}
+}
+
+// GetInfoAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetInfo for the blocking version and more details.
+func (c *Client) GetInfoAsync() FutureGetInfoResult {
+	return c.GetInfoAsyncContext(context.Background())
+}
+
+// GetInfoAsyncContext is like GetInfoAsync but the request is canceled and the
+// future returns the context error when ctx is done.
+func (c *Client) GetInfoAsyncContext(ctx context.Context) FutureGetInfoResult {
+	cmd := NewGetInfoCmd()
+	return c.sendCmdContext(ctx, cmd)
+}
+
+// GetInfo returns the network status of the Electrum daemon, such as the
+// server it is connected to and the local and server block heights.
+func (c *Client) GetInfo() (*InfoResult, error) {
+	return c.GetInfoAsync().Receive()
+}
+
+// GetInfoContext is like GetInfo but gives up and returns the context error
+// when ctx is done.
+func (c *Client) GetInfoContext(ctx context.Context) (*InfoResult, error) {
+	return c.GetInfoAsyncContext(ctx).Receive()
+}
+
+// BalanceResult models the data returned by the getbalance command.
+type BalanceResult struct {
+	Confirmed   btcutil.Amount
+	Unconfirmed btcutil.Amount
+	Unmatured   btcutil.Amount
+}
+
+// FutureGetBalanceResult is a future promise to deliver the result of a
+// getbalance RPC invocation (or an applicable error).
+type FutureGetBalanceResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// balance of the wallet.
+func (r FutureGetBalanceResult) Receive() (*BalanceResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Confirmed   string `json:"confirmed"`
+		Unconfirmed string `json:"unconfirmed"`
+		Unmatured   string `json:"unmatured"`
+	}
+	err = json.Unmarshal(res, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := &BalanceResult{}
+	for _, field := range []struct {
+		value  string
+		amount *btcutil.Amount
+	}{
+		{resp.Confirmed, &balance.Confirmed},
+		{resp.Unconfirmed, &balance.Unconfirmed},
+		{resp.Unmatured, &balance.Unmatured},
+	} {
+		if field.value == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(field.value, 64)
+		if err != nil {
+			return nil, err
+		}
+		*field.amount, err = btcutil.NewAmount(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return balance, nil
+}
+
+// GetBalanceCmd defines the getbalance JSON-RPC command.
+type GetBalanceCmd struct {
+	Wallet *string `json:"wallet,omitempty"`
+}
+
+// NewGetBalanceCmd returns a new instance which can be used to issue a
+// getbalance JSON-RPC command.
+func NewGetBalanceCmd() *GetBalanceCmd {
+	return &GetBalanceCmd{}
+}
+
+// GetBalanceAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetBalance for the blocking version and more details.
+func (c *Client) GetBalanceAsync() FutureGetBalanceResult {
+	return c.GetBalanceAsyncContext(context.Background())
+}
+
+// GetBalanceAsyncContext is like GetBalanceAsync but the request is canceled
+// and the future returns the context error when ctx is done.
+func (c *Client) GetBalanceAsyncContext(ctx context.Context) FutureGetBalanceResult {
+	cmd := NewGetBalanceCmd()
+	cmd.Wallet = c.wallet()
+	return c.sendCmdContext(ctx, cmd)
+}
+
+// GetBalance returns the confirmed, unconfirmed and unmatured balance of the
+// wallet.
+func (c *Client) GetBalance() (*BalanceResult, error) {
+	return c.GetBalanceAsync().Receive()
+}
+
+// GetBalanceContext is like GetBalance but gives up and returns the context
+// error when ctx is done.
+func (c *Client) GetBalanceContext(ctx context.Context) (*BalanceResult, error) {
+	return c.GetBalanceAsyncContext(ctx).Receive()
+}
+
+// FutureFreezeUTXOResult is a future promise to deliver the result of a
+// freeze_utxo or unfreeze_utxo RPC invocation (or an applicable error).
+type FutureFreezeUTXOResult chan *response
+
+// Receive waits for the response promised by the future and returns whether
+// the wallet accepted the change of the frozen state.
+func (r FutureFreezeUTXOResult) Receive() (bool, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return false, err
+	}
+
+	var ok bool
+	err = json.Unmarshal(res, &ok)
+	return ok, err
+}
+
+// FreezeUTXOCmd defines the freeze_utxo JSON-RPC command.
+type FreezeUTXOCmd struct {
+	Coin   string  `json:"coin"`
+	Wallet *string `json:"wallet,omitempty"`
+}
+
+// NewFreezeUTXOCmd returns a new instance which can be used to issue a
+// freeze_utxo JSON-RPC command.
+func NewFreezeUTXOCmd(outPoint *wire.OutPoint) *FreezeUTXOCmd {
+	return &FreezeUTXOCmd{Coin: outPoint.String()}
+}
+
+// UnfreezeUTXOCmd defines the unfreeze_utxo JSON-RPC command.
+type UnfreezeUTXOCmd struct {
+	Coin   string  `json:"coin"`
+	Wallet *string `json:"wallet,omitempty"`
+}
+
+// NewUnfreezeUTXOCmd returns a new instance which can be used to issue an
+// unfreeze_utxo JSON-RPC command.
+func NewUnfreezeUTXOCmd(outPoint *wire.OutPoint) *UnfreezeUTXOCmd {
+	return &UnfreezeUTXOCmd{Coin: outPoint.String()}
+}
+
+// FreezeUTXOAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See FreezeUTXO for the blocking version and more details.
+func (c *Client) FreezeUTXOAsync(outPoint *wire.OutPoint) FutureFreezeUTXOResult {
+	return c.FreezeUTXOAsyncContext(context.Background(), outPoint)
+}
+
+// FreezeUTXOAsyncContext is like FreezeUTXOAsync but the request is canceled
+// and the future returns the context error when ctx is done.
+func (c *Client) FreezeUTXOAsyncContext(ctx context.Context, outPoint *wire.OutPoint) FutureFreezeUTXOResult {
+	cmd := NewFreezeUTXOCmd(outPoint)
+	cmd.Wallet = c.wallet()
+	return c.sendCmdContext(ctx, cmd)
+}
+
+// FreezeUTXO marks the passed outpoint as frozen in the wallet so it is no
+// longer selected as an input by commands like payto.
+func (c *Client) FreezeUTXO(outPoint *wire.OutPoint) (bool, error) {
+	return c.FreezeUTXOAsync(outPoint).Receive()
+}
+
+// FreezeUTXOContext is like FreezeUTXO but gives up and returns the context
+// error when ctx is done.
+func (c *Client) FreezeUTXOContext(ctx context.Context, outPoint *wire.OutPoint) (bool, error) {
+	return c.FreezeUTXOAsyncContext(ctx, outPoint).Receive()
+}
+
+// UnfreezeUTXOAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See UnfreezeUTXO for the blocking version and more details.
+func (c *Client) UnfreezeUTXOAsync(outPoint *wire.OutPoint) FutureFreezeUTXOResult {
+	return c.UnfreezeUTXOAsyncContext(context.Background(), outPoint)
+}
+
+// UnfreezeUTXOAsyncContext is like UnfreezeUTXOAsync but the request is
+// canceled and the future returns the context error when ctx is done.
+func (c *Client) UnfreezeUTXOAsyncContext(ctx context.Context, outPoint *wire.OutPoint) FutureFreezeUTXOResult {
+	cmd := NewUnfreezeUTXOCmd(outPoint)
+	cmd.Wallet = c.wallet()
+	return c.sendCmdContext(ctx, cmd)
+}
+
+// UnfreezeUTXO makes a previously frozen outpoint available for coin
+// selection again.
+func (c *Client) UnfreezeUTXO(outPoint *wire.OutPoint) (bool, error) {
+	return c.UnfreezeUTXOAsync(outPoint).Receive()
+}
+
+// UnfreezeUTXOContext is like UnfreezeUTXO but gives up and returns the context
+// error when ctx is done.
+func (c *Client) UnfreezeUTXOContext(ctx context.Context, outPoint *wire.OutPoint) (bool, error) {
+	return c.UnfreezeUTXOAsyncContext(ctx, outPoint).Receive()
+}
+
+// WalletInfo describes a wallet loaded in the Electrum daemon.
+type WalletInfo struct {
+	Path         string `json:"path"`
+	Synchronized bool   `json:"synchronized"`
+}
+
+// FutureListWalletsResult is a future promise to deliver the result of a
+// ListWalletsAsync RPC invocation (or an applicable error).
+type FutureListWalletsResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// wallets loaded in the daemon.
+func (r FutureListWalletsResult) Receive() ([]WalletInfo, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallets []WalletInfo
+	err = json.Unmarshal(res, &wallets)
+	return wallets, err
+}
+
+// ListWalletsCmd defines the list_wallets JSON-RPC command.
+type ListWalletsCmd struct {
+}
+
+// NewListWalletsCmd returns a new instance which can be used to issue a
+// list_wallets JSON-RPC command.
+func NewListWalletsCmd() *ListWalletsCmd {
+	return &ListWalletsCmd{}
+}
+
+// ListWalletsAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See ListWallets for the blocking version and more details.
+func (c *Client) ListWalletsAsync() FutureListWalletsResult {
+	return c.ListWalletsAsyncContext(context.Background())
+}
+
+// ListWalletsAsyncContext is like ListWalletsAsync but the request is canceled
+// and the future returns the context error when ctx is done.
+func (c *Client) ListWalletsAsyncContext(ctx context.Context) FutureListWalletsResult {
+	cmd := NewListWalletsCmd()
+	return c.sendCmdContext(ctx, cmd)
+}
+
+// ListWallets returns the wallets loaded in the daemon.
+func (c *Client) ListWallets() ([]WalletInfo, error) {
+	return c.ListWalletsAsync().Receive()
+}
+
+// ListWalletsContext is like ListWallets but gives up and returns the context
+// error when ctx is done.
+func (c *Client) ListWalletsContext(ctx context.Context) ([]WalletInfo, error) {
+	return c.ListWalletsAsyncContext(ctx).Receive()
+}
+
+// FutureLoadWalletResult is a future promise to deliver the result of a
+// LoadWalletAsync RPC invocation (or an applicable error).
+type FutureLoadWalletResult chan *response
+
+// Receive waits for the response promised by the future and returns an error
+// if the wallet could not be loaded.
+func (r FutureLoadWalletResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// LoadWalletCmd defines the load_wallet JSON-RPC command.
+type LoadWalletCmd struct {
+	WalletPath *string `json:"wallet_path,omitempty"`
+	Password   *string `json:"password,omitempty"`
+}
+
+// NewLoadWalletCmd returns a new instance which can be used to issue a
+// load_wallet JSON-RPC command.  An empty walletPath loads the default wallet
+// of the daemon.
+func NewLoadWalletCmd(walletPath string) *LoadWalletCmd {
+	cmd := &LoadWalletCmd{}
+	if walletPath != "" {
+		cmd.WalletPath = &walletPath
+	}
+	return cmd
+}
+
+// LoadWalletAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See LoadWallet for the blocking version and more details.
+func (c *Client) LoadWalletAsync(walletPath string) FutureLoadWalletResult {
+	return c.LoadWalletAsyncContext(context.Background(), walletPath)
+}
+
+// LoadWalletAsyncContext is like LoadWalletAsync but the request is canceled
+// and the future returns the context error when ctx is done.
+func (c *Client) LoadWalletAsyncContext(ctx context.Context, walletPath string) FutureLoadWalletResult {
+	cmd := NewLoadWalletCmd(walletPath)
+	cmd.Password = c.walletPass()
+	return c.sendCmdContext(ctx, cmd)
+}
+
+// LoadWallet loads the wallet at walletPath in the daemon, decrypting it with
+// the configured wallet password.  Subsequent commands are routed to it when
+// it is the wallet configured on the client.
+func (c *Client) LoadWallet(walletPath string) error {
+	return c.LoadWalletAsync(walletPath).Receive()
+}
+
+// LoadWalletContext is like LoadWallet but gives up and returns the context
+// error when ctx is done.
+func (c *Client) LoadWalletContext(ctx context.Context, walletPath string) error {
+	return c.LoadWalletAsyncContext(ctx, walletPath).Receive()
+}
+
+// FutureGetTransactionResult is a future promise to deliver the result of a
+// GetTransactionAsync RPC invocation (or an applicable error).
+type FutureGetTransactionResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// transaction.
+func (r FutureGetTransactionResult) Receive() (*wire.MsgTx, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Electrum 3 returns an object holding the serialized transaction,
+	// later versions the serialized transaction itself.
+	var txHex string
+	err = json.Unmarshal(res, &txHex)
+	if err != nil {
+		var resp struct {
+			Hex string `json:"hex"`
+		}
+		err = json.Unmarshal(res, &resp)
+		if err != nil {
+			return nil, err
+		}
+		txHex = resp.Hex
+	}
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, err
+	}
+	tx := &wire.MsgTx{}
+	err = tx.Deserialize(bytes.NewReader(txBytes))
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// GetTransactionCmd defines the gettransaction JSON-RPC command.
+type GetTransactionCmd struct {
+	TxID   string  `json:"txid"`
+	Wallet *string `json:"wallet,omitempty"`
+}
+
+// NewGetTransactionCmd returns a new instance which can be used to issue a
+// gettransaction JSON-RPC command.
+func NewGetTransactionCmd(txHash *chainhash.Hash) *GetTransactionCmd {
+	return &GetTransactionCmd{TxID: txHash.String()}
+}
+
+// GetTransactionAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetTransaction for the blocking version and more details.
+func (c *Client) GetTransactionAsync(txHash *chainhash.Hash) FutureGetTransactionResult {
+	return c.GetTransactionAsyncContext(context.Background(), txHash)
+}
+
+// GetTransactionAsyncContext is like GetTransactionAsync but the request is
+// canceled and the future returns the context error when ctx is done.
+func (c *Client) GetTransactionAsyncContext(ctx context.Context, txHash *chainhash.Hash) FutureGetTransactionResult {
+	cmd := NewGetTransactionCmd(txHash)
+	cmd.Wallet = c.wallet()
+	return c.sendCmdContext(ctx, cmd)
+}
+
+// GetTransaction returns the transaction with the passed hash, looking it up
+// in the wallet first and on the Electrum server otherwise.
+func (c *Client) GetTransaction(txHash *chainhash.Hash) (*wire.MsgTx, error) {
+	return c.GetTransactionAsync(txHash).Receive()
+}
+
+// GetTransactionContext is like GetTransaction but gives up and returns the
+// context error when ctx is done.
+func (c *Client) GetTransactionContext(ctx context.Context, txHash *chainhash.Hash) (*wire.MsgTx, error) {
+	return c.GetTransactionAsyncContext(ctx, txHash).Receive()
+}
+
+// AddressTx is a transaction in the history of an address.  Height is zero or
+// negative for unconfirmed transactions.
+type AddressTx struct {
+	TxHash *chainhash.Hash
+	Height int64
+}
+
+// FutureGetAddressHistoryResult is a future promise to deliver the result of
+// a GetAddressHistoryAsync RPC invocation (or an applicable error).
+type FutureGetAddressHistoryResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// transactions in the history of the address.
+func (r FutureGetAddressHistoryResult) Receive() ([]*AddressTx, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp []struct {
+		TxHash string `json:"tx_hash"`
+		Height int64  `json:"height"`
+	}
+	err = json.Unmarshal(res, &resp)
+	if err != nil {
+		return nil, err
+	}
+	history := make([]*AddressTx, 0, len(resp))
+	for _, entry := range resp {
+		txHash, err := chainhash.NewHashFromStr(entry.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, &AddressTx{TxHash: txHash, Height: entry.Height})
+	}
+	return history, nil
+}
+
+// GetAddressHistoryCmd defines the getaddresshistory JSON-RPC command.
+type GetAddressHistoryCmd struct {
+	Address string `json:"address"`
+}
+
+// NewGetAddressHistoryCmd returns a new instance which can be used to issue a
+// getaddresshistory JSON-RPC command.
+func NewGetAddressHistoryCmd(address btcutil.Address) *GetAddressHistoryCmd {
+	return &GetAddressHistoryCmd{Address: address.EncodeAddress()}
+}
+
+// GetAddressHistoryAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetAddressHistory for the blocking version and more details.
+func (c *Client) GetAddressHistoryAsync(address btcutil.Address) FutureGetAddressHistoryResult {
+	return c.GetAddressHistoryAsyncContext(context.Background(), address)
+}
+
+// GetAddressHistoryAsyncContext is like GetAddressHistoryAsync but the request
+// is canceled and the future returns the context error when ctx is done.
+func (c *Client) GetAddressHistoryAsyncContext(ctx context.Context, address btcutil.Address) FutureGetAddressHistoryResult {
+	cmd := NewGetAddressHistoryCmd(address)
+	cmd.Address = c.encodeAddress(address)
+	return c.sendCmdContext(ctx, cmd)
+}
+
+// GetAddressHistory returns the transactions paying to or spending from the
+// passed address, as known by the Electrum server.  The address does not have
+// to belong to the wallet.
+func (c *Client) GetAddressHistory(address btcutil.Address) ([]*AddressTx, error) {
+	return c.GetAddressHistoryAsync(address).Receive()
+}
+
+// GetAddressHistoryContext is like GetAddressHistory but gives up and returns
+// the context error when ctx is done.
+func (c *Client) GetAddressHistoryContext(ctx context.Context, address btcutil.Address) ([]*AddressTx, error) {
+	return c.GetAddressHistoryAsyncContext(ctx, address).Receive()
+}
+
 //-----------------------
 // Btc-Core compatibility
 //-----------------------
@@ -391,13 +1207,29 @@ func (c *Client) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainh
 	return c.Broadcast(tx)
 }
 
+// SendRawTransactionContext is like SendRawTransaction but gives up and returns
+// the context error when ctx is done.
+func (c *Client) SendRawTransactionContext(ctx context.Context, tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error) {
+	return c.BroadcastContext(ctx, tx)
+}
+
 func init() {
-	RegisterCmd("getunusedaddress", (*GetUnusedAddressCmd)(nil), false)
-	RegisterCmd("getprivatekeys", (*GetPrivateKeysCmd)(nil), false)
+	RegisterCmd("getunusedaddress", (*GetUnusedAddressCmd)(nil), true)
+	RegisterCmd("getprivatekeys", (*GetPrivateKeysCmd)(nil), true)
 	RegisterCmd("getfeerate", (*GetFeeRateCmd)(nil), false)
 	RegisterCmd("payto", (*PayToCmd)(nil), true)
-	RegisterCmd("listunspent", (*ListUnspentCmd)(nil), false)
+	RegisterCmd("paytomany", (*PayToManyCmd)(nil), true)
+	RegisterCmd("listunspent", (*ListUnspentCmd)(nil), true)
 	RegisterCmd("broadcast", (*BroadcastCmd)(nil), false)
+	RegisterCmd("version", (*VersionCmd)(nil), false)
+	RegisterCmd("getinfo", (*GetInfoCmd)(nil), false)
+	RegisterCmd("getbalance", (*GetBalanceCmd)(nil), true)
+	RegisterCmd("freeze_utxo", (*FreezeUTXOCmd)(nil), true)
+	RegisterCmd("unfreeze_utxo", (*UnfreezeUTXOCmd)(nil), true)
+	RegisterCmd("list_wallets", (*ListWalletsCmd)(nil), true)
+	RegisterCmd("load_wallet", (*LoadWalletCmd)(nil), true)
+	RegisterCmd("gettransaction", (*GetTransactionCmd)(nil), true)
+	RegisterCmd("getaddresshistory", (*GetAddressHistoryCmd)(nil), false)
 }
 
 //-----------------------
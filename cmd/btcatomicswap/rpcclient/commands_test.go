@@ -0,0 +1,303 @@
+package rpcclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// testAddress is the address the test daemon returns, prefixed with
+// testAddressPrefix so only the decoder of the configuration accepts it.
+const (
+	testAddress       = "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"
+	testAddressPrefix = "test:"
+)
+
+// newTestDaemon returns a server answering getunusedaddress and listunspent,
+// single or batched, with prefixed addresses.
+func newTestDaemon(t *testing.T) *httptest.Server {
+	results := map[string]string{
+		"version":          `"4.1.5"`,
+		"getunusedaddress": `"` + testAddressPrefix + testAddress + `"`,
+		"listunspent": `[{"address":"` + testAddressPrefix + testAddress + `","value":"0.5",` +
+			`"prevout_n":1,"prevout_hash":"` + strings.Repeat("11", 32) + `","height":1}]`,
+	}
+	type request struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	respond := func(req *request) string {
+		return `{"id":` + string(req.ID) + `,"result":` + results[req.Method] + `,"error":null}`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var batch []*request
+		if json.Unmarshal(body, &batch) == nil {
+			responses := make([]string, len(batch))
+			for i, req := range batch {
+				responses[i] = respond(req)
+			}
+			w.Write([]byte("[" + strings.Join(responses, ",") + "]"))
+			return
+		}
+		var req request
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Write([]byte(respond(&req)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFuturesUseConfiguredDecoder(t *testing.T) {
+	srv := newTestDaemon(t)
+	c, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(srv.URL, "http://"),
+		DisableTLS:   true,
+		HTTPPostMode: true,
+		DecodeAddress: func(addr string) (btcutil.Address, error) {
+			return btcutil.DecodeAddress(strings.TrimPrefix(addr, testAddressPrefix),
+				&chaincfg.MainNetParams)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Shutdown()
+
+	checkAddress := func(path string, addr btcutil.Address, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if addr.EncodeAddress() != testAddress {
+			t.Fatalf("%s: address is %v, want %v", path, addr, testAddress)
+		}
+	}
+	checkUnspent := func(path string, utxos []*UnspentOutput, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if len(utxos) != 1 {
+			t.Fatalf("%s: %d unspent outputs, want 1", path, len(utxos))
+		}
+		checkAddress(path, utxos[0].Address, nil)
+	}
+
+	addr, err := c.GetUnusedAddress()
+	checkAddress("GetUnusedAddress", addr, err)
+	addr, err = c.GetUnusedAddressAsync().Receive()
+	checkAddress("GetUnusedAddressAsync", addr, err)
+	utxos, err := c.ListUnspent()
+	checkUnspent("ListUnspent", utxos, err)
+	utxos, err = c.ListUnspentAsync().Receive()
+	checkUnspent("ListUnspentAsync", utxos, err)
+
+	b := c.NewBatch()
+	first := b.ListUnspentAsync()
+	second := b.ListUnspentAsync()
+	err = b.Send()
+	if err != nil {
+		t.Fatal(err)
+	}
+	utxos, err = first.Receive()
+	checkUnspent("Batch.ListUnspentAsync", utxos, err)
+	utxos, err = second.Receive()
+	checkUnspent("Batch.ListUnspentAsync", utxos, err)
+}
+
+func TestListUnspentInvalidAddress(t *testing.T) {
+	srv := newTestDaemon(t)
+	c, err := New(&ConnConfig{
+		Host:         strings.TrimPrefix(srv.URL, "http://"),
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Shutdown()
+
+	// Without the decoder the prefixed address is invalid, which must be
+	// reported rather than returning an output without an address.
+	_, err = c.ListUnspent()
+	if err == nil {
+		t.Fatal("undecodable address was not reported")
+	}
+}
+
+// newRecordingDaemon returns a server answering every request with a null
+// result, and a function returning the named parameters of the last request
+// of each method.  Positional parameters are recorded as no parameters.
+func newRecordingDaemon(t *testing.T) (*httptest.Server, func() map[string]map[string]json.RawMessage) {
+	var mtx sync.Mutex
+	params := make(map[string]map[string]json.RawMessage)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var named map[string]json.RawMessage
+		json.Unmarshal(req.Params, &named)
+		mtx.Lock()
+		params[req.Method] = named
+		mtx.Unlock()
+		w.Write([]byte(`{"id":` + string(req.ID) + `,"result":null,"error":null}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() map[string]map[string]json.RawMessage {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return params
+	}
+}
+
+// callCommands sends every command of the client once.  The results are
+// ignored, the commands are only called for the requests they send.
+func callCommands(t *testing.T, c *Client) {
+	addr, err := btcutil.DecodeAddress(testAddress, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outPoint := &wire.OutPoint{Hash: chainhash.Hash{1}}
+	c.GetUnusedAddress()
+	c.DumpPrivKey(addr)
+	c.GetFeeRate()
+	c.PayTo(addr, 1e5, 0, true)
+	c.PayToMany(map[btcutil.Address]btcutil.Amount{addr: 1e5}, 0, true)
+	c.ListUnspent()
+	c.Broadcast(wire.NewMsgTx(wire.TxVersion))
+	c.Version()
+	c.GetInfo()
+	c.GetBalance()
+	c.FreezeUTXO(outPoint)
+	c.UnfreezeUTXO(outPoint)
+	c.ListWallets()
+	c.LoadWallet("/wallets/default_wallet")
+	c.GetTransaction(&outPoint.Hash)
+	c.GetAddressHistory(addr)
+}
+
+// allMethods are the methods sent by callCommands.
+var allMethods = []string{
+	"getunusedaddress", "getprivatekeys", "getfeerate", "payto", "paytomany",
+	"listunspent", "broadcast", "version", "getinfo", "getbalance",
+	"freeze_utxo", "unfreeze_utxo", "list_wallets", "load_wallet",
+	"gettransaction", "getaddresshistory",
+}
+
+// checkParam checks that the request of every method in allMethods has the
+// parameter name with value exactly when want reports true for the method.
+func checkParam(t *testing.T, params map[string]map[string]json.RawMessage, name, value string, want func(method string) bool) {
+	t.Helper()
+	for _, method := range allMethods {
+		methodParams, ok := params[method]
+		if !ok {
+			t.Errorf("%s was not sent", method)
+			continue
+		}
+		param, ok := methodParams[name]
+		switch {
+		case !want(method) && ok:
+			t.Errorf("%s: unexpected %s parameter %s", method, name, param)
+		case want(method) && !ok:
+			t.Errorf("%s: no %s parameter", method, name)
+		case ok && string(param) != `"`+value+`"`:
+			t.Errorf("%s: %s parameter is %s, want %q", method, name, param, value)
+		}
+	}
+}
+
+func TestWalletPassParam(t *testing.T) {
+	// The password is only passed to the commands signing transactions,
+	// revealing private keys or opening the wallet.
+	needPass := map[string]bool{
+		"getprivatekeys": true,
+		"payto":          true,
+		"paytomany":      true,
+		"load_wallet":    true,
+	}
+	for _, pass := range []string{"", "secret"} {
+		srv, params := newRecordingDaemon(t)
+		c, err := New(&ConnConfig{
+			Host:            strings.TrimPrefix(srv.URL, "http://"),
+			DisableTLS:      true,
+			HTTPPostMode:    true,
+			WalletPass:      pass,
+			ElectrumVersion: "4.1.5",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		callCommands(t, c)
+		c.Shutdown()
+
+		checkParam(t, params(), "password", pass, func(method string) bool {
+			return pass != "" && needPass[method]
+		})
+	}
+}
+
+func TestWalletParam(t *testing.T) {
+	// The commands operating on a wallet are routed to the configured one,
+	// the commands of the daemon and the network are not.
+	walletCommands := map[string]bool{
+		"getunusedaddress": true,
+		"getprivatekeys":   true,
+		"payto":            true,
+		"paytomany":        true,
+		"listunspent":      true,
+		"getbalance":       true,
+		"freeze_utxo":      true,
+		"unfreeze_utxo":    true,
+		"gettransaction":   true,
+	}
+	for _, wallet := range []string{"", "/wallets/second_wallet"} {
+		srv, params := newRecordingDaemon(t)
+		c, err := New(&ConnConfig{
+			Host:            strings.TrimPrefix(srv.URL, "http://"),
+			DisableTLS:      true,
+			HTTPPostMode:    true,
+			Wallet:          wallet,
+			ElectrumVersion: "4.1.5",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		callCommands(t, c)
+		c.Shutdown()
+
+		checkParam(t, params(), "wallet", wallet, func(method string) bool {
+			return wallet != "" && walletCommands[method]
+		})
+
+		// load_wallet names the wallet it loads, whichever is selected.
+		path := string(params()["load_wallet"]["wallet_path"])
+		if path != `"/wallets/default_wallet"` {
+			t.Errorf("load_wallet: wallet_path parameter is %s", path)
+		}
+	}
+}
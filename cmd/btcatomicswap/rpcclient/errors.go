@@ -0,0 +1,113 @@
+package rpcclient
+
+import (
+	"errors"
+	"strings"
+)
+
+// Errors describing the kind of failure reported by the Electrum daemon.  An
+// RPCError matches one of these with errors.Is, so callers can branch on the
+// kind of failure without parsing error messages:
+//
+//	if errors.Is(err, rpcclient.ErrWalletLocked) {
+//		...
+//	}
+var (
+	// ErrMethodNotFound is returned when the daemon does not know the
+	// requested method, usually because the Electrum version is too old.
+	ErrMethodNotFound = errors.New("method not found")
+
+	// ErrWalletNotLoaded is returned when no wallet is loaded in the
+	// daemon.
+	ErrWalletNotLoaded = errors.New("wallet not loaded")
+
+	// ErrWalletLocked is returned when a command needs the password of an
+	// encrypted wallet and none was supplied.
+	ErrWalletLocked = errors.New("wallet is locked")
+
+	// ErrWrongPassword is returned when the supplied wallet password is
+	// incorrect.
+	ErrWrongPassword = errors.New("incorrect wallet password")
+
+	// ErrInsufficientFunds is returned when the wallet can not fund a
+	// payment.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+
+	// ErrTxNonFinal is returned when a transaction is broadcast before its
+	// locktime has been reached.
+	ErrTxNonFinal = errors.New("transaction is not final")
+
+	// ErrAlreadyInChain is returned when a broadcast transaction is already
+	// included in the block chain.
+	ErrAlreadyInChain = errors.New("transaction already in block chain")
+
+	// ErrAlreadyInMempool is returned when a broadcast transaction is
+	// already known to the mempool of the server.
+	ErrAlreadyInMempool = errors.New("transaction already in mempool")
+
+	// ErrMissingInputs is returned when the inputs of a broadcast
+	// transaction are unknown or already spent.
+	ErrMissingInputs = errors.New("transaction inputs missing or spent")
+
+	// ErrMempoolConflict is returned when another transaction spending
+	// the inputs of a broadcast transaction is already in the mempool of
+	// the server.
+	ErrMempoolConflict = errors.New("transaction conflicts with the mempool")
+
+	// ErrFeeTooLow is returned when a broadcast transaction does not pay
+	// the minimum relay fee.
+	ErrFeeTooLow = errors.New("transaction fee too low")
+)
+
+// rpcMethodNotFound is the JSON-RPC error code for unknown methods.
+const rpcMethodNotFound RPCErrorCode = -32601
+
+// errorMessages maps fragments of the messages reported by Electrum and the
+// bitcoind nodes behind Electrum servers to the error kinds.  The fragments
+// are matched case insensitively and in order.
+var errorMessages = []struct {
+	fragment string
+	kind     error
+}{
+	{"method not found", ErrMethodNotFound},
+	{"unknown command", ErrMethodNotFound},
+	{"wallet not loaded", ErrWalletNotLoaded},
+	{"no wallet", ErrWalletNotLoaded},
+	{"password required", ErrWalletLocked},
+	{"incorrect password", ErrWrongPassword},
+	{"invalid password", ErrWrongPassword},
+	{"insufficient funds", ErrInsufficientFunds},
+	{"not enough funds", ErrInsufficientFunds},
+	{"non-final", ErrTxNonFinal},
+	{"non-bip68-final", ErrTxNonFinal},
+	{"already in block chain", ErrAlreadyInChain},
+	{"txn-already-known", ErrAlreadyInMempool},
+	{"txn-already-in-mempool", ErrAlreadyInMempool},
+	{"missing inputs", ErrMissingInputs},
+	{"missingorspent", ErrMissingInputs},
+	{"txn-mempool-conflict", ErrMempoolConflict},
+	{"min relay fee not met", ErrFeeTooLow},
+	{"mempool min fee not met", ErrFeeTooLow},
+}
+
+// Kind returns the error kind describing e, such as ErrWalletLocked, or nil
+// if the failure is not one known to this package.
+func (e RPCError) Kind() error {
+	if e.Code == rpcMethodNotFound {
+		return ErrMethodNotFound
+	}
+	message := strings.ToLower(e.Message)
+	for _, m := range errorMessages {
+		if strings.Contains(message, m.fragment) {
+			return m.kind
+		}
+	}
+	return nil
+}
+
+// Is reports whether target is the error kind of e.  It allows errors.Is to
+// match an RPCError against the error kinds exported by this package.
+func (e RPCError) Is(target error) bool {
+	kind := e.Kind()
+	return kind != nil && kind == target
+}
@@ -0,0 +1,89 @@
+package rpcclient
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// errorKinds are the error kinds exported by the package.
+var errorKinds = []error{
+	ErrMethodNotFound,
+	ErrWalletNotLoaded,
+	ErrWalletLocked,
+	ErrWrongPassword,
+	ErrInsufficientFunds,
+	ErrTxNonFinal,
+	ErrAlreadyInChain,
+	ErrAlreadyInMempool,
+	ErrMissingInputs,
+	ErrMempoolConflict,
+	ErrFeeTooLow,
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		code    RPCErrorCode
+		message string
+		kind    error
+	}{
+		{rpcMethodNotFound, "", ErrMethodNotFound},
+		{-32000, "Method not found", ErrMethodNotFound},
+		{-32000, "Unknown command: freeze_utxo", ErrMethodNotFound},
+		{-32000, "Wallet not loaded. Use 'electrum load_wallet'", ErrWalletNotLoaded},
+		{-32000, "No wallet is loaded", ErrWalletNotLoaded},
+		{-32000, "Password required", ErrWalletLocked},
+		{-32000, "Incorrect password", ErrWrongPassword},
+		{-32000, "Invalid password", ErrWrongPassword},
+		{-32000, "Insufficient funds", ErrInsufficientFunds},
+		{-32000, "Not enough funds", ErrInsufficientFunds},
+		{-26, "non-final", ErrTxNonFinal},
+		{-26, "non-BIP68-final", ErrTxNonFinal},
+		{-27, "transaction already in block chain", ErrAlreadyInChain},
+		{-26, "txn-already-known", ErrAlreadyInMempool},
+		{-26, "txn-already-in-mempool", ErrAlreadyInMempool},
+		{-25, "Missing inputs", ErrMissingInputs},
+		{-25, "bad-txns-inputs-missingorspent", ErrMissingInputs},
+		{-26, "txn-mempool-conflict", ErrMempoolConflict},
+		{-26, "min relay fee not met, 100 < 226", ErrFeeTooLow},
+		{-26, "mempool min fee not met", ErrFeeTooLow},
+		{-26, "dust", nil},
+		{-32602, "Invalid parameters", nil},
+	}
+	for _, test := range tests {
+		rpcErr := RPCError{Code: test.code, Message: test.message}
+		if kind := rpcErr.Kind(); kind != test.kind {
+			t.Errorf("%d %q: kind is %v, want %v", test.code, test.message, kind, test.kind)
+		}
+
+		// Every kind but the expected one is rejected, also when the
+		// error is returned as a pointer and wrapped by the caller.
+		for _, err := range []error{rpcErr, &rpcErr, fmt.Errorf("broadcast: %w", &rpcErr)} {
+			for _, kind := range errorKinds {
+				if errors.Is(err, kind) != (kind == test.kind) {
+					t.Errorf("%d %q: errors.Is(%T, %v) is %v", test.code, test.message,
+						err, kind, kind != test.kind)
+				}
+			}
+		}
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	rpcErr := RPCError{Code: -32000, Message: "Password required"}
+	if !rpcErr.Is(ErrWalletLocked) {
+		t.Error("locked wallet error is not ErrWalletLocked")
+	}
+	if rpcErr.Is(ErrWrongPassword) {
+		t.Error("locked wallet error is ErrWrongPassword")
+	}
+	if rpcErr.Is(errors.New("password required")) {
+		t.Error("locked wallet error matches an error with the same message")
+	}
+
+	// An unclassified error has no kind, so it matches no nil target.
+	unknown := RPCError{Code: -32000, Message: "unexpected failure"}
+	if unknown.Is(nil) {
+		t.Error("unclassified error matches nil")
+	}
+}
@@ -39,7 +39,7 @@ func main() {
 
 	amount, err := btcutil.NewAmount(0.01)
 
-	tx, _, err := client.PayTo(addr, amount, true)
+	tx, _, err := client.PayTo(addr, amount, feerate, true)
 	if err != nil {
 		log.Fatal(err)
 	}
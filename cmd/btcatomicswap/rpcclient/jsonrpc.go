@@ -134,11 +134,18 @@ func makeParams(rt reflect.Type, rv reflect.Value) []interface{} {
 // must be a registered type.  All commands provided by this package are
 // registered by default.
 func MarshalCmd(id interface{}, cmd interface{}) ([]byte, error) {
+	return marshalCmd(id, cmd, false)
+}
 
+// marshalCmd marshals the passed command like MarshalCmd.  The command is sent
+// with named parameters when forceNamed is set, regardless of the parameter
+// style it was registered with.
+func marshalCmd(id interface{}, cmd interface{}, forceNamed bool) ([]byte, error) {
 	method, namedParameters, err := CmdMethod(cmd)
 	if err != nil {
 		return nil, err
 	}
+	namedParameters = namedParameters || forceNamed
 
 	// The provided command must not be nil.
 	rv := reflect.ValueOf(cmd)
@@ -5,17 +5,27 @@ package rpcclient
 import (
 	"bytes"
 	"container/list"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"reflect"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/go-socks/socks"
 )
 
 var (
@@ -39,13 +49,38 @@ const (
 	// sendPostBufferSize is the number of elements the HTTP POST send
 	// channel can queue before blocking.
 	sendPostBufferSize = 100
+
+	// defaultConcurrency is the number of requests sent in parallel when
+	// ConnConfig.Concurrency is not set.
+	defaultConcurrency = 4
+
+	// defaultRetryBackoff is the delay before the first retry of a failed
+	// request when ConnConfig.RetryBackoff is not set.
+	defaultRetryBackoff = 500 * time.Millisecond
+
+	// maxRetryBackoff is the maximum delay between retries.
+	maxRetryBackoff = 30 * time.Second
 )
 
+// idempotentMethods are the methods that only read state, so they can safely
+// be sent again when a request fails before a response is received.
+var idempotentMethods = map[string]bool{
+	"version":           true,
+	"getinfo":           true,
+	"getfeerate":        true,
+	"getbalance":        true,
+	"listunspent":       true,
+	"getunusedaddress":  true,
+	"getprivatekeys":    true,
+	"list_wallets":      true,
+	"gettransaction":    true,
+	"getaddresshistory": true,
+}
+
 // sendPostDetails houses an HTTP POST request to send to an RPC server as well
 // as the original JSON-RPC command and a channel to reply on when the server
 // responds with the result.
 type sendPostDetails struct {
-	httpRequest *http.Request
 	jsonRequest *jsonRequest
 }
 
@@ -90,16 +125,44 @@ func (r rawResponse) result() (result []byte, err error) {
 type response struct {
 	result []byte
 	err    error
+
+	// client is the client that received the result.  The futures decode
+	// the addresses and keys in the result with its configuration, so they
+	// decode the same as the methods of the client.
+	client *Client
+}
+
+// decodeAddress decodes an address in the result with the DecodeAddress
+// function of the client configuration, if any.
+func (r *response) decodeAddress(addr string) (btcutil.Address, error) {
+	if r.client == nil {
+		return decodeAddress(addr)
+	}
+	return r.client.decodeAddress(addr)
+}
+
+// decodeWIF decodes a private key in the result with the DecodeWIF function
+// of the client configuration, if any.
+func (r *response) decodeWIF(wif string) (*btcutil.WIF, error) {
+	if r.client == nil {
+		return btcutil.DecodeWIF(wif)
+	}
+	return r.client.decodeWIF(wif)
 }
 
 // jsonRequest holds information about a json request that is used to properly
 // detect, interpret, and deliver a reply to it.
 type jsonRequest struct {
+	ctx            context.Context
 	id             uint64
 	method         string
 	cmd            interface{}
 	marshalledJSON []byte
 	responseChan   chan *response
+
+	// batch is set when marshalledJSON is a JSON-RPC batch array.  The
+	// raw response array is delivered on responseChan.
+	batch bool
 }
 
 // Client represents an  Electrum RPC client which allows easy access to the
@@ -110,9 +173,23 @@ type jsonRequest struct {
 type Client struct {
 	id uint64 // atomic, so must stay 64-bit aligned
 
+	// noBatch is set once the server rejected a batch request, after which
+	// batches are sent as individual requests.  It is accessed atomically.
+	noBatch int32
+
 	// config holds the connection configuration assoiated with this client.
 	config *ConnConfig
 
+	// version is the version of the Electrum daemon and adapter encodes
+	// requests and decodes responses for it.  They are detected when the
+	// first request other than version is sent, so a client can be
+	// created for a daemon that is unreachable or unsupported.  detectMtx
+	// is held while the version is queried, so it is queried only once.
+	detectMtx  sync.Mutex
+	adapterMtx sync.Mutex
+	version    string
+	adapter    *adapter
+
 	// httpClient is the underlying HTTP client to use when running in HTTP
 	// POST mode.
 	httpClient *http.Client
@@ -166,6 +243,20 @@ type ConnConfig struct {
 	// is true.
 	Certificates []byte
 
+	// ClientCertificate and ClientKey are the bytes for a PEM-encoded
+	// certificate and private key presented to servers requiring client
+	// authentication.  Both must be set to present a certificate.  They
+	// have no effect if the DisableTLS parameter is true.
+	ClientCertificate []byte
+	ClientKey         []byte
+
+	// CertificateSHA256 pins the server certificate to the one with this
+	// SHA-256 fingerprint.  When Certificates is empty the pinned
+	// certificate is accepted without verifying its chain, which allows
+	// connecting to servers using a self-signed certificate.  It has no
+	// effect if the DisableTLS parameter is true.
+	CertificateSHA256 []byte
+
 	// Proxy specifies to connect through a SOCKS 5 proxy server.  It may
 	// be an empty string if a proxy is not required.
 	Proxy string
@@ -180,6 +271,70 @@ type ConnConfig struct {
 	// is not set.
 	ProxyPass string
 
+	// TorIsolation enables Tor stream isolation by authenticating to the
+	// proxy with credentials derived from IsolationKey, so requests made
+	// for different swaps use different Tor circuits.  Without an
+	// isolation key random credentials are generated for each client.  It
+	// has no effect if the Proxy parameter is not set or ProxyUser is set.
+	TorIsolation bool
+
+	// IsolationKey identifies the swap the requests are made for when
+	// TorIsolation is enabled, such as the hash of its secret.  Clients
+	// created with the same key use the same credentials and so share a
+	// Tor circuit.
+	IsolationKey []byte
+
+	// Wallet is the path of the wallet the commands are routed to when the
+	// daemon has several wallets loaded.  It may be an empty string to use
+	// the default wallet of the daemon.
+	Wallet string
+
+	// WalletPass is the password of an encrypted Electrum wallet.  It is
+	// passed to the commands that need to sign transactions or reveal
+	// private keys and is never logged.  It may be an empty string if the
+	// wallet is not encrypted.
+	WalletPass string
+
+	// ElectrumVersion is the version of the Electrum daemon, such as
+	// "4.1.5".  It selects how requests are encoded and responses decoded.
+	// When it is empty the version is queried before the first request.
+	ElectrumVersion string
+
+	// DecodeAddress, when set, decodes the addresses returned by daemons of
+	// other coins, such as the cashaddr addresses of Electron Cash, for the
+	// methods, futures and batches of the client.
+	DecodeAddress func(addr string) (btcutil.Address, error)
+
+	// EncodeAddress, when set, encodes the addresses passed to the daemon
+	// by the methods of the client.  The legacy Bitcoin encoding is used
+	// otherwise, which is also accepted by Electron Cash.
+	EncodeAddress func(addr btcutil.Address) string
+
+	// DecodeWIF, when set, decodes the private keys returned by daemons of
+	// coins with their own WIF checksum, such as Groestlcoin, for the
+	// methods, futures and batches of the client.
+	DecodeWIF func(wif string) (*btcutil.WIF, error)
+
+	// Concurrency is the maximum number of requests in flight at the same
+	// time.  Requests beyond it are queued until a worker is available.
+	// Zero uses a default of four.
+	Concurrency int
+
+	// Timeout is the maximum duration of a single request, including
+	// reading the response.  A request is also canceled when the context
+	// it was made with is done.  Zero means no timeout.
+	Timeout time.Duration
+
+	// MaxRetries is the number of times a request of a method that only
+	// reads state is sent again after it failed without a response from
+	// the daemon, for example because the daemon is restarting.  Requests
+	// with side effects are never retried.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry, doubled for each
+	// subsequent retry.  Zero uses a default of half a second.
+	RetryBackoff time.Duration
+
 	// HTTPPostMode instructs the client to run using multiple independent
 	// connections issuing HTTP POST requests instead of using the default
 	// of websockets.  Websockets are generally preferred as some of the
@@ -191,41 +346,196 @@ type ConnConfig struct {
 	HTTPPostMode bool
 }
 
+// wallet returns the wallet parameter for commands operating on a wallet, or
+// nil to use the default wallet of the daemon.
+func (c *Client) wallet() *string {
+	if c.config.Wallet == "" {
+		return nil
+	}
+	wallet := c.config.Wallet
+	return &wallet
+}
+
+// walletPass returns the wallet password parameter for commands accepting
+// one, or nil when no password is configured.
+func (c *Client) walletPass() *string {
+	if c.config.WalletPass == "" {
+		return nil
+	}
+	pass := c.config.WalletPass
+	return &pass
+}
+
 // newHTTPClient returns a new http client that is configured according to the
 // proxy and TLS settings in the associated connection configuration.
 func newHTTPClient(config *ConnConfig) (*http.Client, error) {
-	// Set proxy function if there is a proxy configured.
-	var proxyFunc func(*http.Request) (*url.URL, error)
+	// Dial through the SOCKS 5 proxy if there is a proxy configured.
+	var dial func(ctx context.Context, network, addr string) (net.Conn, error)
 	if config.Proxy != "" {
-		proxyURL, err := url.Parse(config.Proxy)
+		proxy, err := newSocksProxy(config)
 		if err != nil {
 			return nil, err
 		}
-		proxyFunc = http.ProxyURL(proxyURL)
+		dial = proxyDialContext(proxy)
 	}
 
 	// Configure TLS if needed.
 	var tlsConfig *tls.Config
 	if !config.DisableTLS {
-		if len(config.Certificates) > 0 {
-			pool := x509.NewCertPool()
-			pool.AppendCertsFromPEM(config.Certificates)
-			tlsConfig = &tls.Config{
-				RootCAs: pool,
-			}
+		var err error
+		tlsConfig, err = newTLSConfig(config)
+		if err != nil {
+			return nil, err
 		}
 	}
 
+	// Keep as many idle connections as there are workers, so connections
+	// are reused instead of being opened for every request.
 	client := http.Client{
 		Transport: &http.Transport{
-			Proxy:           proxyFunc,
-			TLSClientConfig: tlsConfig,
+			DialContext:         dial,
+			TLSClientConfig:     tlsConfig,
+			MaxIdleConnsPerHost: config.concurrency(),
 		},
 	}
 
 	return &client, nil
 }
 
+// concurrency returns the number of requests sent in parallel.
+func (config *ConnConfig) concurrency() int {
+	if config.Concurrency <= 0 {
+		return defaultConcurrency
+	}
+	return config.Concurrency
+}
+
+// newSocksProxy returns the SOCKS 5 proxy described by the proxy settings in
+// the connection configuration.  The proxy address may be given as host:port
+// or as a socks5:// URL.
+func newSocksProxy(config *ConnConfig) (*socks.Proxy, error) {
+	addr := config.Proxy
+	if strings.Contains(addr, "://") {
+		proxyURL, err := url.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+		if proxyURL.Scheme != "socks5" && proxyURL.Scheme != "socks5h" {
+			return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+		}
+		addr = proxyURL.Host
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("proxy address: %v", err)
+	}
+
+	proxy := &socks.Proxy{
+		Addr:     addr,
+		Username: config.ProxyUser,
+		Password: config.ProxyPass,
+	}
+	if config.TorIsolation && config.ProxyUser == "" {
+		// Tor isolates streams using different SOCKS credentials.
+		var isolation [sha256.Size]byte
+		if config.IsolationKey != nil {
+			isolation = sha256.Sum256(config.IsolationKey)
+		} else {
+			_, err := rand.Read(isolation[:])
+			if err != nil {
+				return nil, err
+			}
+		}
+		proxy.Username = hex.EncodeToString(isolation[:8])
+		proxy.Password = hex.EncodeToString(isolation[8:16])
+	}
+	return proxy, nil
+}
+
+// proxyDialContext returns a dial function for http.Transport.DialContext
+// connecting through proxy.  The proxy has no context aware dial, so the dial
+// is bounded by the deadline of the context and abandoned when the context is
+// canceled.
+func proxyDialContext(proxy *socks.Proxy) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		type dialResult struct {
+			conn net.Conn
+			err  error
+		}
+		done := make(chan dialResult, 1)
+		go func() {
+			var r dialResult
+			if deadline, ok := ctx.Deadline(); ok {
+				r.conn, r.err = proxy.DialTimeout(network, addr, time.Until(deadline))
+			} else {
+				r.conn, r.err = proxy.Dial(network, addr)
+			}
+			done <- r
+		}()
+		select {
+		case r := <-done:
+			return r.conn, r.err
+		case <-ctx.Done():
+			// Close the connection if the dial completes anyway.
+			go func() {
+				if r := <-done; r.conn != nil {
+					r.conn.Close()
+				}
+			}()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// newTLSConfig returns the TLS configuration described by the certificate
+// settings in the connection configuration.
+func newTLSConfig(config *ConnConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if len(config.Certificates) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(config.Certificates) {
+			return nil, errors.New("no valid certificates found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(config.ClientCertificate) > 0 || len(config.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(config.ClientCertificate, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(config.CertificateSHA256) > 0 {
+		if len(config.CertificateSHA256) != sha256.Size {
+			return nil, fmt.Errorf("certificate fingerprint must be %d "+
+				"bytes", sha256.Size)
+		}
+		pin := config.CertificateSHA256
+
+		// Without a CA the pin replaces the chain verification,
+		// otherwise it is checked after the chain has been verified.
+		tlsConfig.InsecureSkipVerify = len(config.Certificates) == 0
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("server presented no certificate")
+			}
+			fingerprint := sha256.Sum256(rawCerts[0])
+			if !bytes.Equal(fingerprint[:], pin) {
+				return fmt.Errorf("server certificate fingerprint %x "+
+					"does not match pinned fingerprint %x",
+					fingerprint, pin)
+			}
+			return nil
+		}
+	}
+
+	return tlsConfig, nil
+}
+
 // New creates a new RPC client based on the provided connection configuration
 // details.
 func New(config *ConnConfig) (*Client, error) {
@@ -259,6 +569,97 @@ func New(config *ConnConfig) (*Client, error) {
 	return client, nil
 }
 
+// ElectrumVersion returns the version of the Electrum daemon the requests of
+// the client are adapted to, querying it when no request has been sent yet.
+// An UnsupportedVersionError is returned when the version is not supported.
+func (c *Client) ElectrumVersion() (string, error) {
+	_, err := c.detectAdapter(context.Background())
+	if err != nil {
+		return "", err
+	}
+	c.adapterMtx.Lock()
+	defer c.adapterMtx.Unlock()
+	return c.version, nil
+}
+
+// detectAdapter returns the adapter for the version of the Electrum daemon,
+// which is queried the first time unless it is configured.  The version
+// request is sent directly rather than through the workers, which may all be
+// waiting for the adapter.  Failures are not remembered, so a daemon that
+// was unreachable is queried again by the next request.
+func (c *Client) detectAdapter(ctx context.Context) (*adapter, error) {
+	c.detectMtx.Lock()
+	defer c.detectMtx.Unlock()
+	if a := c.currentAdapter(); a != nil {
+		return a, nil
+	}
+
+	version := c.config.ElectrumVersion
+	if version == "" {
+		marshalledJSON, err := marshalCmd(c.NextID(), NewVersionCmd(), false)
+		if err != nil {
+			return nil, err
+		}
+		res, _, err := c.doPost(&jsonRequest{
+			ctx:            ctx,
+			method:         "version",
+			marshalledJSON: marshalledJSON,
+		})
+		if err == nil {
+			err = json.Unmarshal(res, &version)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to query Electrum version: %w", err)
+		}
+	}
+	a, err := adapterForVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Electrum version %s, using the %s adapter", version, a.name)
+	c.adapterMtx.Lock()
+	c.version = version
+	c.adapter = a
+	c.adapterMtx.Unlock()
+	return a, nil
+}
+
+// currentAdapter returns the adapter detected for the daemon, or nil when it
+// has not been detected yet.
+func (c *Client) currentAdapter() *adapter {
+	c.adapterMtx.Lock()
+	defer c.adapterMtx.Unlock()
+	return c.adapter
+}
+
+// marshalRequest marshals the command of jReq for the daemon, detecting the
+// adapter first.  Raw requests and batches are marshalled when they are
+// created.  The version command is encoded the same by every adapter, so it is
+// sent without detecting one.
+func (c *Client) marshalRequest(jReq *jsonRequest) error {
+	if jReq.marshalledJSON != nil {
+		if jReq.method == "version" || jReq.batch {
+			return nil
+		}
+		_, err := c.detectAdapter(jReq.ctx)
+		return err
+	}
+	namedParams := false
+	if jReq.method != "version" {
+		a, err := c.detectAdapter(jReq.ctx)
+		if err != nil {
+			return err
+		}
+		namedParams = a.namedParams
+	}
+	marshalledJSON, err := marshalCmd(jReq.id, jReq.cmd, namedParams)
+	if err != nil {
+		return err
+	}
+	jReq.marshalledJSON = marshalledJSON
+	return nil
+}
+
 // removeAllRequests removes all the jsonRequests which contain the response
 // channels for outstanding requests.
 //
@@ -319,16 +720,20 @@ func (c *Client) WaitForShutdown() {
 	c.wg.Wait()
 }
 
-// start begins processing input and output messages.
+// start begins processing input and output messages with a pool of workers,
+// one for each request that may be in flight.
 func (c *Client) start() {
-	c.wg.Add(1)
-	go c.sendPostHandler()
+	workers := c.config.concurrency()
+	c.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go c.sendPostHandler()
+	}
 }
 
-// sendPostHandler handles all outgoing messages when the client is running
-// in HTTP POST mode.  It uses a buffered channel to serialize output messages
-// while allowing the sender to continue running asynchronously.  It must be run
-// as a goroutine.
+// sendPostHandler handles outgoing messages when the client is running in
+// HTTP POST mode.  Several handlers receive from the same buffered channel, so
+// requests are sent in parallel while allowing the sender to continue running
+// asynchronously.  It must be run as a goroutine.
 func (c *Client) sendPostHandler() {
 out:
 	for {
@@ -358,29 +763,90 @@ cleanup:
 			break cleanup
 		}
 	}
+	c.httpClient.CloseIdleConnections()
 	c.wg.Done()
 
 }
 
 // handleSendPostMessage handles performing the passed HTTP request, reading the
 // result, unmarshalling it, and delivering the unmarshalled result to the
-// provided response channel.
+// provided response channel.  Requests of idempotent methods that fail without
+// a response from the server are retried with exponential backoff.
 func (c *Client) handleSendPostMessage(details *sendPostDetails) {
 	jReq := details.jsonRequest
-	log.Tracef("Sending command [%s] with id %d", jReq.method, jReq.id)
-	httpResponse, err := c.httpClient.Do(details.httpRequest)
+	retries := 0
+	if idempotentMethods[jReq.method] {
+		retries = c.config.MaxRetries
+	}
+	backoff := c.config.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+
+	err := c.marshalRequest(jReq)
 	if err != nil {
 		jReq.responseChan <- &response{err: err}
 		return
 	}
 
+	for attempt := 0; ; attempt++ {
+		res, retry, err := c.doPost(jReq)
+		if !retry || attempt >= retries {
+			jReq.responseChan <- &response{result: res, err: err, client: c}
+			return
+		}
+
+		log.Debugf("Retrying command [%s] with id %d in %v: %v",
+			jReq.method, jReq.id, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-jReq.ctx.Done():
+			jReq.responseChan <- &response{err: jReq.ctx.Err()}
+			return
+		case <-c.shutdown:
+			jReq.responseChan <- &response{err: ErrClientShutdown}
+			return
+		}
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// doPost performs a single HTTP POST of the passed request and returns the
+// unmarshalled result.  retry reports whether the request failed without a
+// JSON-RPC response, so it may be sent again.
+func (c *Client) doPost(jReq *jsonRequest) (result []byte, retry bool, err error) {
+	ctx := jReq.ctx
+	if c.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+	}
+
+	httpReq, err := c.newHTTPRequest(ctx, jReq)
+	if err != nil {
+		return nil, false, err
+	}
+
+	log.Tracef("Sending command [%s] with id %d", jReq.method, jReq.id)
+	httpResponse, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		// Only retry when the caller has not given up on the request.
+		return nil, jReq.ctx.Err() == nil, err
+	}
+
 	// Read the raw bytes and close the response.
 	respBytes, err := ioutil.ReadAll(httpResponse.Body)
 	httpResponse.Body.Close()
 	if err != nil {
 		err = fmt.Errorf("error reading json reply: %v", err)
-		jReq.responseChan <- &response{err: err}
-		return
+		return nil, jReq.ctx.Err() == nil, err
+	}
+
+	if jReq.batch {
+		return batchResult(httpResponse.StatusCode, respBytes)
 	}
 
 	// Try to unmarshal the response as a regular JSON-RPC response.
@@ -389,31 +855,151 @@ func (c *Client) handleSendPostMessage(details *sendPostDetails) {
 	if err != nil {
 		// When the response itself isn't a valid JSON-RPC response
 		// return an error which includes the HTTP status code and raw
-		// response bytes.
+		// response bytes.  Server errors, such as those of a proxy in
+		// front of a restarting daemon, are worth retrying.
 		err = fmt.Errorf("status code: %d, response: %q",
 			httpResponse.StatusCode, string(respBytes))
-		jReq.responseChan <- &response{err: err}
-		return
+		return nil, httpResponse.StatusCode >= 500, err
 	}
 
+	res, err := c.decodeResult(jReq.method, resp)
+	return res, false, err
+}
+
+// decodeResult returns the result of the passed response to a call of
+// method, normalized by the adapter for the Electrum version of the server.
+func (c *Client) decodeResult(method string, resp rawResponse) ([]byte, error) {
 	res, err := resp.result()
-	jReq.responseChan <- &response{result: res, err: err}
+	if err != nil {
+		return nil, err
+	}
+	a := c.currentAdapter()
+	if a == nil || a.normalize == nil {
+		return res, nil
+	}
+	return a.normalize(method, res)
 }
 
-// sendCmd sends the passed command to the associated server and returns a
-// response channel on which the reply will be delivered at some point in the
-// future.  It handles both websocket and HTTP POST mode depending on the
-// configuration of the client.
-func (c *Client) sendCmd(cmd interface{}) chan *response {
+// decodeAddress decodes an address returned by the daemon with the
+// DecodeAddress function of the configuration, if any.
+func (c *Client) decodeAddress(addr string) (btcutil.Address, error) {
+	if c.config.DecodeAddress != nil {
+		return c.config.DecodeAddress(addr)
+	}
+	return decodeAddress(addr)
+}
+
+// encodeAddress encodes an address passed to the daemon with the
+// EncodeAddress function of the configuration, if any.
+func (c *Client) encodeAddress(addr btcutil.Address) string {
+	if c.config.EncodeAddress != nil {
+		return c.config.EncodeAddress(addr)
+	}
+	return addr.EncodeAddress()
+}
+
+// decodeWIF decodes a private key returned by the daemon with the DecodeWIF
+// function of the configuration, if any.
+func (c *Client) decodeWIF(wif string) (*btcutil.WIF, error) {
+	if c.config.DecodeWIF != nil {
+		return c.config.DecodeWIF(wif)
+	}
+	return btcutil.DecodeWIF(wif)
+}
+
+// sendCmdContext sends the passed command to the associated server and returns
+// a response channel on which the reply will be delivered at some point in the
+// future.  The request is canceled and the context error delivered when ctx is
+// done.
+func (c *Client) sendCmdContext(ctx context.Context, cmd interface{}) chan *response {
+	jReq, err := c.newJSONRequest(ctx, cmd)
+	if err != nil {
+		return newFutureError(err)
+	}
+	c.sendPost(jReq)
+
+	return withContext(ctx, jReq.responseChan)
+}
+
+// newJSONRequest returns a request for the passed command with a channel to
+// respond on.  The command is marshalled by marshalRequest once the adapter
+// for the daemon is known.
+func (c *Client) newJSONRequest(ctx context.Context, cmd interface{}) (*jsonRequest, error) {
 	// Get the method associated with the command.
 	method, _, err := CmdMethod(cmd)
 	if err != nil {
-		return newFutureError(err)
+		return nil, err
+	}
+
+	return &jsonRequest{
+		ctx:          ctx,
+		id:           c.NextID(),
+		method:       method,
+		cmd:          cmd,
+		responseChan: make(chan *response, 1),
+	}, nil
+}
+
+// withContext returns a response channel delivering the response from
+// responseChan, or the context error as soon as ctx is done, even while the
+// request is still queued.
+func withContext(ctx context.Context, responseChan chan *response) chan *response {
+	if ctx.Done() == nil {
+		return responseChan
+	}
+	ctxChan := make(chan *response, 1)
+	go func() {
+		select {
+		case r := <-responseChan:
+			ctxChan <- r
+		case <-ctx.Done():
+			ctxChan <- &response{err: ctx.Err()}
+		}
+	}()
+	return ctxChan
+}
+
+// FutureRawResult is a future promise to deliver the result of a RawRequest
+// RPC invocation (or an applicable error).
+type FutureRawResult chan *response
+
+// Receive waits for the response promised by the future and returns the raw
+// response, or an error if the request was unsuccessful.
+func (r FutureRawResult) Receive() (json.RawMessage, error) {
+	return receiveFuture(r)
+}
+
+// RawRequestAsync returns an instance of a type that can be used to get the
+// result of a custom RPC request at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See RawRequest for the blocking version and more details.
+func (c *Client) RawRequestAsync(method string, params []json.RawMessage) FutureRawResult {
+	return c.RawRequestAsyncContext(context.Background(), method, params)
+}
+
+// RawRequestAsyncContext is like RawRequestAsync but the request is canceled
+// and the future returns the context error when ctx is done.
+func (c *Client) RawRequestAsyncContext(ctx context.Context, method string, params []json.RawMessage) FutureRawResult {
+	// Method may not be empty.
+	if method == "" {
+		return newFutureError(errors.New("no method"))
+	}
+
+	// Marshal parameters as "[]" instead of "null" when no parameters
+	// are passed.
+	if params == nil {
+		params = []json.RawMessage{}
 	}
 
-	// Marshal the command.
 	id := c.NextID()
-	marshalledJSON, err := MarshalCmd(id, cmd)
+	rawRequest := &Request{
+		Jsonrpc: "1.0",
+		ID:      id,
+		Method:  method,
+		Params:  params,
+	}
+	marshalledJSON, err := json.Marshal(rawRequest)
 	if err != nil {
 		return newFutureError(err)
 	}
@@ -421,24 +1007,58 @@ func (c *Client) sendCmd(cmd interface{}) chan *response {
 	// Generate the request and send it along with a channel to respond on.
 	responseChan := make(chan *response, 1)
 	jReq := &jsonRequest{
+		ctx:            ctx,
 		id:             id,
 		method:         method,
-		cmd:            cmd,
+		cmd:            nil,
 		marshalledJSON: marshalledJSON,
 		responseChan:   responseChan,
 	}
 	c.sendPost(jReq)
 
-	return responseChan
+	return withContext(ctx, responseChan)
+}
+
+// RawRequest allows the caller to send a raw or custom request to the server.
+// This method may be used to send and receive requests and responses for
+// requests that are not handled by this client package, or to proxy partially
+// unmarshaled requests to another JSON-RPC server if a request cannot be
+// handled directly.
+func (c *Client) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	return c.RawRequestAsync(method, params).Receive()
+}
+
+// RawRequestContext is like RawRequest but gives up and returns the context
+// error when ctx is done.
+func (c *Client) RawRequestContext(ctx context.Context, method string, params []json.RawMessage) (json.RawMessage, error) {
+	return c.RawRequestAsyncContext(ctx, method, params).Receive()
 }
 
-// sendPost sends the passed request to the server by issuing an HTTP POST
-// request using the provided response channel for the reply.  Typically a new
-// connection is opened and closed for each command when using this method,
-// however, the underlying HTTP client might coalesce multiple commands
-// depending on several factors including the remote server configuration.
+// sendPost queues the passed request for sending to the server by issuing an
+// HTTP POST request using the provided response channel for the reply.
+// Connections are kept alive and reused by the workers sending the requests,
+// up to the configured concurrency.  It is backed by a buffered channel, so it
+// will not block until the send channel is full.
 func (c *Client) sendPost(jReq *jsonRequest) {
-	// Generate a request to the configured RPC server.
+	// Don't send the message if shutting down.
+	select {
+	case <-c.shutdown:
+		jReq.responseChan <- &response{result: nil, err: ErrClientShutdown}
+		return
+	default:
+	}
+
+	select {
+	case c.sendPostChan <- &sendPostDetails{jsonRequest: jReq}:
+	case <-c.shutdown:
+		jReq.responseChan <- &response{result: nil, err: ErrClientShutdown}
+	}
+}
+
+// newHTTPRequest returns an HTTP POST request of the passed JSON-RPC request to
+// the configured RPC server.  A new request is created for every attempt since
+// the body can only be read once.
+func (c *Client) newHTTPRequest(ctx context.Context, jReq *jsonRequest) (*http.Request, error) {
 	protocol := "http"
 	if !c.config.DisableTLS {
 		protocol = "https"
@@ -447,34 +1067,14 @@ func (c *Client) sendPost(jReq *jsonRequest) {
 	bodyReader := bytes.NewReader(jReq.marshalledJSON)
 	httpReq, err := http.NewRequest("POST", url, bodyReader)
 	if err != nil {
-		jReq.responseChan <- &response{result: nil, err: err}
-		return
+		return nil, err
 	}
-	httpReq.Close = true
+	httpReq = httpReq.WithContext(ctx)
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// Configure basic access authorization.
 	httpReq.SetBasicAuth(c.config.User, c.config.Pass)
-
-	log.Tracef("Sending command [%s] with id %d", jReq.method, jReq.id)
-	c.sendPostRequest(httpReq, jReq)
-}
-
-// sendPostRequest sends the passed HTTP request to the RPC server using the
-// HTTP client associated with the client.  It is backed by a buffered channel,
-// so it will not block until the send channel is full.
-func (c *Client) sendPostRequest(httpReq *http.Request, jReq *jsonRequest) {
-	// Don't send the message if shutting down.
-	select {
-	case <-c.shutdown:
-		jReq.responseChan <- &response{result: nil, err: ErrClientShutdown}
-	default:
-	}
-
-	c.sendPostChan <- &sendPostDetails{
-		jsonRequest: jReq,
-		httpRequest: httpReq,
-	}
+	return httpReq, nil
 }
 
 // newFutureError returns a new future result channel that already has the
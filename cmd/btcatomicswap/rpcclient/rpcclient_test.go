@@ -0,0 +1,544 @@
+package rpcclient
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// testTimeout bounds every wait of the tests so a broken client fails the
+// test instead of hanging it.
+const testTimeout = 5 * time.Second
+
+// respondVersion answers every request with the version of Electrum 4.
+func respondVersion(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID json.RawMessage `json:"id"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	w.Write([]byte(`{"id":` + string(req.ID) + `,"result":"4.1.5","error":null}`))
+}
+
+func TestCertificatePin(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(respondVersion))
+	t.Cleanup(srv.Close)
+	fingerprint := sha256.Sum256(srv.Certificate().Raw)
+	otherFingerprint := sha256.Sum256([]byte("another certificate"))
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	tests := []struct {
+		name         string
+		certificates []byte
+		pin          []byte
+		err          string
+	}{
+		{
+			name: "pinned self-signed certificate",
+			pin:  fingerprint[:],
+		},
+		{
+			name: "pin mismatch",
+			pin:  otherFingerprint[:],
+			err:  "does not match pinned fingerprint",
+		},
+		{
+			name: "unknown authority",
+			err:  "certificate",
+		},
+		{
+			name:         "verified and pinned certificate",
+			certificates: ca,
+			pin:          fingerprint[:],
+		},
+		{
+			// The pin is checked even when the chain is valid.
+			name:         "verified certificate with pin mismatch",
+			certificates: ca,
+			pin:          otherFingerprint[:],
+			err:          "does not match pinned fingerprint",
+		},
+	}
+	for _, test := range tests {
+		c, err := New(&ConnConfig{
+			Host:              strings.TrimPrefix(srv.URL, "https://"),
+			Certificates:      test.certificates,
+			CertificateSHA256: test.pin,
+			HTTPPostMode:      true,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		_, err = c.Version()
+		c.Shutdown()
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: error is %v, want %s", test.name, err, test.err)
+		}
+	}
+
+	// A truncated fingerprint is rejected before connecting.
+	_, err := New(&ConnConfig{
+		Host:              strings.TrimPrefix(srv.URL, "https://"),
+		CertificateSHA256: fingerprint[:20],
+		HTTPPostMode:      true,
+	})
+	if err == nil {
+		t.Error("truncated fingerprint was accepted")
+	}
+}
+
+// socksCredentials are the username and password a client authenticated to
+// the fake SOCKS 5 proxy with.
+type socksCredentials struct {
+	username, password string
+}
+
+// newFakeSocksProxy returns the address of a SOCKS 5 proxy requiring username
+// and password authentication, and the channel the credentials of each
+// connection are delivered on.  Connections are closed after authentication,
+// so requests made through the proxy fail.
+func newFakeSocksProxy(t *testing.T) (string, <-chan socksCredentials) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	creds := make(chan socksCredentials, 16)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(testTimeout))
+				// Greeting: version, number of methods and methods.
+				buf := make([]byte, 255)
+				if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+					return
+				}
+				conn.Write([]byte{5, 2})
+				// Username and password authentication (RFC 1929).
+				readField := func() (string, error) {
+					if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+						return "", err
+					}
+					n := buf[0]
+					if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+						return "", err
+					}
+					return string(buf[:n]), nil
+				}
+				if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+					return
+				}
+				username, err := readField()
+				if err != nil {
+					return
+				}
+				password, err := readField()
+				if err != nil {
+					return
+				}
+				creds <- socksCredentials{username, password}
+				conn.Write([]byte{1, 1})
+			}()
+		}
+	}()
+	return l.Addr().String(), creds
+}
+
+// proxyCredentials returns the credentials the client configured with
+// config authenticates to the proxy with.
+func proxyCredentials(t *testing.T, config *ConnConfig, creds <-chan socksCredentials) socksCredentials {
+	t.Helper()
+	config.Host = "electrum.example.com:7777"
+	config.DisableTLS = true
+	config.HTTPPostMode = true
+	config.ElectrumVersion = "4.1.5"
+	c, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Shutdown()
+	_, err = c.Version()
+	if err == nil {
+		t.Fatal("request succeeded through the failing proxy")
+	}
+	select {
+	case cred := <-creds:
+		return cred
+	case <-time.After(testTimeout):
+		t.Fatal("client did not authenticate to the proxy")
+		return socksCredentials{}
+	}
+}
+
+func TestTorIsolation(t *testing.T) {
+	proxy, creds := newFakeSocksProxy(t)
+	isolated := func(key []byte) socksCredentials {
+		t.Helper()
+		return proxyCredentials(t, &ConnConfig{
+			Proxy:        proxy,
+			TorIsolation: true,
+			IsolationKey: key,
+		}, creds)
+	}
+
+	// The credentials are derived from the key, so the requests of a swap
+	// share a circuit that is not shared with other swaps.
+	first := isolated([]byte("swap 1"))
+	if first.username == "" || first.password == "" {
+		t.Fatalf("empty isolation credentials %+v", first)
+	}
+	if again := isolated([]byte("swap 1")); again != first {
+		t.Errorf("credentials of the same swap differ: %+v and %+v", first, again)
+	}
+	if other := isolated([]byte("swap 2")); other == first {
+		t.Errorf("credentials of different swaps are both %+v", first)
+	}
+
+	// Without a key every client uses its own circuit.
+	if isolated(nil) == isolated(nil) {
+		t.Error("clients without an isolation key share credentials")
+	}
+
+	// Configured credentials are not replaced.
+	configured := proxyCredentials(t, &ConnConfig{
+		Proxy:        "socks5://" + proxy,
+		ProxyUser:    "user",
+		ProxyPass:    "pass",
+		TorIsolation: true,
+		IsolationKey: []byte("swap 1"),
+	}, creds)
+	if configured != (socksCredentials{"user", "pass"}) {
+		t.Errorf("configured credentials were replaced with %+v", configured)
+	}
+}
+
+func TestProxyAddress(t *testing.T) {
+	tests := []struct {
+		proxy string
+		valid bool
+	}{
+		{"127.0.0.1:9050", true},
+		{"socks5://127.0.0.1:9050", true},
+		{"socks5h://localhost:9050", true},
+		{"http://127.0.0.1:8080", false},
+		{"127.0.0.1", false},
+	}
+	for _, test := range tests {
+		_, err := newSocksProxy(&ConnConfig{Proxy: test.proxy})
+		if (err == nil) != test.valid {
+			t.Errorf("%s: error is %v", test.proxy, err)
+		}
+	}
+}
+
+// newFlakyDaemon returns a server failing the first failures requests of
+// each method with a server error that is not a JSON-RPC response, then
+// answering them with a JSON-RPC error.  The returned function reports the
+// number of requests received for a method.
+func newFlakyDaemon(t *testing.T, failures int) (*httptest.Server, func(method string) int) {
+	var mtx sync.Mutex
+	requests := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		mtx.Lock()
+		requests[req.Method]++
+		n := requests[req.Method]
+		mtx.Unlock()
+		if n <= failures {
+			http.Error(w, "daemon restarting", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"id":` + string(req.ID) + `,"result":null,` +
+			`"error":{"code":-32000,"message":"failed"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func(method string) int {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return requests[method]
+	}
+}
+
+func TestRetries(t *testing.T) {
+	const maxRetries = 3
+	addr, err := btcutil.DecodeAddress(testAddress, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outPoint := &wire.OutPoint{Hash: chainhash.Hash{1}}
+
+	tests := []struct {
+		method string
+		call   func(c *Client) error
+		retry  bool
+	}{
+		{"getbalance", func(c *Client) error { _, err := c.GetBalance(); return err }, true},
+		{"listunspent", func(c *Client) error { _, err := c.ListUnspent(); return err }, true},
+		{"getprivatekeys", func(c *Client) error { _, err := c.DumpPrivKey(addr); return err }, true},
+		{"payto", func(c *Client) error { _, _, err := c.PayTo(addr, 1e5, 0, false); return err }, false},
+		{"paytomany", func(c *Client) error {
+			_, _, err := c.PayToMany(map[btcutil.Address]btcutil.Amount{addr: 1e5}, 0, false)
+			return err
+		}, false},
+		{"broadcast", func(c *Client) error { _, err := c.Broadcast(wire.NewMsgTx(wire.TxVersion)); return err }, false},
+		{"freeze_utxo", func(c *Client) error { _, err := c.FreezeUTXO(outPoint); return err }, false},
+		{"load_wallet", func(c *Client) error { return c.LoadWallet("/wallets/default_wallet") }, false},
+	}
+	for _, failures := range []int{1, maxRetries + 1} {
+		srv, requests := newFlakyDaemon(t, failures)
+		c, err := New(&ConnConfig{
+			Host:            strings.TrimPrefix(srv.URL, "http://"),
+			DisableTLS:      true,
+			HTTPPostMode:    true,
+			ElectrumVersion: "4.1.5",
+			MaxRetries:      maxRetries,
+			RetryBackoff:    time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, test := range tests {
+			err := test.call(c)
+			if err == nil {
+				t.Errorf("%s: call succeeded", test.method)
+				continue
+			}
+
+			// Only methods reading state are sent again, until the
+			// server answers or the retries are exhausted.  The
+			// JSON-RPC error answering them is not retried.
+			want := 1
+			if test.retry {
+				want = failures + 1
+				if failures > maxRetries {
+					want = maxRetries + 1
+				}
+			}
+			if n := requests(test.method); n != want {
+				t.Errorf("%d failures: %s sent %d times, want %d", failures,
+					test.method, n, want)
+			}
+			var rpcErr *RPCError
+			gotRPCError := errors.As(err, &rpcErr)
+			if gotRPCError != (test.retry && failures <= maxRetries) {
+				t.Errorf("%d failures: %s: error is %v", failures, test.method, err)
+			}
+		}
+		c.Shutdown()
+	}
+}
+
+// newBlockingDaemon returns a server holding every request until release is
+// closed, and a function returning the number of requests it is holding and
+// the highest number it held at the same time.
+func newBlockingDaemon(t *testing.T, release <-chan struct{}) (*httptest.Server, func() (inFlight, maxInFlight int)) {
+	var mtx sync.Mutex
+	var inFlight, maxInFlight int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mtx.Unlock()
+		defer func() {
+			mtx.Lock()
+			inFlight--
+			mtx.Unlock()
+		}()
+		<-release
+		respondVersion(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() (int, int) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return inFlight, maxInFlight
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	const requests = 10
+	for _, concurrency := range []int{0, 1, 3} {
+		release := make(chan struct{})
+		srv, inFlight := newBlockingDaemon(t, release)
+		c, err := New(&ConnConfig{
+			Host:            strings.TrimPrefix(srv.URL, "http://"),
+			DisableTLS:      true,
+			HTTPPostMode:    true,
+			ElectrumVersion: "4.1.5",
+			Concurrency:     concurrency,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := concurrency
+		if want == 0 {
+			want = defaultConcurrency
+		}
+
+		futures := make([]FutureVersionResult, requests)
+		for i := range futures {
+			futures[i] = c.VersionAsync()
+		}
+
+		// The workers fill up, and the other requests stay queued
+		// while they are busy.
+		deadline := time.Now().Add(testTimeout)
+		for n, _ := inFlight(); n < want; n, _ = inFlight() {
+			if time.Now().After(deadline) {
+				t.Fatalf("concurrency %d: only %d requests in flight", concurrency, n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if n, _ := inFlight(); n != want {
+			t.Errorf("concurrency %d: %d requests in flight, want %d", concurrency, n, want)
+		}
+
+		close(release)
+		for i, f := range futures {
+			_, err := f.Receive()
+			if err != nil {
+				t.Errorf("concurrency %d: request %d: %v", concurrency, i, err)
+			}
+		}
+		if _, max := inFlight(); max != want {
+			t.Errorf("concurrency %d: %d requests were in flight at once, want %d",
+				concurrency, max, want)
+		}
+		c.Shutdown()
+	}
+}
+
+func TestBatchFallback(t *testing.T) {
+	results := map[string]string{
+		"getbalance":  `{"confirmed":"0.5","unconfirmed":"0"}`,
+		"listunspent": `[]`,
+		"getinfo":     `{"server":"electrum.example.com","connected":true}`,
+	}
+	type request struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	respond := func(req *request) string {
+		return `{"id":` + string(req.ID) + `,"result":` + results[req.Method] + `,"error":null}`
+	}
+
+	for _, batches := range []bool{false, true} {
+		var mtx sync.Mutex
+		var batchRequests, singleRequests int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var batch []*request
+			if json.Unmarshal(body, &batch) == nil {
+				mtx.Lock()
+				batchRequests++
+				mtx.Unlock()
+				if !batches {
+					// Old daemons answer arrays with a single error.
+					w.Write([]byte(`{"id":null,"result":null,` +
+						`"error":{"code":-32600,"message":"Invalid Request"}}`))
+					return
+				}
+				responses := make([]string, len(batch))
+				for i, req := range batch {
+					responses[i] = respond(req)
+				}
+				w.Write([]byte("[" + strings.Join(responses, ",") + "]"))
+				return
+			}
+			var req request
+			err = json.Unmarshal(body, &req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mtx.Lock()
+			singleRequests++
+			mtx.Unlock()
+			w.Write([]byte(respond(&req)))
+		}))
+		c, err := New(&ConnConfig{
+			Host:            strings.TrimPrefix(srv.URL, "http://"),
+			DisableTLS:      true,
+			HTTPPostMode:    true,
+			ElectrumVersion: "4.1.5",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The second batch is sent individually right away when the
+		// server rejected the first.
+		for i := 0; i < 2; i++ {
+			b := c.NewBatch()
+			balance := b.GetBalanceAsync()
+			unspent := b.ListUnspentAsync()
+			info := b.GetInfoAsync()
+			err = b.Send()
+			if err != nil {
+				t.Fatalf("batches %v: %v", batches, err)
+			}
+			if r, err := balance.Receive(); err != nil || r.Confirmed != 5e7 {
+				t.Errorf("batches %v: getbalance returned %v, %v", batches, r, err)
+			}
+			if r, err := unspent.Receive(); err != nil || len(r) != 0 {
+				t.Errorf("batches %v: listunspent returned %v, %v", batches, r, err)
+			}
+			if r, err := info.Receive(); err != nil || !r.Connected {
+				t.Errorf("batches %v: getinfo returned %v, %v", batches, r, err)
+			}
+		}
+
+		mtx.Lock()
+		wantBatches, wantSingles := 1, 6
+		if batches {
+			wantBatches, wantSingles = 2, 0
+		}
+		if batchRequests != wantBatches || singleRequests != wantSingles {
+			t.Errorf("batches %v: %d batch and %d single requests, want %d and %d",
+				batches, batchRequests, singleRequests, wantBatches, wantSingles)
+		}
+		mtx.Unlock()
+		c.Shutdown()
+		srv.Close()
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// batchSwap describes a single contract of a batchinitiate command.  The
+// secret hash is only set when participating in a swap initiated by the
+// counterparty.
+type batchSwap struct {
+	them       [ripemd160.Size]byte
+	amount     btcutil.Amount
+	secretHash []byte
+}
+
+type batchInitiateCmd struct {
+	swaps []*batchSwap
+}
+
+// parseBatchSwap decodes a batchinitiate argument of the form
+// <address>,<amount>[,<secret hash>].
+func parseBatchSwap(arg string) (*batchSwap, error) {
+	fields := strings.Split(arg, ",")
+	if len(fields) != 2 && len(fields) != 3 {
+		return nil, fmt.Errorf("invalid swap %q: expected <address>,<amount>[,<secret hash>]", arg)
+	}
+
+	addr, err := decodeAddress(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode counterparty address: %v", err)
+	}
+	if !addr.IsForNet(chainParams) {
+		return nil, fmt.Errorf("counterparty address is not "+
+			"intended for use on %v", chainParams.Name)
+	}
+	addrP2PKH, err := atomicswap.KeyHashAddress(addr, chainParams)
+	if err != nil {
+		return nil, fmt.Errorf("counterparty address: %v", err)
+	}
+
+	amountF64, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode amount: %v", err)
+	}
+	amount, err := btcutil.NewAmount(amountF64)
+	if err != nil {
+		return nil, err
+	}
+
+	swap := &batchSwap{them: *addrP2PKH.Hash160(), amount: amount}
+	if len(fields) == 3 {
+		swap.secretHash, err = hex.DecodeString(fields[2])
+		if err != nil {
+			return nil, errors.New("secret hash must be hex encoded")
+		}
+		if len(swap.secretHash) != sha256.Size {
+			return nil, errors.New("secret hash has wrong size")
+		}
+	}
+	return swap, nil
+}
+
+// batchContract houses one of the contracts paid by a batch contract
+// transaction together with its refund transaction.
+type batchContract struct {
+	secret       []byte
+	secretHash   []byte
+	contract     *atomicswap.Contract
+	contractAddr btcutil.Address
+	refundTx     *wire.MsgTx
+	refundFee    btcutil.Amount
+}
+
+// buildBatchContracts creates a contract for each swap and pays them all with a
+// single transaction funded and signed by the wallet.  Every contract refunds
+// to its own new wallet address, so the swaps of a batch are not linked by
+// their refund keys.  The inputs of the contract transaction stay reserved
+// until it is published or released.
+func buildBatchContracts(w Wallet, swaps []*batchSwap) (contractTx *wire.MsgTx,
+	contractFee btcutil.Amount, contracts []*batchContract, err error) {
+
+	contracts = make([]*batchContract, len(swaps))
+	amounts := make(map[btcutil.Address]btcutil.Amount, len(swaps))
+	var total btcutil.Amount
+	now := time.Now()
+	for i, swap := range swaps {
+		bc := &batchContract{secretHash: swap.secretHash}
+
+		refundAddr, err := getUnusedAddress(w)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("getunusedaddress: %w", err)
+		}
+
+		// Swaps without a secret hash are initiated by us and use the
+		// initiator's locktime, the others are participations.
+		// locktime after 500,000,000 (Tue Nov  5 00:53:20 1985 UTC) is
+		// interpreted as a unix time rather than a block height.
+		locktime := now.Add(24 * time.Hour).Unix()
+		if bc.secretHash == nil {
+			var secret [atomicswap.SecretSize]byte
+			_, err := rand.Read(secret[:])
+			if err != nil {
+				return nil, 0, nil, err
+			}
+			bc.secret = secret[:]
+			bc.secretHash = sha256Hash(secret[:])
+			locktime = now.Add(48 * time.Hour).Unix()
+		}
+
+		bc.contract, err = atomicswap.NewContract(refundAddr.Hash160(), &swap.them,
+			locktime, bc.secretHash)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		if segWitContracts {
+			bc.contractAddr, err = bc.contract.WitnessAddress(chainParams)
+		} else {
+			bc.contractAddr, err = bc.contract.Address(chainParams)
+		}
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		for addr := range amounts {
+			if addr.EncodeAddress() == bc.contractAddr.EncodeAddress() {
+				return nil, 0, nil, fmt.Errorf("swap %d duplicates the contract of an earlier swap", i+1)
+			}
+		}
+		amounts[bc.contractAddr] = swap.amount
+		total += swap.amount
+		contracts[i] = bc
+	}
+
+	feePerKb, err := getFeePerKb(w)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	rw := &reservingWallet{Wallet: w}
+	contractTx, contractFee, err = rw.PayTo(amounts, feePerKb)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("payToMany: %w", err)
+	}
+	err = feePolicy.CheckFee("contract", int64(contractFee), int64(total))
+	if err != nil {
+		rw.releaseFunded()
+		return nil, 0, nil, err
+	}
+
+	// BuildRefund locates the contract output by its script, so every
+	// contract gets its own refund spending only its own output.
+	builder := swapBuilder()
+	for _, bc := range contracts {
+		bc.refundTx, bc.refundFee, err = builder.BuildRefund(w, bc.contract, contractTx, feePerKb)
+		if err != nil {
+			rw.releaseFunded()
+			return nil, 0, nil, err
+		}
+	}
+	return contractTx, contractFee, contracts, nil
+}
+
+func (cmd *batchInitiateCmd) runCommand(w Wallet) error {
+	contractTx, contractFee, contracts, err := buildBatchContracts(w, cmd.swaps)
+	if err != nil {
+		return err
+	}
+
+	contractTxHash := txHash(contractTx)
+	contractFeePerKb := calcFeePerKb(contractFee, atomicswap.VirtualSize(contractTx))
+
+	fmt.Printf("Contract fee: %v (%0.8f %s/kB)\n\n", formatAmount(contractFee), contractFeePerKb, coinSymbol)
+	for i, bc := range contracts {
+		refundTxHash := txHash(bc.refundTx)
+		refundFeePerKb := calcFeePerKb(bc.refundFee, atomicswap.VirtualSize(bc.refundTx))
+
+		fmt.Printf("Swap %d:\n", i+1)
+		if bc.secret != nil {
+			fmt.Printf("Secret:      %x\n", bc.secret)
+		}
+		fmt.Printf("Secret hash: %x\n\n", bc.secretHash)
+		fmt.Printf("Refund fee:   %v (%0.8f %s/kB)\n\n", formatAmount(bc.refundFee), refundFeePerKb, coinSymbol)
+		fmt.Printf("Contract (%v):\n", addrString(bc.contractAddr))
+		fmt.Printf("%x\n\n", bc.contract.Script)
+		var refundBuf bytes.Buffer
+		refundBuf.Grow(bc.refundTx.SerializeSize())
+		bc.refundTx.Serialize(&refundBuf)
+		fmt.Printf("Refund transaction (%v):\n", &refundTxHash)
+		fmt.Printf("%x\n\n", refundBuf.Bytes())
+	}
+	var contractBuf bytes.Buffer
+	contractBuf.Grow(contractTx.SerializeSize())
+	contractTx.Serialize(&contractBuf)
+	fmt.Printf("Contract transaction (%v):\n", &contractTxHash)
+	fmt.Printf("%x\n\n", contractBuf.Bytes())
+
+	return promptPublishContract(w, contractTx)
+}
+
+type batchRedeemCmd struct {
+	spends []*atomicswap.Spend
+}
+
+type batchRefundCmd struct {
+	spends []*atomicswap.Spend
+}
+
+// parseBatchSpend decodes a batchredeem argument of the form
+// <contract>,<contract transaction>,<secret> or, when withSecret is false, a
+// batchrefund argument of the form <contract>,<contract transaction>.
+func parseBatchSpend(arg string, withSecret bool) (*atomicswap.Spend, error) {
+	fields := strings.Split(arg, ",")
+	if withSecret && len(fields) != 3 {
+		return nil, errors.New("expected <contract>,<contract transaction>,<secret>")
+	}
+	if !withSecret && len(fields) != 2 {
+		return nil, errors.New("expected <contract>,<contract transaction>")
+	}
+
+	script, err := hex.DecodeString(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode contract: %v", err)
+	}
+	contract, err := atomicswap.ParseContract(script)
+	if err != nil {
+		return nil, err
+	}
+
+	contractTxBytes, err := hex.DecodeString(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode contract transaction: %v", err)
+	}
+	var contractTx wire.MsgTx
+	err = contractTx.Deserialize(bytes.NewReader(contractTxBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode contract transaction: %v", err)
+	}
+
+	spend := &atomicswap.Spend{Contract: contract, ContractTx: &contractTx}
+	if withSecret {
+		spend.Secret, err = hex.DecodeString(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode secret: %v", err)
+		}
+	}
+	return spend, nil
+}
+
+func (cmd *batchRedeemCmd) runCommand(w Wallet) error {
+	feePerKb, err := getFeePerKb(w)
+	if err != nil {
+		return err
+	}
+
+	redeemTx, fee, err := swapBuilder().BuildBatchRedeem(w, cmd.spends, feePerKb)
+	if err != nil {
+		return err
+	}
+
+	redeemTxHash := txHash(redeemTx)
+	redeemFeePerKb := calcFeePerKb(fee, atomicswap.VirtualSize(redeemTx))
+
+	var buf bytes.Buffer
+	buf.Grow(redeemTx.SerializeSize())
+	redeemTx.Serialize(&buf)
+	fmt.Printf("Redeem fee: %v (%0.8f %s/kB)\n\n", formatAmount(fee), redeemFeePerKb, coinSymbol)
+	fmt.Printf("Redeem transaction (%v):\n", &redeemTxHash)
+	fmt.Printf("%x\n\n", buf.Bytes())
+
+	return promptPublishTx(w, redeemTx, "redeem")
+}
+
+func (cmd *batchRefundCmd) runCommand(w Wallet) error {
+	feePerKb, err := getFeePerKb(w)
+	if err != nil {
+		return err
+	}
+
+	refundTx, fee, err := swapBuilder().BuildBatchRefund(w, cmd.spends, feePerKb)
+	if err != nil {
+		return err
+	}
+
+	refundTxHash := txHash(refundTx)
+	refundFeePerKb := calcFeePerKb(fee, atomicswap.VirtualSize(refundTx))
+
+	var buf bytes.Buffer
+	buf.Grow(refundTx.SerializeSize())
+	refundTx.Serialize(&buf)
+	fmt.Printf("Refund fee: %v (%0.8f %s/kB)\n\n", formatAmount(fee), refundFeePerKb, coinSymbol)
+	fmt.Printf("Refund transaction (%v):\n", &refundTxHash)
+	fmt.Printf("%x\n\n", buf.Bytes())
+
+	return promptPublishTx(w, refundTx, "refund")
+}
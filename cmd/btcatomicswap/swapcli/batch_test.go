@@ -0,0 +1,433 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+	"github.com/robvanmieghem/electrumatomicswap/feepolicy"
+)
+
+// sameAddressWallet is a memWallet handing out the same address on every
+// request.
+type sameAddressWallet struct {
+	*memWallet
+	addr btcutil.Address
+}
+
+func (w *sameAddressWallet) NewAddress() (btcutil.Address, error) {
+	return w.addr, nil
+}
+
+// useTestFeePolicy replaces the fee policy with p until the test ends.
+func useTestFeePolicy(t *testing.T, p feepolicy.Policy) {
+	old := feePolicy
+	feePolicy = p
+	t.Cleanup(func() { feePolicy = old })
+}
+
+func TestParseBatchSwap(t *testing.T) {
+	hash := bytes.Repeat([]byte{0x01}, 20)
+	pkh, _ := btcutil.NewAddressPubKeyHash(hash, chainParams)
+	wpkh, _ := btcutil.NewAddressWitnessPubKeyHash(hash, chainParams)
+	sh, _ := btcutil.NewAddressScriptHashFromHash(hash, chainParams)
+	testnet, _ := btcutil.NewAddressPubKeyHash(hash, &chaincfg.TestNet3Params)
+	secretHash := strings.Repeat("ab", 32)
+
+	swap, err := parseBatchSwap(pkh.EncodeAddress() + ",1.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(swap.them[:], hash) || swap.amount != 1.5e8 || swap.secretHash != nil {
+		t.Fatalf("parsed %x %v %x", swap.them, swap.amount, swap.secretHash)
+	}
+	swap, err = parseBatchSwap(pkh.EncodeAddress() + ",0.1," + secretHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(swap.secretHash) != secretHash {
+		t.Fatalf("secret hash is %x", swap.secretHash)
+	}
+
+	// Contracts pay to key hashes, so a P2WPKH address is as good.
+	swap, err = parseBatchSwap(wpkh.EncodeAddress() + ",1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(swap.them[:], hash) {
+		t.Fatalf("parsed key hash %x, want %x", swap.them, hash)
+	}
+
+	tests := []struct {
+		name string
+		arg  string
+	}{
+		{"missing amount", pkh.EncodeAddress()},
+		{"extra field", pkh.EncodeAddress() + ",1," + secretHash + ",1"},
+		{"invalid address", "1invalid,1"},
+		{"testnet address", testnet.EncodeAddress() + ",1"},
+		{"P2SH address", sh.EncodeAddress() + ",1"},
+		{"invalid amount", pkh.EncodeAddress() + ",one"},
+		{"secret hash not hex", pkh.EncodeAddress() + ",1,xyz"},
+		{"short secret hash", pkh.EncodeAddress() + ",1,abcd"},
+	}
+	for _, test := range tests {
+		_, err := parseBatchSwap(test.arg)
+		if err == nil {
+			t.Errorf("%s: %q parsed", test.name, test.arg)
+		}
+	}
+}
+
+// newTestBatchSwaps returns an initiated and a participated swap with new
+// addresses of the participant's wallet.
+func newTestBatchSwaps(t *testing.T, participant Wallet) []*batchSwap {
+	var swaps []*batchSwap
+	for _, secretHash := range [][]byte{nil, bytes.Repeat([]byte{0x2a}, 32)} {
+		addr, err := getUnusedAddress(participant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		swaps = append(swaps, &batchSwap{
+			them:       *addr.Hash160(),
+			amount:     1e8,
+			secretHash: secretHash,
+		})
+	}
+	return swaps
+}
+
+func TestBuildBatchContracts(t *testing.T) {
+	useTestReservations(t)
+	initiator := fundedMemWallet(t, 3e8)
+	participant := newMemWallet(testFeePerKb)
+	swaps := newTestBatchSwaps(t, participant)
+
+	contractTx, _, contracts, err := buildBatchContracts(initiator, swaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contracts) != len(swaps) {
+		t.Fatalf("%d contracts, want %d", len(contracts), len(swaps))
+	}
+	if contracts[0].secret == nil || contracts[1].secret != nil {
+		t.Fatal("secrets are only created for initiated swaps")
+	}
+	if !bytes.Equal(contracts[1].secretHash, swaps[1].secretHash) {
+		t.Fatal("participation does not use the secret hash of the counterparty")
+	}
+
+	refunds := make(map[[20]byte]bool)
+	for i, bc := range contracts {
+		// Every contract refunds to its own key of the wallet.
+		refundHash := bc.contract.RefundHash160
+		if refunds[refundHash] {
+			t.Errorf("contract %d reuses a refund address", i)
+		}
+		refunds[refundHash] = true
+		refundAddr, err := btcutil.NewAddressPubKeyHash(refundHash[:], chainParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := initiator.keys[refundAddr.EncodeAddress()]; !ok {
+			t.Errorf("contract %d refunds to %v, not an address of the wallet", i, refundAddr)
+		}
+		if bc.contract.RecipientHash160 != swaps[i].them {
+			t.Errorf("contract %d pays to %x", i, bc.contract.RecipientHash160)
+		}
+
+		// The refund only spends the output of its own contract.
+		if len(bc.refundTx.TxIn) != 1 {
+			t.Fatalf("refund %d spends %d inputs", i, len(bc.refundTx.TxIn))
+		}
+		contractOut := contractTx.TxOut[bc.refundTx.TxIn[0].PreviousOutPoint.Index]
+		pkScript, err := txscript.PayToAddrScript(bc.contractAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(contractOut.PkScript, pkScript) {
+			t.Errorf("refund %d does not spend its contract", i)
+		}
+		verifyInputs(t, bc.refundTx, contractTx)
+	}
+
+	r, err := loadReservations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, txIn := range contractTx.TxIn {
+		if r.owner(txIn.PreviousOutPoint) == "" {
+			t.Errorf("input %v is not reserved", txIn.PreviousOutPoint)
+		}
+	}
+}
+
+func TestBuildBatchContractsDuplicate(t *testing.T) {
+	useTestReservations(t)
+	initiator := fundedMemWallet(t, 3e8)
+	refundAddr, err := initiator.NewAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &sameAddressWallet{memWallet: initiator, addr: refundAddr}
+	participant := newMemWallet(testFeePerKb)
+	swap := newTestBatchSwaps(t, participant)[1]
+
+	// Two participations with the same counterparty, secret hash and
+	// refund address would pay the same contract twice.
+	_, _, _, err = buildBatchContracts(w, []*batchSwap{swap, swap})
+	if err == nil || !strings.Contains(err.Error(), "duplicates the contract") {
+		t.Fatalf("error is %v", err)
+	}
+	r, err := loadReservations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 0 {
+		t.Fatalf("reservations after a failed batch: %v", r)
+	}
+}
+
+func TestBuildBatchContractsSegWitWallet(t *testing.T) {
+	useTestReservations(t)
+	initiator := fundedMemWallet(t, 3e8)
+	keyAddr, err := initiator.NewAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := keyAddr.(*btcutil.AddressPubKeyHash).Hash160()
+	refundAddr, err := btcutil.NewAddressWitnessPubKeyHash(hash[:], chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &sameAddressWallet{memWallet: initiator, addr: refundAddr}
+	participant := newMemWallet(testFeePerKb)
+
+	// The contracts refund to the key hash of the P2WPKH wallet address,
+	// and the refunds are signed by its key.
+	_, _, contracts, err := buildBatchContracts(w, newTestBatchSwaps(t, participant))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, bc := range contracts {
+		if bc.contract.RefundHash160 != *hash {
+			t.Errorf("contract %d refunds to %x, want %x", i, bc.contract.RefundHash160, *hash)
+		}
+	}
+}
+
+func TestBuildBatchContractsFeeCeiling(t *testing.T) {
+	useTestReservations(t)
+	useTestFeePolicy(t, feepolicy.Policy{
+		ConfTarget: feepolicy.DefaultConfTarget,
+		MinFeeRate: feepolicy.DefaultMinFeeRate,
+		MaxFee:     0.00000001,
+	})
+	initiator := fundedMemWallet(t, 3e8)
+	participant := newMemWallet(testFeePerKb)
+
+	_, _, _, err := buildBatchContracts(initiator, newTestBatchSwaps(t, participant))
+	var ceilingErr *feepolicy.CeilingError
+	if !errors.As(err, &ceilingErr) {
+		t.Fatalf("error is %v, want a fee ceiling error", err)
+	}
+
+	// The funded inputs are released when the fee is refused.
+	r, err := loadReservations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 0 {
+		t.Fatalf("reservations after a refused fee: %v", r)
+	}
+	for outPoint, locked := range initiator.locked {
+		if locked {
+			t.Errorf("input %v is still locked", outPoint)
+		}
+	}
+}
+
+// newTestSpends builds a contract paying amount to the participant for each
+// of the locktimes, funded by the initiator, and returns their spends with
+// the secret of the contracts.
+func newTestSpends(t *testing.T, initiator, participant Wallet, amount btcutil.Amount,
+	lockTimes ...int64) []*atomicswap.Spend {
+
+	spends := make([]*atomicswap.Spend, len(lockTimes))
+	for i, lockTime := range lockTimes {
+		args, secret := newTestContractArgs(t, participant, amount)
+		args.LockTime = lockTime
+		b, err := buildContract(initiator, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		spends[i] = &atomicswap.Spend{Contract: b.Contract, ContractTx: b.ContractTx, Secret: secret}
+	}
+	return spends
+}
+
+// verifyBatchInputs executes the scripts of every input of tx, which spends
+// the contracts of spends in order.
+func verifyBatchInputs(t *testing.T, tx *wire.MsgTx, spends []*atomicswap.Spend) {
+	t.Helper()
+	for i, spend := range spends {
+		prevOut := spend.ContractTx.TxOut[tx.TxIn[i].PreviousOutPoint.Index]
+		vm, err := txscript.NewEngine(prevOut.PkScript, tx, i,
+			txscript.StandardVerifyFlags, nil, nil, prevOut.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = vm.Execute()
+		if err != nil {
+			t.Fatalf("input %d: %v", i, err)
+		}
+	}
+}
+
+func TestParseBatchSpend(t *testing.T) {
+	useTestReservations(t)
+	initiator := fundedMemWallet(t, 2e8)
+	participant := newMemWallet(testFeePerKb)
+	spend := newTestSpends(t, initiator, participant, 1e8, time.Now().Add(48*time.Hour).Unix())[0]
+
+	var buf bytes.Buffer
+	err := spend.ContractTx.Serialize(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract := hex.EncodeToString(spend.Contract.Script)
+	contractTx := hex.EncodeToString(buf.Bytes())
+	secret := hex.EncodeToString(spend.Secret)
+
+	parsed, err := parseBatchSpend(contract+","+contractTx+","+secret, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(parsed.Contract.Script, spend.Contract.Script) ||
+		parsed.ContractTx.TxHash() != spend.ContractTx.TxHash() ||
+		!bytes.Equal(parsed.Secret, spend.Secret) {
+		t.Fatal("parsed spend differs")
+	}
+	parsed, err = parseBatchSpend(contract+","+contractTx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Secret != nil {
+		t.Fatal("refund spend has a secret")
+	}
+
+	tests := []struct {
+		name       string
+		arg        string
+		withSecret bool
+	}{
+		{"redeem without secret", contract + "," + contractTx, true},
+		{"refund with secret", contract + "," + contractTx + "," + secret, false},
+		{"contract not hex", "xyz," + contractTx, false},
+		{"not a contract", "76a9," + contractTx, false},
+		{"transaction not hex", contract + ",xyz", false},
+		{"truncated transaction", contract + "," + contractTx[:20], false},
+		{"secret not hex", contract + "," + contractTx + ",xyz", true},
+	}
+	for _, test := range tests {
+		_, err := parseBatchSpend(test.arg, test.withSecret)
+		if err == nil {
+			t.Errorf("%s: parsed", test.name)
+		}
+	}
+}
+
+func TestBatchRedeemAndRefund(t *testing.T) {
+	useTestReservations(t)
+	initiator := fundedMemWallet(t, 2e8, 2e8)
+	participant := newMemWallet(testFeePerKb)
+	now := time.Now()
+	lockTimes := []int64{now.Add(72 * time.Hour).Unix(), now.Add(48 * time.Hour).Unix()}
+	spends := newTestSpends(t, initiator, participant, 1e8, lockTimes...)
+
+	redeemTx, fee, err := swapBuilder().BuildBatchRedeem(participant, spends, testFeePerKb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(redeemTx.TxIn) != 2 || len(redeemTx.TxOut) != 1 || redeemTx.TxOut[0].Value != 2e8-int64(fee) {
+		t.Fatal("redeem does not sweep both contracts into one output")
+	}
+	verifyBatchInputs(t, redeemTx, spends)
+
+	// The refund can only be published once the latest locktime is reached.
+	refundTx, _, err := swapBuilder().BuildBatchRefund(initiator, spends, testFeePerKb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(refundTx.LockTime) != lockTimes[0] {
+		t.Fatalf("refund locktime is %d, want %d", refundTx.LockTime, lockTimes[0])
+	}
+	for i, txIn := range refundTx.TxIn {
+		if txIn.Sequence != 0 {
+			t.Errorf("refund input %d has sequence %d", i, txIn.Sequence)
+		}
+	}
+	verifyBatchInputs(t, refundTx, spends)
+}
+
+func TestBatchSpendRejections(t *testing.T) {
+	useTestReservations(t)
+	initiator := fundedMemWallet(t, 2e8, 2e8, 2e8)
+	participant := newMemWallet(testFeePerKb)
+	lockTime := time.Now().Add(48 * time.Hour).Unix()
+	spends := newTestSpends(t, initiator, participant, 1e8, lockTime)
+
+	segWitContracts = true
+	defer func() { segWitContracts = false }()
+	witnessSpends := newTestSpends(t, initiator, participant, 1e8, lockTime)
+	segWitContracts = false
+
+	_, _, err := swapBuilder().BuildBatchRedeem(participant,
+		[]*atomicswap.Spend{spends[0], witnessSpends[0]}, testFeePerKb)
+	if err == nil || !strings.Contains(err.Error(), "mix P2SH and P2WSH") {
+		t.Errorf("mixed redeem: error is %v", err)
+	}
+	_, _, err = swapBuilder().BuildBatchRefund(initiator,
+		[]*atomicswap.Spend{witnessSpends[0], spends[0]}, testFeePerKb)
+	if err == nil || !strings.Contains(err.Error(), "mix P2SH and P2WSH") {
+		t.Errorf("mixed refund: error is %v", err)
+	}
+
+	_, _, err = swapBuilder().BuildBatchRedeem(participant,
+		[]*atomicswap.Spend{spends[0], spends[0]}, testFeePerKb)
+	if err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Errorf("duplicate outpoint: error is %v", err)
+	}
+}
+
+func TestBatchSpendDust(t *testing.T) {
+	useTestReservations(t)
+	initiator := fundedMemWallet(t, 2e8, 2e8)
+	participant := newMemWallet(testFeePerKb)
+	lockTime := time.Now().Add(48 * time.Hour).Unix()
+	spends := newTestSpends(t, initiator, participant, 10000, lockTime, lockTime)
+
+	// At this fee rate the fee leaves less than the dust limit of the
+	// sweeping output.
+	const feePerKb = 25000
+	_, _, err := swapBuilder().BuildBatchRedeem(participant, spends, feePerKb)
+	if !errors.Is(err, atomicswap.ErrDust) {
+		t.Errorf("redeem: error is %v, want %v", err, atomicswap.ErrDust)
+	}
+	_, _, err = swapBuilder().BuildBatchRefund(initiator, spends, feePerKb)
+	if !errors.Is(err, atomicswap.ErrDust) {
+		t.Errorf("refund: error is %v, want %v", err, atomicswap.ErrDust)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"flag"
+	"strconv"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+	"github.com/robvanmieghem/electrumatomicswap/cashaddr"
+	"github.com/robvanmieghem/electrumatomicswap/coindef"
+	"github.com/robvanmieghem/electrumatomicswap/groestl"
+)
+
+var (
+	// coinSymbol is the symbol amounts are displayed with.
+	coinSymbol = "BTC"
+
+	// coinNetwork is the network of the coin loaded with -coin, nil for
+	// Bitcoin.
+	coinNetwork *coindef.Network
+
+	// dustFeePerKb is the fee rate outputs are checked against to not be
+	// dust.  Zero uses the fee rate of the transaction.
+	dustFeePerKb btcutil.Amount
+
+	// forkIDSigs selects SIGHASH_FORKID signatures for coins defined with
+	// the forkid sighash.
+	forkIDSigs bool
+
+	// segWitContracts pays contracts to P2WSH outputs for coins supporting
+	// segwit.
+	segWitContracts bool
+
+	// txHashes are the hashing primitives of coins hashing transactions
+	// and signatures differently than Bitcoin, and nil for the others.
+	txHashes *atomicswap.Hashes
+
+	// groestlChecksums selects the Groestl-512 checksums of base58
+	// addresses and private keys.
+	groestlChecksums bool
+
+	// cashAddrPrefix is the cashaddr prefix of the network of coins with
+	// cashaddr addresses, and empty for base58 addresses.
+	cashAddrPrefix string
+
+	// electrumVersion is the Electrum version the requests to the daemon
+	// are encoded for, or empty to query it.
+	electrumVersion string
+
+	// noFeeEstimates uses the minimum relay fee rate instead of querying
+	// the wallet for fee estimates.
+	noFeeEstimates bool
+)
+
+// loadCoin replaces Bitcoin with the coin defined in the file named by -coin,
+// or else by the default coin of the command, selecting its testnet with
+// -testnet.  A minimum relay fee rate of the coin applies unless -minfeerate
+// is set.
+func loadCoin() error {
+	var coin *coindef.Coin
+	var err error
+	if *coinFlag != "" {
+		coin, err = coindef.Load(*coinFlag)
+	} else {
+		coin, err = coindef.Parse(defaultCoin)
+	}
+	if err != nil {
+		return err
+	}
+	network := coindef.MainNet
+	if *testnetFlag {
+		network = coindef.TestNet
+	}
+	n, err := coin.Network(network)
+	if err != nil {
+		return err
+	}
+	params, err := n.Params()
+	if err != nil {
+		return err
+	}
+
+	chainParams = params
+	coinNetwork = n
+	coinSymbol = coin.Symbol
+	dustFeePerKb = btcutil.Amount(coin.DustFeePerKb())
+	forkIDSigs = coin.SigHash == coindef.SigHashForkID
+	segWitContracts = coin.HasFeature(coindef.FeatureSegWit)
+	if coin.TxHash == coindef.TxHashSHA256 {
+		txHashes = atomicswap.SingleSHA256
+	}
+	groestlChecksums = coin.Base58Checksum == coindef.ChecksumGroestl
+	if coin.AddressFormat == coindef.AddressCashAddr {
+		cashAddrPrefix = n.CashAddrPrefix
+	}
+	electrumVersion = coin.ElectrumVersion
+	noFeeEstimates = coin.NoFeeEstimates
+	if coin.MinRelayFee != 0 && !flagSet("minfeerate") {
+		feePolicy.MinFeeRate = coin.MinRelayFee
+	}
+	return nil
+}
+
+// flagSet returns whether the flag named name was set on the command line.
+func flagSet(name string) bool {
+	set := false
+	flagset.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// decodeAddress decodes an address of the network in use, in the cashaddr
+// format for coins with cashaddr addresses.  The cashaddr prefix of the
+// network may be omitted.
+func decodeAddress(addr string) (btcutil.Address, error) {
+	switch {
+	case cashAddrPrefix != "":
+		return cashaddr.DecodeAddress(addr, cashAddrPrefix, chainParams)
+	case groestlChecksums:
+		return groestl.DecodeAddress(addr, chainParams)
+	}
+	return btcutil.DecodeAddress(addr, chainParams)
+}
+
+// encodeAddress returns the base58 encoding of addr passed to the wallet, with
+// the checksum of the coin.
+func encodeAddress(addr btcutil.Address) string {
+	if !groestlChecksums {
+		return addr.EncodeAddress()
+	}
+	s, err := groestl.EncodeAddress(addr, chainParams)
+	if err != nil {
+		return addr.EncodeAddress()
+	}
+	return s
+}
+
+// addrString returns the encoding of addr displayed to the operator, which is
+// the cashaddr encoding for coins with cashaddr addresses.
+func addrString(addr btcutil.Address) string {
+	if cashAddrPrefix == "" {
+		return encodeAddress(addr)
+	}
+	s, err := cashaddr.EncodeAddress(addr, cashAddrPrefix)
+	if err != nil {
+		return addr.String()
+	}
+	return s
+}
+
+// decodeWIF decodes a private key of the network in use returned by the
+// wallet, with the base58 checksum of the coin.
+func decodeWIF(wif string) (*btcutil.WIF, error) {
+	if groestlChecksums {
+		return groestl.DecodeWIF(wif, chainParams)
+	}
+	return btcutil.DecodeWIF(wif)
+}
+
+// txHash returns the id of tx on the chain in use.
+func txHash(tx *wire.MsgTx) chainhash.Hash {
+	if txHashes != nil {
+		return txHashes.TxHash(tx)
+	}
+	return tx.TxHash()
+}
+
+// formatAmount formats an amount in whole coins of the coin in use, as
+// btcutil.Amount is always formatted in BTC.
+func formatAmount(amount btcutil.Amount) string {
+	return strconv.FormatFloat(amount.ToBTC(), 'f', -1, 64) + " " + coinSymbol
+}
+
+// dustRelayFeePerKb returns the fee rate outputs of transactions paying
+// feePerKb are checked against to not be dust.
+func dustRelayFeePerKb(feePerKb btcutil.Amount) btcutil.Amount {
+	if dustFeePerKb != 0 {
+		return dustFeePerKb
+	}
+	return feePerKb
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+	"github.com/robvanmieghem/electrumatomicswap/coins"
+	"github.com/robvanmieghem/electrumatomicswap/groestl"
+)
+
+// restoreBitcoin restores Bitcoin and the flags selecting the coin when the
+// test ends.
+func restoreBitcoin(t *testing.T) {
+	params, network, symbol, dustFee := chainParams, coinNetwork, coinSymbol, dustFeePerKb
+	forkID, segWit, prefix := forkIDSigs, segWitContracts, cashAddrPrefix
+	hashes, groestlSums := txHashes, groestlChecksums
+	version, noEstimates, minFeeRate := electrumVersion, noFeeEstimates, feePolicy.MinFeeRate
+	t.Cleanup(func() {
+		chainParams, coinNetwork, coinSymbol, dustFeePerKb = params, network, symbol, dustFee
+		forkIDSigs, segWitContracts, cashAddrPrefix = forkID, segWit, prefix
+		txHashes, groestlChecksums = hashes, groestlSums
+		electrumVersion, noFeeEstimates, feePolicy.MinFeeRate = version, noEstimates, minFeeRate
+		*coinFlag, defaultCoin = "", nil
+	})
+}
+
+// testCoinPath returns the path of the definition of the coins directory
+// named name.
+func testCoinPath(name string) string {
+	return filepath.Join("..", "..", "..", "coins", name)
+}
+
+// useTestCoin loads the definition of the coins directory named name as if
+// it was passed with -coin, and restores Bitcoin when the test ends.
+func useTestCoin(t *testing.T, name string) {
+	restoreBitcoin(t)
+	*coinFlag = testCoinPath(name)
+	err := loadCoin()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDefaultCoin checks that the coin of a command running with its own
+// definition is swapped unless -coin is set.
+func TestDefaultCoin(t *testing.T) {
+	restoreBitcoin(t)
+	defaultCoin = coins.BitcoinCash
+	err := loadCoin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coinSymbol != "BCH" || cashAddrPrefix != "bitcoincash" {
+		t.Fatalf("default coin loaded %s with cashaddr prefix %q", coinSymbol, cashAddrPrefix)
+	}
+
+	*coinFlag = testCoinPath("dash.json")
+	err = loadCoin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coinSymbol != "DASH" {
+		t.Fatalf("-coin loaded %s", coinSymbol)
+	}
+}
+
+func TestBitcoinCashCoin(t *testing.T) {
+	useTestCoin(t, "bitcoincash.json")
+
+	b := swapBuilder()
+	if !b.ForkID || b.SegWit || b.Hashes != nil {
+		t.Fatalf("builder signs with forkid %v, segwit %v, hashes %v", b.ForkID, b.SegWit, b.Hashes)
+	}
+	if electrumVersion != "3.0" || !noFeeEstimates {
+		t.Fatalf("Electrum version %q, fee estimates %v", electrumVersion, !noFeeEstimates)
+	}
+
+	const (
+		cashAddr = "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"
+		legacy   = "1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu"
+	)
+	for _, s := range []string{cashAddr, cashAddr[len("bitcoincash:"):]} {
+		addr, err := decodeAddress(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if addr.EncodeAddress() != legacy || !addr.IsForNet(chainParams) {
+			t.Fatalf("%s decoded to %v", s, addr)
+		}
+		if addrString(addr) != cashAddr {
+			t.Fatalf("%s is displayed as %s", s, addrString(addr))
+		}
+	}
+	_, err := decodeAddress(legacy)
+	if err == nil {
+		t.Fatal("legacy address decoded as cashaddr")
+	}
+}
+
+func TestDashCoin(t *testing.T) {
+	useTestCoin(t, "dash.json")
+
+	b := swapBuilder()
+	if b.ForkID || b.SegWit || b.Hashes != nil {
+		t.Fatalf("builder signs with forkid %v, segwit %v, hashes %v", b.ForkID, b.SegWit, b.Hashes)
+	}
+	if coinSymbol != "DASH" || dustFeePerKb != 1000 || feePolicy.MinFeeRate != 1 {
+		t.Fatalf("symbol %s, dust fee rate %v, minimum fee rate %v", coinSymbol, dustFeePerKb, feePolicy.MinFeeRate)
+	}
+
+	// A P2PKH address of the Dash mainnet starts with X.
+	addr, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := addrString(addr)
+	if s[0] != 'X' {
+		t.Fatalf("address %s is not a Dash address", s)
+	}
+
+	// The dust limit of a P2PKH output is the 546 duffs of Dash Core.
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !txrules.IsDustOutput(wire.NewTxOut(545, pkScript), dustFeePerKb) ||
+		txrules.IsDustOutput(wire.NewTxOut(546, pkScript), dustFeePerKb) {
+		t.Fatal("dust limit of a P2PKH output is not 546 duffs")
+	}
+	decoded, err := decodeAddress(s)
+	if err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	if !decoded.IsForNet(chainParams) {
+		t.Fatalf("%s decoded to %v", s, decoded)
+	}
+	_, err = decodeAddress("1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu")
+	if err == nil {
+		t.Fatal("Bitcoin address decoded as a Dash address")
+	}
+}
+
+func TestGroestlcoinCoin(t *testing.T) {
+	useTestCoin(t, "groestlcoin.json")
+	useTestReservations(t)
+
+	b := swapBuilder()
+	if b.Hashes != atomicswap.SingleSHA256 || b.ForkID || b.SegWit {
+		t.Fatalf("builder signs with forkid %v, segwit %v, hashes %v", b.ForkID, b.SegWit, b.Hashes)
+	}
+	if dustFeePerKb != 1000 {
+		t.Fatalf("dust fee rate %v", dustFeePerKb)
+	}
+
+	// A P2PKH address of the Groestlcoin mainnet starts with F, and its
+	// checksum is not the one of Bitcoin.
+	addr, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := addrString(addr)
+	if s[0] != 'F' || s == addr.EncodeAddress() {
+		t.Fatalf("address %s is not a Groestlcoin address", s)
+	}
+	decoded, err := decodeAddress(s)
+	if err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	if decoded.EncodeAddress() != addr.EncodeAddress() {
+		t.Fatalf("%s decoded to %v", s, decoded)
+	}
+	_, err = decodeAddress(addr.EncodeAddress())
+	if err == nil {
+		t.Fatal("address with a Bitcoin checksum decoded")
+	}
+
+	key := bytes.Repeat([]byte{1}, 32)
+	wif, err := decodeWIF(groestl.CheckEncode(append(key, 0x01), chainParams.PrivateKeyID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(wif.PrivKey.Serialize(), key) || !wif.CompressPubKey {
+		t.Fatalf("private key decoded to %x", wif.PrivKey.Serialize())
+	}
+
+	// The contract is spent by its Groestlcoin transaction id, with
+	// signatures over the single SHA256 signature hash.
+	initiator := fundedMemWallet(t, 2e8)
+	participant := newMemWallet(testFeePerKb)
+	args, secret := newTestContractArgs(t, participant, 1e8)
+	bc, err := buildContract(initiator, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redeemTx, _, err := b.BuildRedeem(participant, bc.Contract, bc.ContractTx, secret, testFeePerKb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contractTxHash := txHash(bc.ContractTx)
+	if contractTxHash == bc.ContractTx.TxHash() {
+		t.Fatal("contract transaction id is the Bitcoin transaction id")
+	}
+	for name, tx := range map[string]*wire.MsgTx{"redeem": redeemTx, "refund": bc.RefundTx} {
+		prevOut := tx.TxIn[0].PreviousOutPoint
+		if prevOut.Hash != contractTxHash {
+			t.Errorf("%s spends %v, want %v", name, prevOut.Hash, contractTxHash)
+		}
+		contractOut := bc.ContractTx.TxOut[prevOut.Index]
+		err = txHashes.VerifyInput(tx, 0, contractOut.PkScript, contractOut.Value)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
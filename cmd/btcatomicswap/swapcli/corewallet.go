@@ -0,0 +1,434 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	corerpc "github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+	rpc "github.com/robvanmieghem/electrumatomicswap/cmd/btcatomicswap/rpcclient"
+)
+
+// coreWallet is the Wallet of a Bitcoin Core node, used through its JSON-RPC
+// interface.  Methods missing from, or incompatible with current Bitcoin Core
+// versions in, the btcd/rpcclient package are implemented with raw requests.
+type coreWallet struct {
+	c *corerpc.Client
+}
+
+// coreUnlockTimeout is how long an encrypted Bitcoin Core wallet is unlocked
+// for when its password is passed.  The wallet is locked again when the
+// command completes.
+const coreUnlockTimeout = 10 * time.Minute
+
+// runCoreCommand runs cmd with the wallet of the Bitcoin Core node described
+// by config, which holds the connection settings of the wallet RPC flags.
+// Settings only supported by the Electrum client are rejected.
+func runCoreCommand(cmd command, config *rpc.ConnConfig) error {
+	if len(config.ClientCertificate) != 0 || len(config.CertificateSHA256) != 0 {
+		return errors.New("-backend=core does not support -rpcclientcert and -rpccertfingerprint")
+	}
+	if config.TorIsolation {
+		return errors.New("-backend=core does not support -torisolation")
+	}
+
+	host := config.Host
+	if config.Wallet != "" {
+		host += "/wallet/" + url.PathEscape(config.Wallet)
+	}
+	var proxy string
+	if config.Proxy != "" {
+		proxy = "socks5://" + config.Proxy
+		if config.ProxyUser != "" {
+			proxy = "socks5://" + url.UserPassword(config.ProxyUser,
+				config.ProxyPass).String() + "@" + config.Proxy
+		}
+	}
+	client, err := corerpc.New(&corerpc.ConnConfig{
+		Host:         host,
+		User:         config.User,
+		Pass:         config.Pass,
+		Proxy:        proxy,
+		DisableTLS:   config.DisableTLS,
+		Certificates: config.Certificates,
+		HTTPPostMode: true,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("rpc connect: %v", err)
+	}
+	defer func() {
+		client.Shutdown()
+		client.WaitForShutdown()
+	}()
+
+	if config.WalletPass != "" {
+		err = client.WalletPassphrase(config.WalletPass, int64(coreUnlockTimeout/time.Second))
+		if err != nil {
+			return fmt.Errorf("walletpassphrase: %w", err)
+		}
+		defer client.WalletLock()
+	}
+
+	return cmd.runCommand(&coreWallet{client})
+}
+
+// explainCoreError adds a hint on how to resolve err when it was caused by a
+// Bitcoin Core failure the operator can act on.
+func explainCoreError(err error) error {
+	var rpcErr *btcjson.RPCError
+	if !errors.As(err, &rpcErr) {
+		return err
+	}
+	var hint string
+	switch {
+	case errors.Is(err, rpc.ErrTxNonFinal):
+		// The error already says when the transaction becomes final.
+		return err
+	case errors.Is(err, rpc.ErrMissingInputs):
+		hint = "the spent outputs are unknown to the node or already spent"
+	case errors.Is(err, rpc.ErrMempoolConflict):
+		hint = "another transaction spending the same outputs is waiting to be mined"
+	case rpcErr.Code == btcjson.ErrRPCMethodNotFound.Code:
+		hint = "bitcoind does not support this command, upgrade Bitcoin Core"
+	case rpcErr.Code == btcjson.ErrRPCWalletNotFound, rpcErr.Code == btcjson.ErrRPCWalletNotSpecified:
+		hint = "load the wallet in bitcoind and select it with -wallet"
+	case rpcErr.Code == btcjson.ErrRPCWalletUnlockNeeded, rpcErr.Code == btcjson.ErrRPCWalletPassphraseIncorrect:
+		hint = "the wallet is encrypted, pass its password with -walletpassfile, " +
+			"-walletpassprompt or $" + walletPassEnv
+	case rpcErr.Code == btcjson.ErrRPCWalletInsufficientFunds:
+		hint = "the wallet balance does not cover the amount and fees"
+	case rpcErr.Code == btcjson.ErrRPCVerifyAlreadyInChain:
+		hint = "the transaction has already been published"
+	case rpcErr.Code == btcjson.ErrRPCVerifyRejected:
+		hint = "the transaction was rejected by the node's mempool policy"
+	default:
+		return err
+	}
+	return fmt.Errorf("%w (%s)", err, hint)
+}
+
+// coreError is an error of a Bitcoin Core RPC describing a failure the
+// Electrum client has an error kind for.  It matches the kind with errors.Is,
+// so the commands handle the failures of both backends alike.
+type coreError struct {
+	err  error
+	kind error
+}
+
+func (e *coreError) Error() string        { return e.err.Error() }
+func (e *coreError) Unwrap() error        { return e.err }
+func (e *coreError) Is(target error) bool { return target == e.kind }
+
+// classifyCoreError returns err matching the error kind of the rpcclient
+// package, such as rpc.ErrTxNonFinal, for the Bitcoin Core failure it
+// describes.  Bitcoin Core reports the failures of its mempool with the
+// messages the rpcclient package recognizes from the nodes behind Electrum
+// servers.
+func classifyCoreError(err error) error {
+	var rpcErr *btcjson.RPCError
+	if !errors.As(err, &rpcErr) {
+		return err
+	}
+	kind := rpc.RPCError{
+		Code:    rpc.RPCErrorCode(rpcErr.Code),
+		Message: rpcErr.Message,
+	}.Kind()
+	if kind == nil {
+		return err
+	}
+	return &coreError{err: err, kind: kind}
+}
+
+// NewAddress calls the getnewaddress JSON-RPC method.  It is implemented
+// manually as the rpcclient implementation can not request a legacy address,
+// which is required for the P2PKH contract refunds and redemptions.
+func (w *coreWallet) NewAddress() (btcutil.Address, error) {
+	params := []json.RawMessage{[]byte(`""`), []byte(`"legacy"`)}
+	rawResp, err := w.c.RawRequest("getnewaddress", params)
+	if err != nil {
+		return nil, err
+	}
+	var addrStr string
+	err = json.Unmarshal(rawResp, &addrStr)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAddress(addrStr)
+}
+
+// PayTo funds a transaction paying the amounts with fundrawtransaction and
+// signs it with the wallet.
+func (w *coreWallet) PayTo(amounts map[btcutil.Address]btcutil.Amount, feePerKb btcutil.Amount) (*wire.MsgTx, btcutil.Amount, error) {
+	outputs, err := sortedOutputs(amounts)
+	if err != nil {
+		return nil, 0, err
+	}
+	unsignedTx := wire.NewMsgTx(atomicswap.TxVersion)
+	for _, out := range outputs {
+		unsignedTx.AddTxOut(out)
+	}
+	unsignedTx, fee, err := w.fundRawTransaction(unsignedTx, feePerKb)
+	if err != nil {
+		return nil, 0, fmt.Errorf("fundrawtransaction: %v", err)
+	}
+	signedTx, err := w.signRawTransaction(unsignedTx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return signedTx, fee, nil
+}
+
+// signRawTransaction signs the inputs of tx with the wallet using the
+// signrawtransactionwithwallet JSON-RPC method, falling back to
+// signrawtransaction for Bitcoin Core versions older than 0.17.
+func (w *coreWallet) signRawTransaction(tx *wire.MsgTx) (*wire.MsgTx, error) {
+	method := "signrawtransactionwithwallet"
+	signedTx, complete, err := w.c.SignRawTransactionWithWallet(tx)
+	var rpcErr *btcjson.RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == btcjson.ErrRPCMethodNotFound.Code {
+		method = "signrawtransaction"
+		signedTx, complete, err = w.c.SignRawTransaction(tx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+	if !complete {
+		return nil, fmt.Errorf("%s: failed to completely sign transaction", method)
+	}
+	return signedTx, nil
+}
+
+// fundRawTransaction calls the fundrawtransaction JSON-RPC method.  It is
+// implemented manually as client support is currently missing from the
+// btcd/rpcclient package.
+func (w *coreWallet) fundRawTransaction(tx *wire.MsgTx, feePerKb btcutil.Amount) (fundedTx *wire.MsgTx, fee btcutil.Amount, err error) {
+	var buf bytes.Buffer
+	buf.Grow(tx.SerializeSize())
+	tx.Serialize(&buf)
+	param0, err := json.Marshal(hex.EncodeToString(buf.Bytes()))
+	if err != nil {
+		return nil, 0, err
+	}
+	param1, err := json.Marshal(struct {
+		FeeRate float64 `json:"feeRate"`
+	}{
+		FeeRate: feePerKb.ToBTC(),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	params := []json.RawMessage{param0, param1}
+	rawResp, err := w.c.RawRequest("fundrawtransaction", params)
+	if err != nil {
+		return nil, 0, err
+	}
+	var resp struct {
+		Hex       string  `json:"hex"`
+		Fee       float64 `json:"fee"`
+		ChangePos float64 `json:"changepos"`
+	}
+	err = json.Unmarshal(rawResp, &resp)
+	if err != nil {
+		return nil, 0, err
+	}
+	fundedTxBytes, err := hex.DecodeString(resp.Hex)
+	if err != nil {
+		return nil, 0, err
+	}
+	fundedTx = &wire.MsgTx{}
+	err = fundedTx.Deserialize(bytes.NewReader(fundedTxBytes))
+	if err != nil {
+		return nil, 0, err
+	}
+	feeAmount, err := btcutil.NewAmount(resp.Fee)
+	if err != nil {
+		return nil, 0, err
+	}
+	return fundedTx, feeAmount, nil
+}
+
+// CreateSig signs with a private key dumped from the wallet.  Due to
+// limitations of the Bitcoin Core RPC API, this requires dumping a private key
+// and signing in the client, rather than letting the wallet sign.  Dumping
+// keys is only supported by legacy (non-descriptor) wallets.
+func (w *coreWallet) CreateSig(tx *wire.MsgTx, idx int, pkScript []byte, addr btcutil.Address) (sig, pubkey []byte, err error) {
+	privKey, err := w.privKey(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signWithKey(tx, idx, pkScript, privKey)
+}
+
+func (w *coreWallet) CreateWitnessSig(tx *wire.MsgTx, idx int, script []byte, amount int64,
+	addr btcutil.Address) (sig, pubkey []byte, err error) {
+
+	privKey, err := w.privKey(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signWitnessWithKey(tx, idx, script, amount, privKey)
+}
+
+func (w *coreWallet) CreateForkIDSig(tx *wire.MsgTx, idx int, pkScript []byte, amount int64,
+	addr btcutil.Address) (sig, pubkey []byte, err error) {
+
+	privKey, err := w.privKey(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signForkIDWithKey(tx, idx, pkScript, amount, privKey)
+}
+
+// privKey dumps the private key of addr from the wallet.
+func (w *coreWallet) privKey(addr btcutil.Address) (*btcec.PrivateKey, error) {
+	wif, err := w.c.DumpPrivKey(addr)
+	if err != nil {
+		return nil, err
+	}
+	return wif.PrivKey, nil
+}
+
+// FeeRate queries the wallet for the transaction fee/kB to use.  It first
+// tries to get the user-set fee in the wallet.  If unset, it attempts to find
+// an estimate using estimatesmartfee for the confirmation target.  If both of
+// these fail, it falls back to mempool relay fee policy.  Failures to query
+// the relay fee and the wallet fee are returned.
+func (w *coreWallet) FeeRate(confTarget int) (btcutil.Amount, error) {
+	var netInfoResp struct {
+		RelayFee float64 `json:"relayfee"`
+	}
+	var walletInfoResp struct {
+		PayTxFee float64 `json:"paytxfee"`
+	}
+	var estimateResp struct {
+		FeeRate float64 `json:"feerate"`
+	}
+
+	netInfoRawResp, err := w.c.RawRequest("getnetworkinfo", nil)
+	if err != nil {
+		return 0, fmt.Errorf("getnetworkinfo: %w", err)
+	}
+	err = json.Unmarshal(netInfoRawResp, &netInfoResp)
+	if err != nil {
+		return 0, fmt.Errorf("getnetworkinfo: %v", err)
+	}
+	walletInfoRawResp, err := w.c.RawRequest("getwalletinfo", nil)
+	if err != nil {
+		return 0, fmt.Errorf("getwalletinfo: %w", err)
+	}
+	err = json.Unmarshal(walletInfoRawResp, &walletInfoResp)
+	if err != nil {
+		return 0, fmt.Errorf("getwalletinfo: %v", err)
+	}
+
+	relayFee, err := btcutil.NewAmount(netInfoResp.RelayFee)
+	if err != nil {
+		return 0, err
+	}
+	if relayFee <= 0 {
+		return 0, fmt.Errorf("getnetworkinfo: invalid relay fee %v", relayFee)
+	}
+	payTxFee, err := btcutil.NewAmount(walletInfoResp.PayTxFee)
+	if err != nil {
+		return 0, err
+	}
+
+	// Use user-set wallet fee when set and not lower than the network relay
+	// fee.
+	if payTxFee != 0 {
+		maxFee := payTxFee
+		if relayFee > maxFee {
+			maxFee = relayFee
+		}
+		return maxFee, nil
+	}
+
+	params := []json.RawMessage{[]byte(strconv.Itoa(confTarget))}
+	estimateRawResp, err := w.c.RawRequest("estimatesmartfee", params)
+	if err != nil {
+		return 0, err
+	}
+
+	err = json.Unmarshal(estimateRawResp, &estimateResp)
+	if err == nil && estimateResp.FeeRate > 0 {
+		useFee, err := btcutil.NewAmount(estimateResp.FeeRate)
+		if relayFee > useFee {
+			useFee = relayFee
+		}
+		return useFee, err
+	}
+
+	fmt.Println("warning: falling back to mempool relay fee policy")
+	return relayFee, nil
+}
+
+// Broadcast uses the sendrawtransaction JSON-RPC method.  Failures are
+// classified like those of the Electrum daemon.
+func (w *coreWallet) Broadcast(tx *wire.MsgTx) (*chainhash.Hash, error) {
+	txHash, err := w.c.SendRawTransaction(tx, false)
+	if err != nil {
+		return nil, classifyCoreError(err)
+	}
+	return txHash, nil
+}
+
+// Transaction uses the getrawtransaction JSON-RPC method, which requires the
+// node to index transactions unless the transaction is in the mempool or the
+// wallet.
+func (w *coreWallet) Transaction(txHash *chainhash.Hash) (*wire.MsgTx, error) {
+	tx, err := w.c.GetRawTransaction(txHash)
+	if err != nil {
+		return nil, err
+	}
+	return tx.MsgTx(), nil
+}
+
+// ListUnspent uses the listunspent JSON-RPC method.
+func (w *coreWallet) ListUnspent() ([]*unspentOutput, error) {
+	utxos, err := w.c.ListUnspent()
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]*unspentOutput, 0, len(utxos))
+	for _, utxo := range utxos {
+		txHash, err := chainhash.NewHashFromStr(utxo.TxID)
+		if err != nil {
+			return nil, err
+		}
+		value, err := btcutil.NewAmount(utxo.Amount)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, &unspentOutput{
+			OutPoint: *wire.NewOutPoint(txHash, utxo.Vout),
+			Value:    value,
+		})
+	}
+	return outputs, nil
+}
+
+// LockOutPoint uses the lockunspent JSON-RPC method.  Bitcoin Core forgets
+// the locks when it is restarted.
+func (w *coreWallet) LockOutPoint(outPoint *wire.OutPoint) error {
+	return w.c.LockUnspent(false, []*wire.OutPoint{outPoint})
+}
+
+// UnlockOutPoint uses the lockunspent JSON-RPC method.
+func (w *coreWallet) UnlockOutPoint(outPoint *wire.OutPoint) error {
+	return w.c.LockUnspent(true, []*wire.OutPoint{outPoint})
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	rpc "github.com/robvanmieghem/electrumatomicswap/cmd/btcatomicswap/rpcclient"
+)
+
+func TestClassifyCoreError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{
+			name: "non-final",
+			err:  btcjson.NewRPCError(btcjson.ErrRPCVerifyRejected, "non-final"),
+			kind: rpc.ErrTxNonFinal,
+		},
+		{
+			name: "non-BIP68-final",
+			err:  btcjson.NewRPCError(btcjson.ErrRPCVerifyRejected, "non-BIP68-final"),
+			kind: rpc.ErrTxNonFinal,
+		},
+		{
+			name: "missing inputs",
+			err:  btcjson.NewRPCError(btcjson.ErrRPCVerify, "bad-txns-inputs-missingorspent"),
+			kind: rpc.ErrMissingInputs,
+		},
+		{
+			name: "legacy missing inputs",
+			err:  btcjson.NewRPCError(btcjson.ErrRPCVerify, "Missing inputs"),
+			kind: rpc.ErrMissingInputs,
+		},
+		{
+			name: "unclassified",
+			err:  btcjson.NewRPCError(btcjson.ErrRPCVerifyRejected, "dust"),
+		},
+	}
+	for _, test := range tests {
+		err := classifyCoreError(test.err)
+		var rpcErr *btcjson.RPCError
+		if !errors.As(err, &rpcErr) {
+			t.Errorf("%s: classified error does not wrap the RPC error", test.name)
+		}
+		for _, kind := range []error{rpc.ErrTxNonFinal, rpc.ErrMissingInputs} {
+			if errors.Is(err, kind) != (kind == test.kind) {
+				t.Errorf("%s: errors.Is(%v) is %v", test.name, kind, !(kind == test.kind))
+			}
+		}
+
+		// The kind survives the wrapping by the commands.
+		wrapped := fmt.Errorf("sendrawtransaction: %w", err)
+		if test.kind != nil && !errors.Is(wrapped, test.kind) {
+			t.Errorf("%s: wrapped error does not match %v", test.name, test.kind)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+	rpc "github.com/robvanmieghem/electrumatomicswap/cmd/btcatomicswap/rpcclient"
+)
+
+// doctorPayToAmount is the amount of the unsigned payment used to exercise the
+// payto method.
+const doctorPayToAmount = btcutil.Amount(1e4)
+
+// maxSyncLag is the number of blocks the daemon may lag behind its server
+// before it is reported as not synchronised.
+const maxSyncLag = 2
+
+type doctorCmd struct{}
+
+// doctor reports the outcome of the environment checks.
+type doctor struct {
+	failures int
+}
+
+func (d *doctor) ok(format string, args ...interface{}) {
+	fmt.Printf("[ok]   "+format+"\n", args...)
+}
+
+func (d *doctor) warn(format string, args ...interface{}) {
+	fmt.Printf("[warn] "+format+"\n", args...)
+}
+
+func (d *doctor) fail(format string, args ...interface{}) {
+	d.failures++
+	fmt.Printf("[FAIL] "+format+"\n", args...)
+}
+
+// methodExists reports whether err is anything but a method not found error,
+// meaning the daemon knows the method even when the call itself failed.
+func methodExists(err error) bool {
+	return !errors.Is(err, rpc.ErrMethodNotFound)
+}
+
+func (cmd *doctorCmd) runCommand(w Wallet) error {
+	c, err := electrumClient(w)
+	if err != nil {
+		return err
+	}
+	d := &doctor{}
+
+	version, err := c.Version()
+	if err != nil {
+		d.fail("version: %v", err)
+		// Nothing else will work when the daemon can not be reached.
+		return fmt.Errorf("unable to reach the Electrum daemon at %v", *connectFlag)
+	}
+	d.ok("daemon version %v", version)
+
+	apiVersion, err := c.ElectrumVersion()
+	if err != nil {
+		d.fail("%v", err)
+		// Requests other than version can not be encoded for the daemon.
+		return fmt.Errorf("%d checks failed", d.failures)
+	}
+	if apiVersion != version {
+		d.ok("requests adapted to Electrum version %v", apiVersion)
+	}
+
+	info, err := c.GetInfo()
+	switch {
+	case err != nil:
+		d.fail("getinfo: %v", err)
+	case !info.Connected:
+		d.fail("daemon is not connected to an Electrum server")
+	default:
+		d.ok("connected to server %v", info.Server)
+		if info.ServerHeight-info.BlockchainHeight > maxSyncLag {
+			d.fail("daemon is not synchronised: local height %d, server height %d",
+				info.BlockchainHeight, info.ServerHeight)
+		} else {
+			d.ok("synchronised at height %d", info.BlockchainHeight)
+		}
+	}
+	if info != nil && info.Network != "" {
+		d.ok("daemon network %v", info.Network)
+	}
+
+	if *walletFlag != "" {
+		wallets, err := c.ListWallets()
+		if err != nil {
+			d.fail("list_wallets: %v", err)
+		} else if !walletLoaded(wallets, *walletFlag) {
+			d.fail("wallet %s is not loaded (use the loadwallet command)", *walletFlag)
+		} else {
+			d.ok("wallet %s is loaded", *walletFlag)
+		}
+	}
+
+	addr, err := c.GetUnusedAddress()
+	switch {
+	case err != nil:
+		d.fail("getunusedaddress: %v (is a wallet loaded?)", err)
+	case !addr.IsForNet(chainParams):
+		d.fail("wallet address %v is not intended for use on %v", addr, chainParams.Name)
+	default:
+		d.ok("wallet loaded, unused address %v on %v", addrString(addr), chainParams.Name)
+		if _, err := atomicswap.KeyHashAddress(addr, chainParams); err != nil {
+			d.fail("wallet %v", err)
+		}
+	}
+
+	if addr != nil {
+		_, err = c.DumpPrivKey(addr)
+		if err != nil {
+			d.fail("getprivatekeys: %v (is the wallet locked?)", err)
+		} else {
+			d.ok("getprivatekeys: wallet is unlocked")
+		}
+	}
+
+	balance, err := c.GetBalance()
+	if err != nil {
+		d.fail("getbalance: %v", err)
+	} else {
+		d.ok("balance %v confirmed, %v unconfirmed", formatAmount(balance.Confirmed), formatAmount(balance.Unconfirmed))
+		if balance.Confirmed == 0 {
+			d.warn("wallet has no confirmed balance to fund contracts")
+		}
+	}
+
+	feePerKb, err := c.GetFeeRate()
+	if err != nil {
+		d.fail("getfeerate: %v", err)
+	} else {
+		d.ok("getfeerate: %v/kB", formatAmount(feePerKb))
+	}
+
+	utxos, err := c.ListUnspent()
+	if err != nil {
+		d.fail("listunspent: %v", err)
+	} else {
+		d.ok("listunspent: %d unspent outputs", len(utxos))
+	}
+
+	// An unsigned payment to ourselves is never added to the wallet, so it
+	// exercises payto without side effects.
+	if addr != nil {
+		_, _, err = c.PayTo(addr, doctorPayToAmount, 0, true)
+		if err != nil && !methodExists(err) {
+			d.fail("payto: %v", err)
+		} else if err != nil {
+			d.warn("payto responded with: %v", err)
+		} else {
+			d.ok("payto")
+		}
+	}
+
+	// An empty transaction is always rejected, so broadcasting one only
+	// tells whether the method is available.
+	_, err = c.Broadcast(wire.NewMsgTx(atomicswap.TxVersion))
+	if err != nil && !methodExists(err) {
+		d.fail("broadcast: %v", err)
+	} else {
+		d.ok("broadcast")
+	}
+
+	// Calling these without parameters fails before they have any effect.
+	_, err = c.RawRequest("paytomany", nil)
+	if !methodExists(err) {
+		d.warn("paytomany is not supported, batchinitiate will not work")
+	}
+	_, err = c.RawRequest("freeze_utxo", nil)
+	if !methodExists(err) {
+		d.fail("freeze_utxo is not supported, contract inputs can not be reserved")
+	}
+
+	if d.failures != 0 {
+		return fmt.Errorf("%d checks failed", d.failures)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+	rpc "github.com/robvanmieghem/electrumatomicswap/cmd/btcatomicswap/rpcclient"
+)
+
+// methodNotFound is the response of the test daemon to an unknown method.
+const methodNotFound = `"error":{"code":-32601,"message":"Method not found"}`
+
+// newTestDaemonResponses returns the responses of a healthy Electrum 3.0
+// daemon to the requests of the doctor, keyed by method.  Each response is the
+// result or error member of the JSON-RPC response.
+func newTestDaemonResponses(t *testing.T) map[string]string {
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey := privKey.PubKey().SerializeCompressed()
+	addr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey), chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wif, err := btcutil.NewWIF(privKey, chainParams, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := wire.NewMsgTx(atomicswap.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(int64(doctorPayToAmount), nil))
+	var buf bytes.Buffer
+	err = tx.Serialize(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]string{
+		"version": `"result":"3.0.6"`,
+		"getinfo": `"result":{"server":"electrum.example.com","blockchain_height":100,` +
+			`"server_height":101,"connected":true}`,
+		"getunusedaddress": `"result":"` + encodeAddress(addr) + `"`,
+		"getprivatekeys":   `"result":"` + wif.String() + `"`,
+		"getbalance":       `"result":{"confirmed":"0.5","unconfirmed":"0"}`,
+		"getfeerate":       `"result":10000`,
+		"listunspent":      `"result":[]`,
+		"payto":            `"result":{"complete":false,"final":true,"hex":"` + hex.EncodeToString(buf.Bytes()) + `"}`,
+		"broadcast":        `"result":[false,"TX decode failed"]`,
+		"paytomany":        `"error":{"code":-32602,"message":"missing parameter outputs"}`,
+		"freeze_utxo":      `"error":{"code":-32602,"message":"missing parameter coin"}`,
+	}
+}
+
+// newTestElectrumWallet returns a wallet using a daemon that answers each
+// method with its response in responses, or a method not found error.
+func newTestElectrumWallet(t *testing.T, responses map[string]string) *electrumWallet {
+	return newTestElectrumWalletFunc(t, func(method string, params json.RawMessage) string {
+		response, ok := responses[method]
+		if !ok {
+			return methodNotFound
+		}
+		return response
+	})
+}
+
+// newTestElectrumWalletFunc returns a wallet using a daemon that answers each
+// request with the response returned by respond for its method and
+// parameters.
+func newTestElectrumWalletFunc(t *testing.T, respond func(method string, params json.RawMessage) string) *electrumWallet {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		response := respond(req.Method, req.Params)
+		w.Write([]byte(`{"id":` + string(req.ID) + `,` + response + `}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := rpc.New(&rpc.ConnConfig{
+		Host:          strings.TrimPrefix(srv.URL, "http://"),
+		DisableTLS:    true,
+		HTTPPostMode:  true,
+		DecodeAddress: decodeAddress,
+		EncodeAddress: encodeAddress,
+		DecodeWIF:     decodeWIF,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Shutdown)
+	return &electrumWallet{c}
+}
+
+func TestDoctor(t *testing.T) {
+	hash := bytes.Repeat([]byte{0x01}, 20)
+	p2wpkh, err := btcutil.NewAddressWitnessPubKeyHash(hash, chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2sh, err := btcutil.NewAddressScriptHashFromHash(hash, chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		responses map[string]string
+		err       string
+	}{
+		{
+			name: "healthy daemon",
+		},
+		{
+			name:      "unreachable daemon",
+			responses: map[string]string{"version": `"error":{"code":-32000,"message":"daemon not running"}`},
+			err:       "unable to reach",
+		},
+		{
+			name:      "unsupported version",
+			responses: map[string]string{"version": `"result":"2.9.3"`},
+			err:       "1 checks failed",
+		},
+		{
+			name: "disconnected daemon",
+			responses: map[string]string{"getinfo": `"result":{"blockchain_height":100,` +
+				`"server_height":0,"connected":false}`},
+			err: "1 checks failed",
+		},
+		{
+			name: "daemon not synchronised",
+			responses: map[string]string{"getinfo": `"result":{"server":"electrum.example.com",` +
+				`"blockchain_height":100,"server_height":103,"connected":true}`},
+			err: "1 checks failed",
+		},
+		{
+			name:      "locked wallet",
+			responses: map[string]string{"getprivatekeys": `"error":{"code":-32000,"message":"Password required"}`},
+			err:       "1 checks failed",
+		},
+		{
+			name:      "no wallet loaded",
+			responses: map[string]string{"getunusedaddress": `"error":{"code":-32000,"message":"Wallet not loaded"}`},
+			err:       "1 checks failed",
+		},
+		{
+			name: "wallet of another network",
+			responses: map[string]string{
+				"getunusedaddress": `"result":"mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"`},
+			err: "1 checks failed",
+		},
+		{
+			name: "segwit wallet",
+			responses: map[string]string{
+				"getunusedaddress": `"result":"` + p2wpkh.EncodeAddress() + `"`},
+		},
+		{
+			name: "wallet of script addresses",
+			responses: map[string]string{
+				"getunusedaddress": `"result":"` + p2sh.EncodeAddress() + `"`},
+			err: "1 checks failed",
+		},
+		{
+			// paytomany is optional, so its absence is only a warning.
+			name:      "no paytomany",
+			responses: map[string]string{"paytomany": methodNotFound},
+		},
+		{
+			name:      "no freeze_utxo",
+			responses: map[string]string{"freeze_utxo": methodNotFound},
+			err:       "1 checks failed",
+		},
+		{
+			name: "failing wallet",
+			responses: map[string]string{
+				"getbalance":  methodNotFound,
+				"getfeerate":  methodNotFound,
+				"listunspent": methodNotFound,
+				"payto":       methodNotFound,
+				"broadcast":   methodNotFound,
+			},
+			err: "5 checks failed",
+		},
+		{
+			// A payto failure other than an unknown method is only a
+			// warning, the daemon knows the method.
+			name:      "payto rejected",
+			responses: map[string]string{"payto": `"error":{"code":-32000,"message":"Insufficient funds"}`},
+		},
+	}
+	for _, test := range tests {
+		responses := newTestDaemonResponses(t)
+		for method, response := range test.responses {
+			if response == methodNotFound {
+				delete(responses, method)
+				continue
+			}
+			responses[method] = response
+		}
+		w := newTestElectrumWallet(t, responses)
+
+		err := (&doctorCmd{}).runCommand(w)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: error is %v, want %s", test.name, err, test.err)
+		}
+	}
+}
+
+func TestDoctorWallet(t *testing.T) {
+	old := *walletFlag
+	*walletFlag = "/wallets/default_wallet"
+	defer func() { *walletFlag = old }()
+
+	tests := []struct {
+		name    string
+		wallets string
+		err     string
+	}{
+		{
+			name:    "wallet loaded",
+			wallets: `"result":[{"path":"/wallets/default_wallet","synchronized":true}]`,
+		},
+		{
+			name:    "other wallet loaded",
+			wallets: `"result":[{"path":"/wallets/other_wallet","synchronized":true}]`,
+			err:     "1 checks failed",
+		},
+		{
+			name:    "no list_wallets",
+			wallets: methodNotFound,
+			err:     "1 checks failed",
+		},
+	}
+	for _, test := range tests {
+		responses := newTestDaemonResponses(t)
+		responses["list_wallets"] = test.wallets
+		w := newTestElectrumWallet(t, responses)
+
+		err := (&doctorCmd{}).runCommand(w)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: error is %v, want %s", test.name, err, test.err)
+		}
+	}
+}
+
+func TestDoctorRequiresElectrum(t *testing.T) {
+	err := (&doctorCmd{}).runCommand(newMemWallet(testFeePerKb))
+	if err == nil {
+		t.Fatal("doctor ran without the Electrum daemon")
+	}
+}
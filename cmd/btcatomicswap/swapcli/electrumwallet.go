@@ -0,0 +1,189 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	rpc "github.com/robvanmieghem/electrumatomicswap/cmd/btcatomicswap/rpcclient"
+	"github.com/robvanmieghem/electrumatomicswap/feepolicy"
+)
+
+// electrumWallet is the Wallet of an Electrum daemon, used through its
+// JSON-RPC interface.
+type electrumWallet struct {
+	c *rpc.Client
+}
+
+// electrumClient returns the RPC client of the Electrum daemon for commands
+// that only work with the Electrum wallet.
+func electrumClient(w Wallet) (*rpc.Client, error) {
+	ew, ok := w.(*electrumWallet)
+	if !ok {
+		return nil, errors.New("command requires the Electrum daemon")
+	}
+	return ew.c, nil
+}
+
+// NewAddress uses the getunusedaddress JSON-RPC method.
+func (w *electrumWallet) NewAddress() (btcutil.Address, error) {
+	return w.c.GetUnusedAddress()
+}
+
+// PayTo calls the payto JSON-RPC method, or paytomany when paying several
+// amounts.  It creates a funded, signed transaction.
+func (w *electrumWallet) PayTo(amounts map[btcutil.Address]btcutil.Amount, feePerKb btcutil.Amount) (fundedTx *wire.MsgTx, fee btcutil.Amount, err error) {
+	method := "paytomany"
+	var complete bool
+	if len(amounts) == 1 {
+		method = "payto"
+		for destination, amount := range amounts {
+			fundedTx, complete, err = w.c.PayTo(destination, amount, feePerKb, false)
+		}
+	} else {
+		fundedTx, complete, err = w.c.PayToMany(amounts, feePerKb, false)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if !complete {
+		return nil, 0, fmt.Errorf("%s:Created transaction is not complete", method)
+	}
+	fee, err = w.txFee(fundedTx)
+	return fundedTx, fee, err
+}
+
+// txFee calculates the fee of a transaction funded by the wallet by looking
+// up the values of the spent wallet outputs.
+func (w *electrumWallet) txFee(fundedTx *wire.MsgTx) (fee btcutil.Amount, err error) {
+	//Fetch all unspent outputs from the wallet in order to calculate the fee
+	utxos, err := w.c.ListUnspent()
+	if err != nil {
+		return
+	}
+	findUtxofunc := func(outPoint wire.OutPoint) (*rpc.UnspentOutput, error) {
+		for _, utxo := range utxos {
+			if outPoint.Hash.IsEqual(&utxo.OutPoint.Hash) && outPoint.Index == utxo.OutPoint.Index {
+				return utxo, nil
+			}
+		}
+		return nil, fmt.Errorf("no utxo found for used input %s", outPoint)
+	}
+	var rawfee int64
+	for _, txin := range fundedTx.TxIn {
+		utxo, err := findUtxofunc(txin.PreviousOutPoint)
+		if err != nil {
+			return 0, err
+		}
+		rawfee += int64(utxo.Value)
+	}
+	for _, txout := range fundedTx.TxOut {
+		rawfee -= txout.Value
+	}
+	fee = btcutil.Amount(rawfee)
+	return
+}
+
+// CreateSig signs with a private key dumped from the wallet.  Due to
+// limitations of the Electrum RPC API, this requires dumping a private key and
+// signing in the client, rather than letting the wallet sign.
+func (w *electrumWallet) CreateSig(tx *wire.MsgTx, idx int, pkScript []byte, addr btcutil.Address) (sig, pubkey []byte, err error) {
+	privKey, err := w.privKey(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signWithKey(tx, idx, pkScript, privKey)
+}
+
+func (w *electrumWallet) CreateWitnessSig(tx *wire.MsgTx, idx int, script []byte, amount int64,
+	addr btcutil.Address) (sig, pubkey []byte, err error) {
+
+	privKey, err := w.privKey(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signWitnessWithKey(tx, idx, script, amount, privKey)
+}
+
+func (w *electrumWallet) CreateForkIDSig(tx *wire.MsgTx, idx int, pkScript []byte, amount int64,
+	addr btcutil.Address) (sig, pubkey []byte, err error) {
+
+	privKey, err := w.privKey(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signForkIDWithKey(tx, idx, pkScript, amount, privKey)
+}
+
+// privKey dumps the private key of addr from the wallet.  The contracts
+// identify keys by their hash, so a P2PKH address unknown to a wallet handing
+// out P2WPKH addresses is looked up as the P2WPKH address of the same hash.
+func (w *electrumWallet) privKey(addr btcutil.Address) (*btcec.PrivateKey, error) {
+	wif, err := w.c.DumpPrivKey(addr)
+	if err == nil {
+		return wif.PrivKey, nil
+	}
+	pkh, ok := addr.(*btcutil.AddressPubKeyHash)
+	if !ok || chainParams.Bech32HRPSegwit == "" {
+		return nil, err
+	}
+	witnessAddr, witnessErr := btcutil.NewAddressWitnessPubKeyHash(pkh.Hash160()[:], chainParams)
+	if witnessErr != nil {
+		return nil, err
+	}
+	wif, witnessErr = w.c.DumpPrivKey(witnessAddr)
+	if witnessErr != nil {
+		return nil, err
+	}
+	return wif.PrivKey, nil
+}
+
+// FeeRate uses Electrum's eta fee estimation for the confirmation target.
+func (w *electrumWallet) FeeRate(confTarget int) (btcutil.Amount, error) {
+	return w.c.EstimateFeeRate("eta", feepolicy.ElectrumFeeLevel(confTarget))
+}
+
+// Broadcast uses the broadcast JSON-RPC method.
+func (w *electrumWallet) Broadcast(tx *wire.MsgTx) (*chainhash.Hash, error) {
+	return w.c.SendRawTransaction(tx, false)
+}
+
+// Transaction uses the gettransaction JSON-RPC method.
+func (w *electrumWallet) Transaction(txHash *chainhash.Hash) (*wire.MsgTx, error) {
+	return w.c.GetTransaction(txHash)
+}
+
+// ListUnspent uses the listunspent JSON-RPC method.
+func (w *electrumWallet) ListUnspent() ([]*unspentOutput, error) {
+	utxos, err := w.c.ListUnspent()
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]*unspentOutput, 0, len(utxos))
+	for _, utxo := range utxos {
+		outputs = append(outputs, &unspentOutput{
+			OutPoint: *utxo.OutPoint,
+			Value:    utxo.Value,
+		})
+	}
+	return outputs, nil
+}
+
+// LockOutPoint uses the freeze_utxo JSON-RPC method.
+func (w *electrumWallet) LockOutPoint(outPoint *wire.OutPoint) error {
+	_, err := w.c.FreezeUTXO(outPoint)
+	return err
+}
+
+// UnlockOutPoint uses the unfreeze_utxo JSON-RPC method.
+func (w *electrumWallet) UnlockOutPoint(outPoint *wire.OutPoint) error {
+	_, err := w.c.UnfreezeUTXO(outPoint)
+	return err
+}
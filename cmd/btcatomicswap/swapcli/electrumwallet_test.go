@@ -0,0 +1,67 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcutil"
+)
+
+func TestElectrumWitnessKey(t *testing.T) {
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := btcutil.Hash160(privKey.PubKey().SerializeCompressed())
+	p2pkh, err := btcutil.NewAddressPubKeyHash(hash, chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2wpkh, err := btcutil.NewAddressWitnessPubKeyHash(hash, chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wif, err := btcutil.NewWIF(privKey, chainParams, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The wallet only knows the P2WPKH address of the key, and prefixes
+	// the key with its script type like Electrum does.
+	w := newTestElectrumWalletFunc(t, func(method string, params json.RawMessage) string {
+		switch {
+		case method == "version":
+			return `"result":"3.0.6"`
+		case method != "getprivatekeys":
+			return methodNotFound
+		case strings.Contains(string(params), p2wpkh.EncodeAddress()):
+			return `"result":"p2wpkh:` + wif.String() + `"`
+		}
+		return `"error":{"code":-32000,"message":"Address not in wallet."}`
+	})
+
+	key, err := w.privKey(p2pkh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.PubKey().IsEqual(privKey.PubKey()) {
+		t.Error("wrong key for the P2PKH address of the witness key")
+	}
+
+	// Keys the wallet does not have are reported with the error of the
+	// P2PKH lookup.
+	other, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.privKey(other)
+	if err == nil || !strings.Contains(err.Error(), "not in wallet") {
+		t.Errorf("error is %v, want address not in wallet", err)
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/robvanmieghem/electrumatomicswap/electrumx"
+	"github.com/robvanmieghem/electrumatomicswap/hdwallet"
+)
+
+// hdWallet is the Wallet of the built-in HD wallet, used instead of the
+// Electrum daemon when -hdwallet is set.
+type hdWallet struct {
+	w *hdwallet.Wallet
+}
+
+type createHDWalletCmd struct{}
+
+type restoreHDWalletCmd struct{}
+
+// hdWalletPass returns the password of the built-in HD wallet, which is read
+// from the same sources as the Electrum wallet password but is mandatory.
+func hdWalletPass() ([]byte, error) {
+	pass, err := readWalletPass()
+	if err != nil {
+		return nil, err
+	}
+	if pass == "" {
+		return nil, errors.New("the HD wallet requires a password, pass it with " +
+			"-walletpassfile, -walletpassprompt or $" + walletPassEnv)
+	}
+	return []byte(pass), nil
+}
+
+func (cmd *createHDWalletCmd) runCommand(w Wallet) error {
+	return cmd.runOfflineCommand()
+}
+
+func (cmd *createHDWalletCmd) runOfflineCommand() error {
+	if *hdWalletFlag == "" {
+		return errors.New("createhdwallet: the wallet file must be set with -hdwallet")
+	}
+	pass, err := hdWalletPass()
+	if err != nil {
+		return err
+	}
+	mnemonic, err := hdwallet.NewMnemonic()
+	if err != nil {
+		return err
+	}
+	err = hdwallet.Create(*hdWalletFlag, mnemonic, pass, chainParams)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created HD wallet %s\n\n", *hdWalletFlag)
+	fmt.Printf("Mnemonic (write it down, it is the only backup of the wallet):\n")
+	fmt.Printf("%s\n", mnemonic)
+	return nil
+}
+
+func (cmd *restoreHDWalletCmd) runCommand(w Wallet) error {
+	return cmd.runOfflineCommand()
+}
+
+func (cmd *restoreHDWalletCmd) runOfflineCommand() error {
+	if *hdWalletFlag == "" {
+		return errors.New("restorehdwallet: the wallet file must be set with -hdwallet")
+	}
+	pass, err := hdWalletPass()
+	if err != nil {
+		return err
+	}
+
+	// The mnemonic is read from standard input rather than the command line
+	// so it does not end up in the shell history.
+	fmt.Print("Mnemonic: ")
+	mnemonic, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	err = hdwallet.Create(*hdWalletFlag, mnemonic, pass, chainParams)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored HD wallet %s\n", *hdWalletFlag)
+	fmt.Println("Used addresses are discovered when the wallet is first used")
+	return nil
+}
+
+// electrumServerPort returns the default port of Electrum servers for the
+// network, with or without TLS.
+func electrumServerPort(params *chaincfg.Params, useTLS bool) string {
+	switch {
+	case params == &chaincfg.MainNetParams && useTLS:
+		return "50002"
+	case params == &chaincfg.MainNetParams:
+		return "50001"
+	case params == &chaincfg.TestNet3Params && useTLS:
+		return "60002"
+	case params == &chaincfg.TestNet3Params:
+		return "60001"
+	case coinNetwork != nil && useTLS:
+		return coinNetwork.ElectrumTLSPort
+	case coinNetwork != nil:
+		return coinNetwork.ElectrumPort
+	default:
+		return ""
+	}
+}
+
+// hdContext returns the context of a request of the built-in HD wallet to
+// its Electrum server, limited by -rpctimeout.
+func hdContext() (context.Context, context.CancelFunc) {
+	if *rpcTimeoutFlag <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), *rpcTimeoutFlag)
+}
+
+// runHDWalletCommand runs cmd with the built-in HD wallet, connected to the
+// Electrum server set with -electrumserver.
+func runHDWalletCommand(cmd command) error {
+	if *electrumServerFlag == "" {
+		return errors.New("-hdwallet requires an Electrum server set with -electrumserver")
+	}
+	// The wallet funds transactions with legacy signatures only.
+	if forkIDSigs {
+		return errors.New("-hdwallet does not support coins with forkid signatures")
+	}
+	if txHashes != nil || groestlChecksums {
+		return errors.New("-hdwallet does not support coins hashing like Groestlcoin")
+	}
+	server, err := normalizeAddress(*electrumServerFlag,
+		electrumServerPort(chainParams, *electrumServerTLSFlag))
+	if err != nil {
+		return fmt.Errorf("electrum server address: %v", err)
+	}
+	pass, err := hdWalletPass()
+	if err != nil {
+		return err
+	}
+
+	var tlsConfig *tls.Config
+	if *electrumServerTLSFlag {
+		host, _, _ := net.SplitHostPort(server)
+		tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+	ctx, cancel := hdContext()
+	defer cancel()
+	client, err := electrumx.Dial(ctx, server, tlsConfig)
+	if err != nil {
+		return fmt.Errorf("electrum server: %v", err)
+	}
+	defer client.Close()
+	_, err = client.Handshake(ctx, "btcatomicswap")
+	if err != nil {
+		return fmt.Errorf("electrum server: %v", err)
+	}
+
+	w, err := hdwallet.Open(*hdWalletFlag, pass, chainParams, client)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	return cmd.runCommand(&hdWallet{w})
+}
+
+func (w *hdWallet) NewAddress() (btcutil.Address, error) {
+	return w.w.NewAddress()
+}
+
+func (w *hdWallet) PayTo(amounts map[btcutil.Address]btcutil.Amount, feePerKb btcutil.Amount) (*wire.MsgTx, btcutil.Amount, error) {
+	outputs, err := sortedOutputs(amounts)
+	if err != nil {
+		return nil, 0, err
+	}
+	ctx, cancel := hdContext()
+	defer cancel()
+	return w.w.Fund(ctx, outputs, feePerKb)
+}
+
+func (w *hdWallet) CreateSig(tx *wire.MsgTx, idx int, pkScript []byte, addr btcutil.Address) (sig, pubkey []byte, err error) {
+	privKey, err := w.privKey(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signWithKey(tx, idx, pkScript, privKey)
+}
+
+func (w *hdWallet) CreateWitnessSig(tx *wire.MsgTx, idx int, script []byte, amount int64,
+	addr btcutil.Address) (sig, pubkey []byte, err error) {
+
+	privKey, err := w.privKey(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signWitnessWithKey(tx, idx, script, amount, privKey)
+}
+
+func (w *hdWallet) CreateForkIDSig(tx *wire.MsgTx, idx int, pkScript []byte, amount int64,
+	addr btcutil.Address) (sig, pubkey []byte, err error) {
+
+	privKey, err := w.privKey(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signForkIDWithKey(tx, idx, pkScript, amount, privKey)
+}
+
+func (w *hdWallet) privKey(addr btcutil.Address) (*btcec.PrivateKey, error) {
+	return w.w.PrivKey(addr)
+}
+
+// FeeRate uses the fee estimate of the Electrum server.
+func (w *hdWallet) FeeRate(confTarget int) (btcutil.Amount, error) {
+	ctx, cancel := hdContext()
+	defer cancel()
+	return w.w.FeeRate(ctx, confTarget)
+}
+
+func (w *hdWallet) Broadcast(tx *wire.MsgTx) (*chainhash.Hash, error) {
+	ctx, cancel := hdContext()
+	defer cancel()
+	return w.w.Broadcast(ctx, tx)
+}
+
+func (w *hdWallet) Transaction(txHash *chainhash.Hash) (*wire.MsgTx, error) {
+	ctx, cancel := hdContext()
+	defer cancel()
+	return w.w.GetTransaction(ctx, txHash)
+}
+
+// ListUnspent syncs the wallet with the Electrum server first.
+func (w *hdWallet) ListUnspent() ([]*unspentOutput, error) {
+	ctx, cancel := hdContext()
+	defer cancel()
+	err := w.w.Sync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var utxos []*unspentOutput
+	for _, u := range w.w.ListUnspent() {
+		utxos = append(utxos, &unspentOutput{OutPoint: u.OutPoint, Value: u.Value})
+	}
+	return utxos, nil
+}
+
+func (w *hdWallet) LockOutPoint(outPoint *wire.OutPoint) error {
+	return w.w.LockOutPoint(outPoint)
+}
+
+func (w *hdWallet) UnlockOutPoint(outPoint *wire.OutPoint) error {
+	return w.w.UnlockOutPoint(outPoint)
+}
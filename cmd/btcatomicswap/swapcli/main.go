@@ -0,0 +1,935 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package swapcli implements btcatomicswap, the command performing atomic
+// swaps of Bitcoin and of the coins derived from it.  The commands of coins
+// shipping their own binary run it with the definition of their coin.
+package swapcli
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+	rpc "github.com/robvanmieghem/electrumatomicswap/cmd/btcatomicswap/rpcclient"
+	"github.com/robvanmieghem/electrumatomicswap/feepolicy"
+)
+
+// Wallet RPC servers selectable with -backend.
+const (
+	backendElectrum = "electrum"
+	backendCore     = "core"
+)
+
+var (
+	chainParams = &chaincfg.MainNetParams
+
+	// commandName is the name of the command in the usage message.
+	commandName = "btcatomicswap"
+
+	// defaultCoin is the definition of the coin swapped unless -coin is
+	// set, nil for Bitcoin.
+	defaultCoin []byte
+)
+
+var (
+	flagset     = flag.NewFlagSet("", flag.ExitOnError)
+	connectFlag = flagset.String("s", "localhost", "host[:port] of the wallet RPC server")
+	rpcuserFlag = flagset.String("rpcuser", "", "username for wallet RPC authentication")
+	rpcpassFlag = flagset.String("rpcpass", "", "password for wallet RPC authentication")
+	testnetFlag = flagset.Bool("testnet", false, "use testnet network")
+	coinFlag    = flagset.String("coin", "", "file of the definition of a coin derived from Bitcoin to use instead of Bitcoin")
+	tlsFlag     = flagset.Bool("tls", false, "connect to the wallet RPC server using TLS")
+
+	rpcTimeoutFlag = flagset.Duration("rpctimeout", 2*time.Minute, "maximum duration of a single wallet RPC request (0 for no limit)")
+	rpcRetriesFlag = flagset.Int("rpcretries", 3, "number of times a failed wallet RPC read is retried")
+	rpcWorkersFlag = flagset.Int("rpcworkers", 4, "maximum number of wallet RPC requests in flight at the same time")
+
+	rpcCertFlag            = flagset.String("rpccert", "", "file containing the CA certificate(s) used to verify the RPC server (implies -tls)")
+	rpcClientCertFlag      = flagset.String("rpcclientcert", "", "file containing the client certificate presented to the RPC server (implies -tls)")
+	rpcClientKeyFlag       = flagset.String("rpcclientkey", "", "file containing the private key of the client certificate")
+	rpcCertFingerprintFlag = flagset.String("rpccertfingerprint", "", "hex SHA-256 fingerprint the RPC server certificate is pinned to (implies -tls)")
+
+	proxyFlag        = flagset.String("proxy", "", "connect to the RPC server through the SOCKS5 proxy at host:port")
+	proxyUserFlag    = flagset.String("proxyuser", "", "username for SOCKS5 proxy authentication")
+	proxyPassFlag    = flagset.String("proxypass", "", "password for SOCKS5 proxy authentication")
+	torIsolationFlag = flagset.Bool("torisolation", false, "derive proxy credentials from the swap so each swap uses its own Tor circuit")
+
+	backendFlag = flagset.String("backend", backendElectrum, "wallet RPC server: "+backendElectrum+" (Electrum daemon) or "+backendCore+" (Bitcoin Core)")
+	walletFlag  = flagset.String("wallet", "", "path (Electrum) or name (Bitcoin Core) of the wallet to use when several wallets are loaded")
+
+	walletPassFlag       = flagset.String("walletpass", "", "password of an encrypted wallet (prefer -walletpassfile, $"+walletPassEnv+" or -walletpassprompt)")
+	walletPassFileFlag   = flagset.String("walletpassfile", "", "file containing the password of an encrypted wallet")
+	walletPassPromptFlag = flagset.Bool("walletpassprompt", false, "prompt for the password of an encrypted wallet")
+
+	reservationsFlag = flagset.String("reservations", "", "file recording wallet outputs reserved by unpublished contracts (default: in the application data directory)")
+
+	hdWalletFlag          = flagset.String("hdwallet", "", "file of the built-in HD wallet to use instead of the Electrum daemon")
+	electrumServerFlag    = flagset.String("electrumserver", "", "host[:port] of the Electrum server used by the built-in HD wallet")
+	electrumServerTLSFlag = flagset.Bool("electrumservertls", false, "connect to the Electrum server of the built-in HD wallet using TLS")
+
+	feePolicy feepolicy.Policy
+)
+
+// There are two directions that the atomic swap can be performed, as the
+// initiator can be on either chain.  This tool only deals with creating the
+// Bitcoin transactions for these swaps.  A second tool should be used for the
+// transaction on the other chain.  Any chain can be used so long as it supports
+// OP_SHA256 and OP_CHECKLOCKTIMEVERIFY.
+//
+// Example scenerios using bitcoin as the second chain:
+//
+// Scenerio 1:
+//   cp1 initiates (dcr)
+//   cp2 participates with cp1 H(S) (btc)
+//   cp1 redeems btc revealing S
+//     - must verify H(S) in contract is hash of known secret
+//   cp2 redeems dcr with S
+//
+// Scenerio 2:
+//   cp1 initiates (btc)
+//   cp2 participates with cp1 H(S) (dcr)
+//   cp1 redeems dcr revealing S
+//     - must verify H(S) in contract is hash of known secret
+//   cp2 redeems btc with S
+
+func init() {
+	feePolicy.RegisterFlags(flagset)
+
+	flagset.Usage = func() {
+		fmt.Printf("Usage: %s [flags] cmd [cmd args]\n", commandName)
+		fmt.Println()
+		fmt.Println("Commands:")
+		fmt.Println("  initiate <participant address> <amount>")
+		fmt.Println("  participate <initiator address> <amount> <secret hash>")
+		fmt.Println("  redeem <contract> <contract transaction> <secret>")
+		fmt.Println("  refund <contract> <contract transaction>")
+		fmt.Println("  extractsecret <redemption transaction> <secret hash>")
+		fmt.Println("  auditcontract <contract> <contract transaction>")
+		fmt.Println("  release <contract transaction>")
+		fmt.Println("  batchinitiate <address>,<amount>[,<secret hash>] ...")
+		fmt.Println("  batchredeem <contract>,<contract transaction>,<secret> ...")
+		fmt.Println("  batchrefund <contract>,<contract transaction> ...")
+		fmt.Println("  quote <amount>")
+		fmt.Println("  doctor")
+		fmt.Println("  listwallets")
+		fmt.Println("  loadwallet <wallet path>")
+		fmt.Println("  createhdwallet")
+		fmt.Println("  restorehdwallet")
+		fmt.Println()
+		fmt.Println("Flags:")
+		flagset.PrintDefaults()
+	}
+}
+
+type command interface {
+	runCommand(Wallet) error
+}
+
+// offline commands don't require wallet RPC.
+type offlineCommand interface {
+	command
+	runOfflineCommand() error
+}
+
+type initiateCmd struct {
+	cp2Addr *btcutil.AddressPubKeyHash
+	amount  btcutil.Amount
+}
+
+type participateCmd struct {
+	cp1Addr    *btcutil.AddressPubKeyHash
+	amount     btcutil.Amount
+	secretHash []byte
+}
+
+type redeemCmd struct {
+	contract   []byte
+	contractTx *wire.MsgTx
+	secret     []byte
+}
+
+type refundCmd struct {
+	contract   []byte
+	contractTx *wire.MsgTx
+}
+
+type extractSecretCmd struct {
+	redemptionTx *wire.MsgTx
+	secretHash   []byte
+}
+
+type auditContractCmd struct {
+	contract   []byte
+	contractTx *wire.MsgTx
+}
+
+type releaseCmd struct {
+	contractTx *wire.MsgTx
+}
+
+type listWalletsCmd struct{}
+
+type loadWalletCmd struct {
+	walletPath string
+}
+
+// Main runs the command named name with the arguments of the process,
+// swapping the coin of the definition coin, or Bitcoin if coin is nil.  It
+// exits the process with a non-zero status when the command fails.
+func Main(name string, coin []byte) {
+	commandName, defaultCoin = name, coin
+	showUsage, err := run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if showUsage {
+		flagset.Usage()
+	}
+	if err != nil || showUsage {
+		os.Exit(1)
+	}
+}
+
+func checkCmdArgLength(args []string, required int) (nArgs int) {
+	if len(args) < required {
+		return 0
+	}
+	for i, arg := range args[:required] {
+		if len(arg) != 1 && strings.HasPrefix(arg, "-") {
+			return i
+		}
+	}
+	return required
+}
+
+func run() (showUsage bool, err error) {
+	flagset.Parse(os.Args[1:])
+	args := flagset.Args()
+	if len(args) == 0 {
+		return true, nil
+	}
+	cmdArgs := 0
+	variadic := false
+	switch args[0] {
+	case "initiate":
+		cmdArgs = 2
+	case "participate":
+		cmdArgs = 3
+	case "redeem":
+		cmdArgs = 3
+	case "refund":
+		cmdArgs = 2
+	case "extractsecret":
+		cmdArgs = 2
+	case "auditcontract":
+		cmdArgs = 2
+	case "release":
+		cmdArgs = 1
+	case "quote":
+		cmdArgs = 1
+	case "doctor":
+		cmdArgs = 0
+	case "listwallets":
+		cmdArgs = 0
+	case "loadwallet":
+		cmdArgs = 1
+	case "createhdwallet", "restorehdwallet":
+		cmdArgs = 0
+	case "batchinitiate", "batchredeem", "batchrefund":
+		cmdArgs = 1
+		variadic = true
+	default:
+		return true, fmt.Errorf("unknown command %v", args[0])
+	}
+	nArgs := checkCmdArgLength(args[1:], cmdArgs)
+	if variadic && nArgs == cmdArgs {
+		nArgs = checkCmdArgLength(args[1:], len(args)-1)
+	}
+	flagset.Parse(args[1+nArgs:])
+	if nArgs < cmdArgs {
+		return true, fmt.Errorf("%s: too few arguments", args[0])
+	}
+	if flagset.NArg() != 0 {
+		return true, fmt.Errorf("unexpected argument: %s", flagset.Arg(0))
+	}
+
+	if *testnetFlag {
+		chainParams = &chaincfg.TestNet3Params
+	}
+	if *coinFlag != "" || defaultCoin != nil {
+		err := loadCoin()
+		if err != nil {
+			return false, fmt.Errorf("coin definition: %v", err)
+		}
+	}
+
+	var cmd command
+	switch args[0] {
+	case "initiate":
+		cp2Addr, err := decodeAddress(args[1])
+		if err != nil {
+			return true, fmt.Errorf("failed to decode participant address: %v", err)
+		}
+		if !cp2Addr.IsForNet(chainParams) {
+			return true, fmt.Errorf("participant address is not "+
+				"intended for use on %v", chainParams.Name)
+		}
+		cp2AddrP2PKH, err := atomicswap.KeyHashAddress(cp2Addr, chainParams)
+		if err != nil {
+			return true, fmt.Errorf("participant address: %v", err)
+		}
+
+		amountF64, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return true, fmt.Errorf("failed to decode amount: %v", err)
+		}
+		amount, err := btcutil.NewAmount(amountF64)
+		if err != nil {
+			return true, err
+		}
+
+		cmd = &initiateCmd{cp2Addr: cp2AddrP2PKH, amount: amount}
+
+	case "participate":
+		cp1Addr, err := decodeAddress(args[1])
+		if err != nil {
+			return true, fmt.Errorf("failed to decode initiator address: %v", err)
+		}
+		if !cp1Addr.IsForNet(chainParams) {
+			return true, fmt.Errorf("initiator address is not "+
+				"intended for use on %v", chainParams.Name)
+		}
+		cp1AddrP2PKH, err := atomicswap.KeyHashAddress(cp1Addr, chainParams)
+		if err != nil {
+			return true, fmt.Errorf("initiator address: %v", err)
+		}
+
+		amountF64, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return true, fmt.Errorf("failed to decode amount: %v", err)
+		}
+		amount, err := btcutil.NewAmount(amountF64)
+		if err != nil {
+			return true, err
+		}
+
+		secretHash, err := hex.DecodeString(args[3])
+		if err != nil {
+			return true, errors.New("secret hash must be hex encoded")
+		}
+		if len(secretHash) != sha256.Size {
+			return true, errors.New("secret hash has wrong size")
+		}
+
+		cmd = &participateCmd{cp1Addr: cp1AddrP2PKH, amount: amount, secretHash: secretHash}
+
+	case "redeem":
+		contract, err := hex.DecodeString(args[1])
+		if err != nil {
+			return true, fmt.Errorf("failed to decode contract: %v", err)
+		}
+
+		contractTxBytes, err := hex.DecodeString(args[2])
+		if err != nil {
+			return true, fmt.Errorf("failed to decode contract transaction: %v", err)
+		}
+		var contractTx wire.MsgTx
+		err = contractTx.Deserialize(bytes.NewReader(contractTxBytes))
+		if err != nil {
+			return true, fmt.Errorf("failed to decode contract transaction: %v", err)
+		}
+
+		secret, err := hex.DecodeString(args[3])
+		if err != nil {
+			return true, fmt.Errorf("failed to decode secret: %v", err)
+		}
+
+		cmd = &redeemCmd{contract: contract, contractTx: &contractTx, secret: secret}
+
+	case "refund":
+		contract, err := hex.DecodeString(args[1])
+		if err != nil {
+			return true, fmt.Errorf("failed to decode contract: %v", err)
+		}
+
+		contractTxBytes, err := hex.DecodeString(args[2])
+		if err != nil {
+			return true, fmt.Errorf("failed to decode contract transaction: %v", err)
+		}
+		var contractTx wire.MsgTx
+		err = contractTx.Deserialize(bytes.NewReader(contractTxBytes))
+		if err != nil {
+			return true, fmt.Errorf("failed to decode contract transaction: %v", err)
+		}
+
+		cmd = &refundCmd{contract: contract, contractTx: &contractTx}
+
+	case "extractsecret":
+		redemptionTxBytes, err := hex.DecodeString(args[1])
+		if err != nil {
+			return true, fmt.Errorf("failed to decode redemption transaction: %v", err)
+		}
+		var redemptionTx wire.MsgTx
+		err = redemptionTx.Deserialize(bytes.NewReader(redemptionTxBytes))
+		if err != nil {
+			return true, fmt.Errorf("failed to decode redemption transaction: %v", err)
+		}
+
+		secretHash, err := hex.DecodeString(args[2])
+		if err != nil {
+			return true, errors.New("secret hash must be hex encoded")
+		}
+		if len(secretHash) != sha256.Size {
+			return true, errors.New("secret hash has wrong size")
+		}
+
+		cmd = &extractSecretCmd{redemptionTx: &redemptionTx, secretHash: secretHash}
+
+	case "auditcontract":
+		contract, err := hex.DecodeString(args[1])
+		if err != nil {
+			return true, fmt.Errorf("failed to decode contract: %v", err)
+		}
+
+		contractTxBytes, err := hex.DecodeString(args[2])
+		if err != nil {
+			return true, fmt.Errorf("failed to decode contract transaction: %v", err)
+		}
+		var contractTx wire.MsgTx
+		err = contractTx.Deserialize(bytes.NewReader(contractTxBytes))
+		if err != nil {
+			return true, fmt.Errorf("failed to decode contract transaction: %v", err)
+		}
+
+		cmd = &auditContractCmd{contract: contract, contractTx: &contractTx}
+
+	case "release":
+		contractTxBytes, err := hex.DecodeString(args[1])
+		if err != nil {
+			return true, fmt.Errorf("failed to decode contract transaction: %v", err)
+		}
+		var contractTx wire.MsgTx
+		err = contractTx.Deserialize(bytes.NewReader(contractTxBytes))
+		if err != nil {
+			return true, fmt.Errorf("failed to decode contract transaction: %v", err)
+		}
+
+		cmd = &releaseCmd{contractTx: &contractTx}
+
+	case "quote":
+		amountF64, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return true, fmt.Errorf("failed to decode amount: %v", err)
+		}
+		amount, err := btcutil.NewAmount(amountF64)
+		if err != nil {
+			return true, err
+		}
+
+		cmd = &quoteCmd{amount: amount}
+
+	case "doctor":
+		cmd = &doctorCmd{}
+
+	case "listwallets":
+		cmd = &listWalletsCmd{}
+
+	case "loadwallet":
+		cmd = &loadWalletCmd{walletPath: args[1]}
+
+	case "createhdwallet":
+		cmd = &createHDWalletCmd{}
+
+	case "restorehdwallet":
+		cmd = &restoreHDWalletCmd{}
+
+	case "batchinitiate":
+		swaps := make([]*batchSwap, nArgs)
+		for i, arg := range args[1 : 1+nArgs] {
+			swaps[i], err = parseBatchSwap(arg)
+			if err != nil {
+				return true, err
+			}
+		}
+
+		cmd = &batchInitiateCmd{swaps: swaps}
+
+	case "batchredeem", "batchrefund":
+		redeem := args[0] == "batchredeem"
+		spends := make([]*atomicswap.Spend, nArgs)
+		for i, arg := range args[1 : 1+nArgs] {
+			spends[i], err = parseBatchSpend(arg, redeem)
+			if err != nil {
+				return true, fmt.Errorf("contract %d: %v", i+1, err)
+			}
+		}
+
+		if redeem {
+			cmd = &batchRedeemCmd{spends: spends}
+		} else {
+			cmd = &batchRefundCmd{spends: spends}
+		}
+	}
+
+	// Offline commands don't need to talk to the wallet.
+	if cmd, ok := cmd.(offlineCommand); ok {
+		return false, cmd.runOfflineCommand()
+	}
+
+	switch *backendFlag {
+	case backendElectrum, backendCore:
+	default:
+		return true, fmt.Errorf("unknown wallet backend %q", *backendFlag)
+	}
+
+	// The built-in HD wallet replaces the Electrum daemon.
+	if *hdWalletFlag != "" {
+		if *backendFlag != backendElectrum {
+			return true, errors.New("-hdwallet can not be used with -backend=" + *backendFlag)
+		}
+		return false, explainRPCError(runHDWalletCommand(cmd))
+	}
+
+	connect, err := normalizeAddress(*connectFlag, walletPort(chainParams))
+	if err != nil {
+		return true, fmt.Errorf("wallet server address: %v", err)
+	}
+
+	walletPass, err := readWalletPass()
+	if err != nil {
+		return false, fmt.Errorf("wallet password: %v", err)
+	}
+
+	connConfig := &rpc.ConnConfig{
+		Host:            connect,
+		User:            *rpcuserFlag,
+		Pass:            *rpcpassFlag,
+		Proxy:           *proxyFlag,
+		ProxyUser:       *proxyUserFlag,
+		ProxyPass:       *proxyPassFlag,
+		TorIsolation:    *torIsolationFlag,
+		IsolationKey:    isolationKey(cmd),
+		Timeout:         *rpcTimeoutFlag,
+		MaxRetries:      *rpcRetriesFlag,
+		Concurrency:     *rpcWorkersFlag,
+		Wallet:          *walletFlag,
+		WalletPass:      walletPass,
+		ElectrumVersion: electrumVersion,
+		DecodeAddress:   decodeAddress,
+		EncodeAddress:   encodeAddress,
+		DecodeWIF:       decodeWIF,
+		HTTPPostMode:    true,
+	}
+	err = configureTLS(connConfig)
+	if err != nil {
+		return false, err
+	}
+	if *backendFlag == backendCore {
+		return false, explainCoreError(runCoreCommand(cmd, connConfig))
+	}
+	client, err := rpc.New(connConfig)
+	if err != nil {
+		return false, fmt.Errorf("rpc connect: %v", err)
+	}
+	defer func() {
+		client.Shutdown()
+		client.WaitForShutdown()
+	}()
+
+	err = cmd.runCommand(&electrumWallet{client})
+	return false, explainRPCError(err)
+}
+
+// isolationKey returns the key the Tor isolation credentials of cmd are
+// derived from: the secret hash of the swap it works on, so that every run for
+// a swap shares a circuit that is not used for other swaps.  Commands not
+// working on a known swap, such as initiate which has not generated its secret
+// yet, return nil and use random credentials.
+func isolationKey(cmd command) []byte {
+	var contract []byte
+	switch cmd := cmd.(type) {
+	case *participateCmd:
+		return cmd.secretHash
+	case *redeemCmd:
+		contract = cmd.contract
+	case *refundCmd:
+		contract = cmd.contract
+	default:
+		return nil
+	}
+	c, err := atomicswap.ParseContract(contract)
+	if err != nil {
+		return nil
+	}
+	return c.SecretHash[:]
+}
+
+// explainRPCError adds a hint on how to resolve err when it was caused by an
+// Electrum failure the operator can act on.
+func explainRPCError(err error) error {
+	var hint string
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, rpc.ErrMethodNotFound):
+		hint = "the Electrum daemon does not support this command, upgrade Electrum"
+	case errors.Is(err, rpc.ErrWalletNotLoaded):
+		hint = "load the wallet with the loadwallet command"
+	case errors.Is(err, rpc.ErrWalletLocked), errors.Is(err, rpc.ErrWrongPassword):
+		hint = "the wallet is encrypted, pass its password with -walletpassfile, " +
+			"-walletpassprompt or $" + walletPassEnv
+	case errors.Is(err, rpc.ErrInsufficientFunds):
+		hint = "the wallet balance does not cover the amount and fees"
+	case errors.Is(err, rpc.ErrAlreadyInChain), errors.Is(err, rpc.ErrAlreadyInMempool):
+		hint = "the transaction has already been published"
+	case errors.Is(err, rpc.ErrMissingInputs):
+		hint = "the spent outputs are unknown to the server or already spent"
+	case errors.Is(err, rpc.ErrMempoolConflict):
+		hint = "another transaction spending the same outputs is waiting to be mined"
+	case errors.Is(err, rpc.ErrFeeTooLow):
+		hint = "raise the fee rate with -feerate"
+	default:
+		return err
+	}
+	return fmt.Errorf("%w (%s)", err, hint)
+}
+
+// lockTimeString describes a transaction locktime as a time or block height.
+func lockTimeString(lockTime uint32) string {
+	if lockTime < txscript.LockTimeThreshold {
+		return fmt.Sprintf("block %d", lockTime)
+	}
+	return time.Unix(int64(lockTime), 0).String()
+}
+
+func normalizeAddress(addr string, defaultPort string) (hostport string, err error) {
+	host, port, origErr := net.SplitHostPort(addr)
+	if origErr == nil {
+		return net.JoinHostPort(host, port), nil
+	}
+	addr = net.JoinHostPort(addr, defaultPort)
+	_, _, err = net.SplitHostPort(addr)
+	if err != nil {
+		return "", origErr
+	}
+	return addr, nil
+}
+
+func walletPort(params *chaincfg.Params) string {
+	switch params {
+	case &chaincfg.MainNetParams:
+		return "8332"
+	case &chaincfg.TestNet3Params:
+		return "18332"
+	default:
+		if coinNetwork != nil {
+			return coinNetwork.RPCPort
+		}
+		return ""
+	}
+}
+
+// getFeePerKb returns the fee rate per kilobyte selected by the fee policy.
+// Unless an explicit fee rate is configured, the wallet is queried for an
+// estimate for the confirmation target, or the minimum relay fee rate is used
+// for coins whose wallets do not estimate fees.
+func getFeePerKb(w Wallet) (feerate btcutil.Amount, err error) {
+	feePerKb, err := feePolicy.FeePerKb(func(confTarget int) (int64, error) {
+		if noFeeEstimates {
+			return feePolicy.MinFeePerKb(), nil
+		}
+		feerate, err := w.FeeRate(confTarget)
+		return int64(feerate), err
+	})
+	return btcutil.Amount(feePerKb), err
+}
+
+// getUnusedAddress returns a new address of the wallet as the P2PKH address of
+// its key hash, which is what the contracts pay to.  The wallet may hand out
+// P2PKH or P2WPKH addresses.
+func getUnusedAddress(w Wallet) (*btcutil.AddressPubKeyHash, error) {
+	addr, err := w.NewAddress()
+	if err != nil {
+		return nil, err
+	}
+	if !addr.IsForNet(chainParams) {
+		return nil, fmt.Errorf("address %v is not intended for use on %v",
+			addr, chainParams.Name)
+	}
+	return atomicswap.KeyHashAddress(addr, chainParams)
+}
+
+func promptPublishTx(w Wallet, tx *wire.MsgTx, name string) error {
+	_, err := promptPublish(w, tx, name)
+	return err
+}
+
+// promptPublish asks the operator whether to publish tx and broadcasts it if
+// they agree, reporting whether the transaction was published.
+func promptPublish(w Wallet, tx *wire.MsgTx, name string) (published bool, err error) {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("Publish %s transaction? [y/N] ", name)
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return false, err
+		}
+		answer = strings.TrimSpace(strings.ToLower(answer))
+
+		switch answer {
+		case "y", "yes":
+		case "n", "no", "":
+			return false, nil
+		default:
+			fmt.Println("please answer y or n")
+			continue
+		}
+
+		txHash, err := w.Broadcast(tx)
+		if errors.Is(err, rpc.ErrTxNonFinal) {
+			return false, fmt.Errorf("%s transaction is not final until %v: %w",
+				name, lockTimeString(tx.LockTime), err)
+		}
+		if err != nil {
+			return false, fmt.Errorf("sendrawtransaction: %w", err)
+		}
+		fmt.Printf("Published %s transaction (%v)\n", name, txHash)
+		return true, nil
+	}
+}
+
+// swapBuilder returns the builder of the swap transactions, which checks
+// outputs for dust against the dust relay fee of the coin, applies the fee
+// ceilings of the fee policy and signs and pays to contracts as the coin
+// requires.
+func swapBuilder() *atomicswap.Builder {
+	return &atomicswap.Builder{
+		Params:        chainParams,
+		RelayFeePerKb: dustFeePerKb,
+		CheckFee:      feePolicy.CheckFee,
+		ForkID:        forkIDSigs,
+		Hashes:        txHashes,
+		SegWit:        segWitContracts,
+	}
+}
+
+// buildContract creates a contract for the parameters specified in args,
+// using the wallet to generate an address to redeem the refund and to fund
+// and sign the payment to the contract.  The inputs of the contract
+// transaction stay reserved until it is published or released.
+func buildContract(w Wallet, args *atomicswap.ContractArgs) (*atomicswap.BuiltContract, error) {
+	feePerKb, err := getFeePerKb(w)
+	if err != nil {
+		return nil, err
+	}
+
+	rw := &reservingWallet{Wallet: w}
+	b, err := swapBuilder().BuildContract(rw, args, feePerKb)
+	if err != nil {
+		rw.releaseFunded()
+		return nil, err
+	}
+	return b, nil
+}
+
+func sha256Hash(x []byte) []byte {
+	h := sha256.Sum256(x)
+	return h[:]
+}
+
+func calcFeePerKb(absoluteFee btcutil.Amount, serializeSize int) float64 {
+	return float64(absoluteFee) / float64(serializeSize) / 1e5
+}
+
+func (cmd *initiateCmd) runCommand(w Wallet) error {
+	var secret [atomicswap.SecretSize]byte
+	_, err := rand.Read(secret[:])
+	if err != nil {
+		return err
+	}
+	secretHash := sha256Hash(secret[:])
+
+	// locktime after 500,000,000 (Tue Nov  5 00:53:20 1985 UTC) is interpreted
+	// as a unix time rather than a block height.
+	locktime := time.Now().Add(48 * time.Hour).Unix()
+
+	b, err := buildContract(w, &atomicswap.ContractArgs{
+		Them:       cmd.cp2Addr,
+		Amount:     cmd.amount,
+		LockTime:   locktime,
+		SecretHash: secretHash,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Secret:      %x\n", secret)
+	fmt.Printf("Secret hash: %x\n\n", secretHash)
+	printBuiltContract(b)
+
+	return promptPublishContract(w, b.ContractTx)
+}
+
+func (cmd *participateCmd) runCommand(w Wallet) error {
+	// locktime after 500,000,000 (Tue Nov  5 00:53:20 1985 UTC) is interpreted
+	// as a unix time rather than a block height.
+	locktime := time.Now().Add(24 * time.Hour).Unix()
+
+	b, err := buildContract(w, &atomicswap.ContractArgs{
+		Them:       cmd.cp1Addr,
+		Amount:     cmd.amount,
+		LockTime:   locktime,
+		SecretHash: cmd.secretHash,
+	})
+	if err != nil {
+		return err
+	}
+
+	printBuiltContract(b)
+
+	return promptPublishContract(w, b.ContractTx)
+}
+
+// printBuiltContract prints the fees, the contract and the contract and refund
+// transactions of a new contract.
+func printBuiltContract(b *atomicswap.BuiltContract) {
+	refundTxHash := txHash(b.RefundTx)
+	contractFeePerKb := calcFeePerKb(b.ContractFee, atomicswap.VirtualSize(b.ContractTx))
+	refundFeePerKb := calcFeePerKb(b.RefundFee, atomicswap.VirtualSize(b.RefundTx))
+
+	fmt.Printf("Contract fee: %v (%0.8f %s/kB)\n", formatAmount(b.ContractFee), contractFeePerKb, coinSymbol)
+	fmt.Printf("Refund fee:   %v (%0.8f %s/kB)\n\n", formatAmount(b.RefundFee), refundFeePerKb, coinSymbol)
+	fmt.Printf("Contract (%v):\n", addrString(b.ContractAddress))
+	fmt.Printf("%x\n\n", b.Contract.Script)
+	var contractBuf bytes.Buffer
+	contractBuf.Grow(b.ContractTx.SerializeSize())
+	b.ContractTx.Serialize(&contractBuf)
+	fmt.Printf("Contract transaction (%v):\n", &b.ContractTxHash)
+	fmt.Printf("%x\n\n", contractBuf.Bytes())
+	var refundBuf bytes.Buffer
+	refundBuf.Grow(b.RefundTx.SerializeSize())
+	b.RefundTx.Serialize(&refundBuf)
+	fmt.Printf("Refund transaction (%v):\n", &refundTxHash)
+	fmt.Printf("%x\n\n", refundBuf.Bytes())
+}
+
+func (cmd *redeemCmd) runCommand(w Wallet) error {
+	contract, err := atomicswap.ParseContract(cmd.contract)
+	if err != nil {
+		return err
+	}
+
+	feePerKb, err := getFeePerKb(w)
+	if err != nil {
+		return err
+	}
+
+	redeemTx, fee, err := swapBuilder().BuildRedeem(w, contract, cmd.contractTx, cmd.secret, feePerKb)
+	if err != nil {
+		return err
+	}
+
+	redeemTxHash := txHash(redeemTx)
+	redeemFeePerKb := calcFeePerKb(fee, atomicswap.VirtualSize(redeemTx))
+
+	var buf bytes.Buffer
+	buf.Grow(redeemTx.SerializeSize())
+	redeemTx.Serialize(&buf)
+	fmt.Printf("Redeem fee: %v (%0.8f %s/kB)\n\n", formatAmount(fee), redeemFeePerKb, coinSymbol)
+	fmt.Printf("Redeem transaction (%v):\n", &redeemTxHash)
+	fmt.Printf("%x\n\n", buf.Bytes())
+
+	return promptPublishTx(w, redeemTx, "redeem")
+}
+
+func (cmd *refundCmd) runCommand(w Wallet) error {
+	contract, err := atomicswap.ParseContract(cmd.contract)
+	if err != nil {
+		return err
+	}
+
+	feePerKb, err := getFeePerKb(w)
+	if err != nil {
+		return err
+	}
+
+	refundTx, refundFee, err := swapBuilder().BuildRefund(w, contract, cmd.contractTx, feePerKb)
+	if err != nil {
+		return err
+	}
+	refundTxHash := txHash(refundTx)
+	var buf bytes.Buffer
+	buf.Grow(refundTx.SerializeSize())
+	refundTx.Serialize(&buf)
+
+	refundFeePerKb := calcFeePerKb(refundFee, atomicswap.VirtualSize(refundTx))
+
+	fmt.Printf("Refund fee: %v (%0.8f %s/kB)\n\n", formatAmount(refundFee), refundFeePerKb, coinSymbol)
+	fmt.Printf("Refund transaction (%v):\n", &refundTxHash)
+	fmt.Printf("%x\n\n", buf.Bytes())
+
+	return promptPublishTx(w, refundTx, "refund")
+}
+
+func (cmd *extractSecretCmd) runCommand(w Wallet) error {
+	return cmd.runOfflineCommand()
+}
+
+func (cmd *extractSecretCmd) runOfflineCommand() error {
+	secret, err := atomicswap.ExtractSecret(cmd.redemptionTx, cmd.secretHash)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Secret: %x\n", secret)
+	return nil
+}
+
+func (cmd *auditContractCmd) runCommand(w Wallet) error {
+	return cmd.runOfflineCommand()
+}
+
+func (cmd *auditContractCmd) runOfflineCommand() error {
+	audit, err := swapBuilder().AuditContract(cmd.contract, cmd.contractTx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Contract address:        %v\n", addrString(audit.ContractAddress))
+	fmt.Printf("Contract value:          %v\n", formatAmount(audit.Value))
+	fmt.Printf("Recipient address:       %v\n", addrString(audit.RecipientAddress))
+	fmt.Printf("Author's refund address: %v\n\n", addrString(audit.RefundAddress))
+
+	fmt.Printf("Secret hash: %x\n\n", audit.Contract.SecretHash[:])
+
+	if audit.Contract.LockTimeIsTime() {
+		t := time.Unix(audit.Contract.LockTime, 0)
+		fmt.Printf("Locktime: %v\n", t.UTC())
+		reachedAt := time.Until(t).Truncate(time.Second)
+		if reachedAt > 0 {
+			fmt.Printf("Locktime reached in %v\n", reachedAt)
+		} else {
+			fmt.Printf("Contract refund time lock has expired\n")
+		}
+	} else {
+		fmt.Printf("Locktime: block %v\n", audit.Contract.LockTime)
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+)
+
+// memWallet is a Wallet kept entirely in memory, for tests.  Its keys are
+// generated on demand, its outputs are added with addOutput and published
+// transactions are recorded instead of being sent to a network.  Outputs of
+// published transactions paying to the wallet become spendable right away.
+type memWallet struct {
+	feePerKb btcutil.Amount
+	keys     map[string]*btcec.PrivateKey
+	utxos    []*memOutput
+	locked   map[wire.OutPoint]bool
+	txs      map[chainhash.Hash]*wire.MsgTx
+}
+
+// memOutput is an unspent output of a memWallet.
+type memOutput struct {
+	unspentOutput
+	pkScript []byte
+	addr     btcutil.Address
+}
+
+// newMemWallet returns an empty in-memory wallet estimating fee rates of
+// feePerKb.
+func newMemWallet(feePerKb btcutil.Amount) *memWallet {
+	return &memWallet{
+		feePerKb: feePerKb,
+		keys:     make(map[string]*btcec.PrivateKey),
+		locked:   make(map[wire.OutPoint]bool),
+		txs:      make(map[chainhash.Hash]*wire.MsgTx),
+	}
+}
+
+// addOutput adds an output of value paying to addr, which must be an address
+// of the wallet, as if it had been received in the transaction with the
+// passed hash.
+func (w *memWallet) addOutput(txHash *chainhash.Hash, index uint32, addr btcutil.Address, value btcutil.Amount) error {
+	if _, ok := w.keys[addr.EncodeAddress()]; !ok {
+		return fmt.Errorf("address %v does not belong to the wallet", addr)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return err
+	}
+	w.utxos = append(w.utxos, &memOutput{
+		unspentOutput: unspentOutput{
+			OutPoint: *wire.NewOutPoint(txHash, index),
+			Value:    value,
+		},
+		pkScript: pkScript,
+		addr:     addr,
+	})
+	return nil
+}
+
+func (w *memWallet) NewAddress() (btcutil.Address, error) {
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+	pkh := btcutil.Hash160(privKey.PubKey().SerializeCompressed())
+	addr, err := btcutil.NewAddressPubKeyHash(pkh, chainParams)
+	if err != nil {
+		return nil, err
+	}
+	w.keys[addr.EncodeAddress()] = privKey
+	return addr, nil
+}
+
+// PayTo funds the transaction with the largest unlocked outputs first, using
+// the same coin selection the quote command estimates contract fees with.
+func (w *memWallet) PayTo(amounts map[btcutil.Address]btcutil.Amount, feePerKb btcutil.Amount) (*wire.MsgTx, btcutil.Amount, error) {
+	outputs, err := sortedOutputs(amounts)
+	if err != nil {
+		return nil, 0, err
+	}
+	var amount btcutil.Amount
+	for _, out := range outputs {
+		amount += btcutil.Amount(out.Value)
+	}
+
+	var available []*unspentOutput
+	spent := make(map[wire.OutPoint]*memOutput)
+	for _, utxo := range w.utxos {
+		if w.locked[utxo.OutPoint] {
+			continue
+		}
+		available = append(available, &utxo.unspentOutput)
+		spent[utxo.OutPoint] = utxo
+	}
+	selection, err := selectCoins(available, amount, feePerKb)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tx := wire.NewMsgTx(atomicswap.TxVersion)
+	for _, utxo := range selection.inputs {
+		tx.AddTxIn(wire.NewTxIn(&utxo.OutPoint, nil, nil))
+	}
+	for _, out := range outputs {
+		tx.AddTxOut(out)
+	}
+	if selection.change != 0 {
+		changeAddr, err := w.NewAddress()
+		if err != nil {
+			return nil, 0, err
+		}
+		changeScript, err := txscript.PayToAddrScript(changeAddr)
+		if err != nil {
+			return nil, 0, err
+		}
+		tx.AddTxOut(wire.NewTxOut(int64(selection.change), changeScript))
+	}
+	for i, txIn := range tx.TxIn {
+		utxo := spent[txIn.PreviousOutPoint]
+		sigScript, err := txscript.SignatureScript(tx, i, utxo.pkScript,
+			txscript.SigHashAll, w.keys[utxo.addr.EncodeAddress()], true)
+		if err != nil {
+			return nil, 0, err
+		}
+		txIn.SignatureScript = sigScript
+	}
+	return tx, selection.fee, nil
+}
+
+func (w *memWallet) CreateSig(tx *wire.MsgTx, idx int, pkScript []byte, addr btcutil.Address) (sig, pubkey []byte, err error) {
+	privKey, err := w.privKey(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signWithKey(tx, idx, pkScript, privKey)
+}
+
+func (w *memWallet) CreateWitnessSig(tx *wire.MsgTx, idx int, script []byte, amount int64,
+	addr btcutil.Address) (sig, pubkey []byte, err error) {
+
+	privKey, err := w.privKey(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signWitnessWithKey(tx, idx, script, amount, privKey)
+}
+
+func (w *memWallet) CreateForkIDSig(tx *wire.MsgTx, idx int, pkScript []byte, amount int64,
+	addr btcutil.Address) (sig, pubkey []byte, err error) {
+
+	privKey, err := w.privKey(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signForkIDWithKey(tx, idx, pkScript, amount, privKey)
+}
+
+func (w *memWallet) privKey(addr btcutil.Address) (*btcec.PrivateKey, error) {
+	privKey, ok := w.keys[addr.EncodeAddress()]
+	if !ok {
+		return nil, fmt.Errorf("address %v does not belong to the wallet", addr)
+	}
+	return privKey, nil
+}
+
+func (w *memWallet) FeeRate(confTarget int) (btcutil.Amount, error) {
+	return w.feePerKb, nil
+}
+
+// Broadcast records tx, removes the outputs it spends and adds the outputs
+// paying to the wallet.
+func (w *memWallet) Broadcast(tx *wire.MsgTx) (*chainhash.Hash, error) {
+	hash := txHash(tx)
+	if _, ok := w.txs[hash]; ok {
+		return nil, errors.New("transaction already published")
+	}
+	w.txs[hash] = tx
+
+	spent := make(map[wire.OutPoint]bool)
+	for _, txIn := range tx.TxIn {
+		spent[txIn.PreviousOutPoint] = true
+	}
+	utxos := w.utxos[:0]
+	for _, utxo := range w.utxos {
+		if !spent[utxo.OutPoint] {
+			utxos = append(utxos, utxo)
+		}
+	}
+	w.utxos = utxos
+
+	for i, txOut := range tx.TxOut {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript, chainParams)
+		if err != nil || len(addrs) != 1 {
+			continue
+		}
+		if _, ok := w.keys[addrs[0].EncodeAddress()]; ok {
+			w.addOutput(&hash, uint32(i), addrs[0], btcutil.Amount(txOut.Value))
+		}
+	}
+	return &hash, nil
+}
+
+func (w *memWallet) Transaction(txHash *chainhash.Hash) (*wire.MsgTx, error) {
+	tx, ok := w.txs[*txHash]
+	if !ok {
+		return nil, fmt.Errorf("transaction %v not found", txHash)
+	}
+	return tx, nil
+}
+
+func (w *memWallet) ListUnspent() ([]*unspentOutput, error) {
+	utxos := make([]*unspentOutput, 0, len(w.utxos))
+	for _, utxo := range w.utxos {
+		u := utxo.unspentOutput
+		utxos = append(utxos, &u)
+	}
+	return utxos, nil
+}
+
+func (w *memWallet) LockOutPoint(outPoint *wire.OutPoint) error {
+	w.locked[*outPoint] = true
+	return nil
+}
+
+func (w *memWallet) UnlockOutPoint(outPoint *wire.OutPoint) error {
+	delete(w.locked, *outPoint)
+	return nil
+}
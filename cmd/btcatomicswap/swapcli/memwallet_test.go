@@ -0,0 +1,247 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+)
+
+// testFeePerKb is the fee rate estimated by the wallets of the tests.
+const testFeePerKb = btcutil.Amount(10000)
+
+// useTestReservations records the reservations of the test in a temporary
+// store.
+func useTestReservations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reservations.json")
+	old := *reservationsFlag
+	*reservationsFlag = path
+	t.Cleanup(func() { *reservationsFlag = old })
+}
+
+// fundedMemWallet returns a wallet holding an output of each of the passed
+// values.
+func fundedMemWallet(t *testing.T, values ...btcutil.Amount) *memWallet {
+	w := newMemWallet(testFeePerKb)
+	for i, value := range values {
+		addr, err := w.NewAddress()
+		if err != nil {
+			t.Fatal(err)
+		}
+		txHash := chainhash.Hash{byte(i + 1)}
+		err = w.addOutput(&txHash, 0, addr, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w
+}
+
+// newTestContractArgs returns the arguments of a contract paying amount to a
+// new address of the participant's wallet, and the secret of the contract.
+func newTestContractArgs(t *testing.T, participant Wallet, amount btcutil.Amount) (*atomicswap.ContractArgs, []byte) {
+	addr, err := getUnusedAddress(participant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := bytes.Repeat([]byte{0x2a}, atomicswap.SecretSize)
+	return &atomicswap.ContractArgs{
+		Them:       addr,
+		Amount:     amount,
+		LockTime:   time.Now().Add(48 * time.Hour).Unix(),
+		SecretHash: sha256Hash(secret),
+	}, secret
+}
+
+// verifyInputs executes the scripts of every input of tx spending the outputs
+// of prevTx.
+func verifyInputs(t *testing.T, tx, prevTx *wire.MsgTx) {
+	t.Helper()
+	for i, txIn := range tx.TxIn {
+		prevOut := prevTx.TxOut[txIn.PreviousOutPoint.Index]
+		vm, err := txscript.NewEngine(prevOut.PkScript, tx, i,
+			txscript.StandardVerifyFlags, nil, nil, prevOut.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = vm.Execute()
+		if err != nil {
+			t.Fatalf("input %d: %v", i, err)
+		}
+	}
+}
+
+func TestSwapWithMemWallet(t *testing.T) {
+	useTestReservations(t)
+	initiator := fundedMemWallet(t, 2e8)
+	participant := newMemWallet(testFeePerKb)
+
+	args, secret := newTestContractArgs(t, participant, 1e8)
+	b, err := buildContract(initiator, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.ContractTx.TxOut[0].Value+b.ContractTx.TxOut[1].Value+int64(b.ContractFee) != 2e8 {
+		t.Fatalf("contract transaction does not spend the wallet output")
+	}
+
+	// The contract inputs are frozen in the wallet and reserved until the
+	// contract transaction is published.
+	r, err := loadReservations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contractTxHash := b.ContractTx.TxHash()
+	for _, txIn := range b.ContractTx.TxIn {
+		if !initiator.locked[txIn.PreviousOutPoint] {
+			t.Errorf("input %v is not locked", txIn.PreviousOutPoint)
+		}
+		if r.owner(txIn.PreviousOutPoint) != contractTxHash.String() {
+			t.Errorf("input %v is not reserved", txIn.PreviousOutPoint)
+		}
+	}
+
+	_, err = initiator.Broadcast(b.ContractTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = forgetReservation(b.ContractTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = loadReservations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 0 {
+		t.Fatalf("reservations left after publishing: %v", r)
+	}
+
+	redeemTx, _, err := swapBuilder().BuildRedeem(participant, b.Contract, b.ContractTx,
+		secret, testFeePerKb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyInputs(t, redeemTx, b.ContractTx)
+	extracted, err := atomicswap.ExtractSecret(redeemTx, args.SecretHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(extracted, secret) {
+		t.Fatalf("extracted secret %x, want %x", extracted, secret)
+	}
+	_, err = participant.Broadcast(redeemTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	utxos, err := participant.ListUnspent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(utxos) != 1 || int64(utxos[0].Value) != redeemTx.TxOut[0].Value {
+		t.Fatalf("redeemed output is not spendable by the participant")
+	}
+
+	refundTx, _, err := swapBuilder().BuildRefund(initiator, b.Contract, b.ContractTx, testFeePerKb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(refundTx.LockTime) != args.LockTime {
+		t.Fatalf("refund locktime is %d, want %d", refundTx.LockTime, args.LockTime)
+	}
+	verifyInputs(t, refundTx, b.ContractTx)
+	verifyInputs(t, b.RefundTx, b.ContractTx)
+}
+
+func TestReservationsPreventDoubleSpends(t *testing.T) {
+	useTestReservations(t)
+	initiator := fundedMemWallet(t, 2e8)
+	participant := newMemWallet(testFeePerKb)
+
+	args, _ := newTestContractArgs(t, participant, 1e8)
+	first, err := buildContract(initiator, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The only wallet output is reserved by the unpublished contract, so a
+	// second contract can not be funded, and the failure reserves nothing.
+	_, err = buildContract(initiator, args)
+	if err == nil {
+		t.Fatal("second contract funded with reserved inputs")
+	}
+	r, err := loadReservations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 {
+		t.Fatalf("%d reservations, want 1", len(r))
+	}
+
+	// Releasing the first contract makes its inputs available again.
+	err = releaseReservation(initiator, first.ContractTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, txIn := range first.ContractTx.TxIn {
+		if initiator.locked[txIn.PreviousOutPoint] {
+			t.Errorf("input %v is still locked", txIn.PreviousOutPoint)
+		}
+	}
+	second, err := buildContract(initiator, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ContractTx.TxIn[0].PreviousOutPoint != first.ContractTx.TxIn[0].PreviousOutPoint {
+		t.Fatal("second contract does not spend the released output")
+	}
+	err = releaseReservation(initiator, first.ContractTx)
+	if err == nil {
+		t.Fatal("released a reservation twice")
+	}
+}
+
+func TestSegWitSwapWithMemWallet(t *testing.T) {
+	useTestReservations(t)
+	segWitContracts = true
+	defer func() { segWitContracts = false }()
+	initiator := fundedMemWallet(t, 2e8)
+	participant := newMemWallet(testFeePerKb)
+
+	args, secret := newTestContractArgs(t, participant, 1e8)
+	b, err := buildContract(initiator, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.ContractAddress.(*btcutil.AddressWitnessScriptHash); !ok {
+		t.Fatalf("contract address %v is not P2WSH", b.ContractAddress)
+	}
+	verifyInputs(t, b.RefundTx, b.ContractTx)
+
+	redeemTx, _, err := swapBuilder().BuildRedeem(participant, b.Contract, b.ContractTx,
+		secret, testFeePerKb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(redeemTx.TxIn[0].Witness) == 0 || len(redeemTx.TxIn[0].SignatureScript) != 0 {
+		t.Fatal("redeem input does not spend the contract with a witness")
+	}
+	verifyInputs(t, redeemTx, b.ContractTx)
+	extracted, err := atomicswap.ExtractSecret(redeemTx, args.SecretHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(extracted, secret) {
+		t.Fatalf("extracted secret %x, want %x", extracted, secret)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+	"golang.org/x/crypto/ripemd160"
+)
+
+type quoteCmd struct {
+	amount btcutil.Amount
+}
+
+// coinSelection is the result of selecting wallet outputs to fund a contract.
+type coinSelection struct {
+	inputs []*unspentOutput
+	fee    btcutil.Amount
+	change btcutil.Amount
+}
+
+// selectCoins estimates the funding of a contract paying amount by adding the
+// largest wallet outputs first until they cover the amount and the fee.  A
+// change output that would be dust is left to the fee, as the wallet does.
+func selectCoins(utxos []*unspentOutput, amount, feePerKb btcutil.Amount) (*coinSelection, error) {
+	sorted := make([]*unspentOutput, len(utxos))
+	copy(sorted, utxos)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Value > sorted[j].Value
+	})
+
+	const p2pkhScriptSize = 25
+	var total btcutil.Amount
+	for i, utxo := range sorted {
+		total += utxo.Value
+		numInputs := i + 1
+
+		fee := txrules.FeeForSerializeSize(feePerKb,
+			atomicswap.EstimateContractSerializeSize(numInputs, true))
+		if total < amount+fee {
+			continue
+		}
+		change := total - amount - fee
+		if txrules.IsDustAmount(change, p2pkhScriptSize, feePerKb) {
+			return &coinSelection{sorted[:numInputs], total - amount, 0}, nil
+		}
+		return &coinSelection{sorted[:numInputs], fee, change}, nil
+	}
+	return nil, fmt.Errorf("insufficient funds: wallet balance of %v does "+
+		"not cover %v plus fees", total, amount)
+}
+
+// spendFees estimates the fees of the refund and redeem transactions of a
+// contract at feePerKb.  The contract and the refund and redeem scripts have
+// the same size regardless of the actual hashes, so placeholders are used.
+// The script of the output receiving the refunded or redeemed value is
+// returned for the dust checks.
+func spendFees(feePerKb btcutil.Amount) (refundFee, redeemFee btcutil.Amount, outScript []byte, err error) {
+	var placeholder [ripemd160.Size]byte
+	locktime := time.Now().Add(48 * time.Hour).Unix()
+	contract, err := atomicswap.AtomicSwapContract(&placeholder, &placeholder, locktime,
+		make([]byte, 32))
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	placeholderAddr, err := btcutil.NewAddressPubKeyHash(placeholder[:], chainParams)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	outScript, err = txscript.PayToAddrScript(placeholderAddr)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	txOuts := []*wire.TxOut{wire.NewTxOut(0, outScript)}
+	refundSize := atomicswap.EstimateRefundSerializeSize(contract, txOuts)
+	redeemSize := atomicswap.EstimateRedeemSerializeSize(contract, txOuts)
+	if segWitContracts {
+		refundSize = atomicswap.EstimateRefundWitnessVSize(contract, txOuts)
+		redeemSize = atomicswap.EstimateRedeemWitnessVSize(contract, txOuts)
+	}
+	refundFee = txrules.FeeForSerializeSize(feePerKb, refundSize)
+	redeemFee = txrules.FeeForSerializeSize(feePerKb, redeemSize)
+	return refundFee, redeemFee, outScript, nil
+}
+
+func (cmd *quoteCmd) runCommand(w Wallet) error {
+	feePerKb, err := getFeePerKb(w)
+	if err != nil {
+		return err
+	}
+
+	utxos, err := w.ListUnspent()
+	if err != nil {
+		return fmt.Errorf("listunspent: %w", err)
+	}
+
+	// Outputs reserved by unpublished contracts are not available.
+	var available []*unspentOutput
+	var balance, reserved btcutil.Amount
+	r, err := loadReservations()
+	if err != nil {
+		return err
+	}
+	for _, utxo := range utxos {
+		if r.owner(utxo.OutPoint) != "" {
+			reserved += utxo.Value
+			continue
+		}
+		available = append(available, utxo)
+		balance += utxo.Value
+	}
+
+	refundFee, redeemFee, outScript, err := spendFees(feePerKb)
+	if err != nil {
+		return err
+	}
+
+	refundValue := cmd.amount - refundFee
+	redeemValue := cmd.amount - redeemFee
+
+	fmt.Printf("Fee rate:              %v/kB\n", formatAmount(feePerKb))
+	fmt.Printf("Contract amount:       %v\n", formatAmount(cmd.amount))
+	fmt.Printf("Wallet balance:        %v", formatAmount(balance))
+	if reserved != 0 {
+		fmt.Printf(" (%v reserved by unpublished contracts)", formatAmount(reserved))
+	}
+	fmt.Printf("\n\n")
+
+	selection, selectErr := selectCoins(available, cmd.amount, feePerKb)
+	if selectErr == nil {
+		fmt.Printf("Contract fee:          %v (%d inputs)\n", formatAmount(selection.fee), len(selection.inputs))
+	} else {
+		fmt.Printf("Contract fee:          unknown\n")
+	}
+	fmt.Printf("Refund fee:            %v\n", formatAmount(refundFee))
+	fmt.Printf("Redeem fee:            %v\n\n", formatAmount(redeemFee))
+
+	if selectErr == nil {
+		fmt.Printf("Total cost:            %v\n", formatAmount(cmd.amount+selection.fee))
+		if selection.change != 0 {
+			fmt.Printf("Change:                %v\n", formatAmount(selection.change))
+		}
+	}
+	fmt.Printf("Counterparty receives: %v\n", formatAmount(redeemValue))
+	fmt.Printf("Refund returns:        %v\n\n", formatAmount(refundValue))
+
+	if selectErr == nil {
+		err = feePolicy.CheckFee("contract", int64(selection.fee), int64(cmd.amount))
+		if err != nil {
+			fmt.Printf("warning: %v\n", err)
+		}
+	}
+	err = feePolicy.CheckFee("refund", int64(refundFee), int64(cmd.amount))
+	if err != nil {
+		fmt.Printf("warning: %v\n", err)
+	}
+	err = feePolicy.CheckFee("redeem", int64(redeemFee), int64(cmd.amount))
+	if err != nil {
+		fmt.Printf("warning: %v\n", err)
+	}
+	if txrules.IsDustAmount(redeemValue, len(outScript), dustRelayFeePerKb(feePerKb)) {
+		fmt.Printf("warning: redeem output value of %v is dust\n", formatAmount(redeemValue))
+	}
+	if txrules.IsDustAmount(refundValue, len(outScript), dustRelayFeePerKb(feePerKb)) {
+		fmt.Printf("warning: refund output value of %v is dust\n", formatAmount(refundValue))
+	}
+
+	if selectErr != nil {
+		return selectErr
+	}
+	if redeemValue <= 0 || refundValue <= 0 {
+		return errors.New("contract amount does not cover the redeem and refund fees")
+	}
+	return nil
+}
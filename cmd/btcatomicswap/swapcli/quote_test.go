@@ -0,0 +1,161 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+)
+
+func TestSelectCoins(t *testing.T) {
+	contractFee := func(numInputs int) btcutil.Amount {
+		return txrules.FeeForSerializeSize(testFeePerKb,
+			atomicswap.EstimateContractSerializeSize(numInputs, true))
+	}
+	fee1, fee2 := contractFee(1), contractFee(2)
+
+	tests := []struct {
+		name      string
+		values    []btcutil.Amount
+		amount    btcutil.Amount
+		numInputs int
+		fee       btcutil.Amount
+		change    btcutil.Amount
+	}{
+		{
+			name:      "largest output first",
+			values:    []btcutil.Amount{5e7, 1e8},
+			amount:    4e7,
+			numInputs: 1,
+			fee:       fee1,
+			change:    1e8 - 4e7 - fee1,
+		},
+		{
+			name:      "two outputs",
+			values:    []btcutil.Amount{5e7, 1e8},
+			amount:    1.2e8,
+			numInputs: 2,
+			fee:       fee2,
+			change:    1.5e8 - 1.2e8 - fee2,
+		},
+		{
+			name:      "output covering the fee of one input only",
+			values:    []btcutil.Amount{1e8, 1e4},
+			amount:    1e8 - fee1,
+			numInputs: 1,
+			fee:       fee1,
+		},
+		{
+			name:      "dust change left to the fee",
+			values:    []btcutil.Amount{1e6},
+			amount:    1e6 - fee1 - 100,
+			numInputs: 1,
+			fee:       fee1 + 100,
+		},
+	}
+	for _, test := range tests {
+		var utxos []*unspentOutput
+		for i, value := range test.values {
+			utxos = append(utxos, &unspentOutput{
+				OutPoint: wire.OutPoint{Hash: chainhash.Hash{byte(i)}},
+				Value:    value,
+			})
+		}
+		selection, err := selectCoins(utxos, test.amount, testFeePerKb)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(selection.inputs) != test.numInputs || selection.fee != test.fee ||
+			selection.change != test.change {
+			t.Errorf("%s: %d inputs, fee %v, change %v, want %d inputs, fee %v, change %v",
+				test.name, len(selection.inputs), selection.fee, selection.change,
+				test.numInputs, test.fee, test.change)
+		}
+	}
+
+	utxos := []*unspentOutput{{Value: 1e6}}
+	_, err := selectCoins(utxos, 1e6-fee1+1, testFeePerKb)
+	if err == nil || !strings.Contains(err.Error(), "insufficient funds") {
+		t.Errorf("error is %v, want insufficient funds", err)
+	}
+}
+
+func TestSpendFees(t *testing.T) {
+	for _, segWit := range []bool{false, true} {
+		func() {
+			useTestReservations(t)
+			segWitContracts = segWit
+			defer func() { segWitContracts = false }()
+
+			refundFee, redeemFee, outScript, err := spendFees(testFeePerKb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(outScript) != 25 {
+				t.Errorf("output script is %d bytes", len(outScript))
+			}
+
+			// The estimates are the fees the swap transactions pay.
+			initiator := fundedMemWallet(t, 2e8)
+			participant := newMemWallet(testFeePerKb)
+			args, secret := newTestContractArgs(t, participant, 1e8)
+			b, err := buildContract(initiator, args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, fee, err := swapBuilder().BuildRedeem(participant, b.Contract, b.ContractTx,
+				secret, testFeePerKb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if refundFee != b.RefundFee || redeemFee != fee {
+				t.Errorf("segwit %v: estimated refund fee %v and redeem fee %v, paid %v and %v",
+					segWit, refundFee, redeemFee, b.RefundFee, fee)
+			}
+		}()
+	}
+}
+
+func TestQuote(t *testing.T) {
+	useTestReservations(t)
+	initiator := fundedMemWallet(t, 2e8)
+	participant := newMemWallet(testFeePerKb)
+
+	err := (&quoteCmd{amount: 1e8}).runCommand(initiator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = (&quoteCmd{amount: 1000}).runCommand(initiator)
+	if err == nil || !strings.Contains(err.Error(), "does not cover") {
+		t.Errorf("amount below the fees: error is %v", err)
+	}
+
+	// The only output is reserved by an unpublished contract, so it can
+	// not fund another one.
+	args, _ := newTestContractArgs(t, participant, 1e8)
+	b, err := buildContract(initiator, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = (&quoteCmd{amount: 1e8}).runCommand(initiator)
+	if err == nil || !strings.Contains(err.Error(), "insufficient funds") {
+		t.Errorf("reserved output: error is %v", err)
+	}
+	err = releaseReservation(initiator, b.ContractTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = (&quoteCmd{amount: 1e8}).runCommand(initiator)
+	if err != nil {
+		t.Errorf("released output: %v", err)
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// reservationLockTimeout is how long to wait for another btcatomicswap
+// process to release the reservation store before giving up.
+const reservationLockTimeout = 30 * time.Second
+
+// reservations maps the hash of an unpublished contract transaction to the
+// wallet outpoints it spends.  Outpoints are stored in their txid:index
+// string form.
+type reservations map[string][]string
+
+// owner returns the hash of the contract transaction that reserved the
+// outpoint, or an empty string if the outpoint is not reserved.
+func (r reservations) owner(outPoint wire.OutPoint) string {
+	op := outPoint.String()
+	for txHash, outPoints := range r {
+		for _, reserved := range outPoints {
+			if reserved == op {
+				return txHash
+			}
+		}
+	}
+	return ""
+}
+
+// reservationsPath returns the file that records the reserved outpoints.
+func reservationsPath() string {
+	if *reservationsFlag != "" {
+		return *reservationsFlag
+	}
+	return filepath.Join(btcutil.AppDataDir("btcatomicswap", false),
+		chainParams.Name, "reservations.json")
+}
+
+// withReservations loads the reservation store, calls fn and writes back any
+// changes fn made.  The store is locked for the duration of the call so that
+// concurrent btcatomicswap processes using the same wallet see each other's
+// reservations.
+func withReservations(fn func(reservations) error) error {
+	path := reservationsPath()
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(reservationLockTimeout)
+	for {
+		lockFile, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			lockFile.Close()
+			break
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("reservation store is locked by another "+
+				"process (remove %s if it is stale)", lockPath)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	defer os.Remove(lockPath)
+
+	r, err := readReservations(path)
+	if err != nil {
+		return err
+	}
+
+	err = fn(r)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, b, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// loadReservations returns the reservation store without locking it, for
+// commands that only report reservations.  The store is replaced atomically
+// when written, so a concurrent update is either fully seen or not at all.
+func loadReservations() (reservations, error) {
+	return readReservations(reservationsPath())
+}
+
+// readReservations decodes the reservation store at path.  A missing store
+// has no reservations.
+func readReservations(path string) (reservations, error) {
+	r := make(reservations)
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(b) != 0 {
+		err = json.Unmarshal(b, &r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+		}
+	}
+	return r, nil
+}
+
+// reserve freezes the wallet outputs spent by the unpublished contract
+// transaction tx and records them in r.  An error is returned without
+// reserving anything if one of the inputs is already reserved by another
+// contract transaction.
+func (r reservations) reserve(w Wallet, tx *wire.MsgTx) error {
+	hash := txHash(tx).String()
+	for _, txIn := range tx.TxIn {
+		if owner := r.owner(txIn.PreviousOutPoint); owner != "" && owner != hash {
+			return fmt.Errorf("input %v is already reserved by unpublished "+
+				"contract transaction %v", txIn.PreviousOutPoint, owner)
+		}
+	}
+
+	outPoints := make([]string, 0, len(tx.TxIn))
+	for _, txIn := range tx.TxIn {
+		err := w.LockOutPoint(&txIn.PreviousOutPoint)
+		if err != nil {
+			for i := range tx.TxIn[:len(outPoints)] {
+				w.UnlockOutPoint(&tx.TxIn[i].PreviousOutPoint)
+			}
+			return fmt.Errorf("lock output %v: %w", txIn.PreviousOutPoint, err)
+		}
+		outPoints = append(outPoints, txIn.PreviousOutPoint.String())
+	}
+	r[hash] = outPoints
+	return nil
+}
+
+// release unfreezes the wallet outputs reserved by the contract transaction
+// with hash txHash and removes the reservation.
+func (r reservations) release(w Wallet, txHash *chainhash.Hash) error {
+	outPoints, ok := r[txHash.String()]
+	if !ok {
+		return fmt.Errorf("no reservation for contract transaction %v", txHash)
+	}
+	for _, op := range outPoints {
+		outPoint, err := parseOutPoint(op)
+		if err != nil {
+			return err
+		}
+		err = w.UnlockOutPoint(outPoint)
+		if err != nil {
+			return fmt.Errorf("unlock output %v: %w", op, err)
+		}
+	}
+	delete(r, txHash.String())
+	return nil
+}
+
+// parseOutPoint decodes an outpoint in the txid:index form produced by
+// wire.OutPoint.String.
+func parseOutPoint(s string) (*wire.OutPoint, error) {
+	sep := strings.LastIndexByte(s, ':')
+	if sep == -1 {
+		return nil, fmt.Errorf("invalid outpoint %q", s)
+	}
+	hash, err := chainhash.NewHashFromStr(s[:sep])
+	if err != nil {
+		return nil, fmt.Errorf("invalid outpoint %q: %v", s, err)
+	}
+	index, err := strconv.ParseUint(s[sep+1:], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid outpoint %q: %v", s, err)
+	}
+	return wire.NewOutPoint(hash, uint32(index)), nil
+}
+
+// releaseReservation releases the reservation held by the contract
+// transaction tx.
+func releaseReservation(w Wallet, tx *wire.MsgTx) error {
+	hash := txHash(tx)
+	return withReservations(func(r reservations) error {
+		return r.release(w, &hash)
+	})
+}
+
+// forgetReservation drops the reservation held by the contract transaction tx
+// without unfreezing its inputs.  It is used once the contract transaction
+// has been published and the reserved outputs are spent.
+func forgetReservation(tx *wire.MsgTx) error {
+	hash := txHash(tx).String()
+	return withReservations(func(r reservations) error {
+		delete(r, hash)
+		return nil
+	})
+}
+
+// reservingWallet reserves the wallet outputs spent by the transactions it
+// funds.  Funding and reserving the inputs happen while holding the
+// reservation store so that a concurrent swap can not select the same coins
+// before they are frozen in the wallet.
+type reservingWallet struct {
+	Wallet
+
+	// funded is the last transaction funded and reserved by PayTo.
+	funded *wire.MsgTx
+}
+
+func (w *reservingWallet) PayTo(amounts map[btcutil.Address]btcutil.Amount, feePerKb btcutil.Amount) (
+	tx *wire.MsgTx, fee btcutil.Amount, err error) {
+
+	err = withReservations(func(r reservations) error {
+		tx, fee, err = w.Wallet.PayTo(amounts, feePerKb)
+		if err != nil {
+			return err
+		}
+		err = r.reserve(w.Wallet, tx)
+		if err != nil {
+			return err
+		}
+		w.funded = tx
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return tx, fee, nil
+}
+
+// releaseFunded releases the reservation of the transaction funded by PayTo,
+// if any, when it is not going to be published.
+func (w *reservingWallet) releaseFunded() {
+	if w.funded == nil {
+		return
+	}
+	if err := releaseReservation(w.Wallet, w.funded); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to release reserved inputs: %v\n", err)
+	}
+	w.funded = nil
+}
+
+// promptPublishContract asks the operator whether to publish the contract
+// transaction.  The reservation of the contract inputs is dropped once the
+// transaction is published and released when the operator declines or the
+// broadcast fails.
+func promptPublishContract(w Wallet, contractTx *wire.MsgTx) error {
+	published, err := promptPublish(w, contractTx, "contract")
+	if published {
+		return forgetReservation(contractTx)
+	}
+	if relErr := releaseReservation(w, contractTx); relErr != nil {
+		fmt.Fprintf(os.Stderr, "failed to release reserved inputs: %v\n", relErr)
+	}
+	return err
+}
+
+func (cmd *releaseCmd) runCommand(w Wallet) error {
+	err := releaseReservation(w, cmd.contractTx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Released inputs of contract transaction %v\n", txHash(cmd.contractTx))
+	return nil
+}
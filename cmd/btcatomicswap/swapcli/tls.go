@@ -0,0 +1,94 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+
+	rpc "github.com/robvanmieghem/electrumatomicswap/cmd/btcatomicswap/rpcclient"
+)
+
+// configureTLS applies the TLS flags to the connection configuration.  TLS is
+// enabled by -tls or by any of the certificate flags.  A warning is printed
+// when the RPC password would be sent in clear text to a remote host.
+func configureTLS(config *rpc.ConnConfig) error {
+	useTLS := *tlsFlag || *rpcCertFlag != "" || *rpcClientCertFlag != "" ||
+		*rpcCertFingerprintFlag != ""
+	if !useTLS {
+		if *rpcClientKeyFlag != "" {
+			return errors.New("-rpcclientkey requires -rpcclientcert")
+		}
+		config.DisableTLS = true
+		if config.Pass != "" && !isLoopback(config.Host) {
+			fmt.Fprintf(os.Stderr, "warning: sending the RPC password to %s "+
+				"in clear text, use -tls\n", config.Host)
+		}
+		return nil
+	}
+
+	var err error
+	if *rpcCertFlag != "" {
+		config.Certificates, err = ioutil.ReadFile(*rpcCertFlag)
+		if err != nil {
+			return fmt.Errorf("rpccert: %v", err)
+		}
+	}
+
+	if (*rpcClientCertFlag == "") != (*rpcClientKeyFlag == "") {
+		return errors.New("-rpcclientcert and -rpcclientkey must be used together")
+	}
+	if *rpcClientCertFlag != "" {
+		config.ClientCertificate, err = ioutil.ReadFile(*rpcClientCertFlag)
+		if err != nil {
+			return fmt.Errorf("rpcclientcert: %v", err)
+		}
+		config.ClientKey, err = ioutil.ReadFile(*rpcClientKeyFlag)
+		if err != nil {
+			return fmt.Errorf("rpcclientkey: %v", err)
+		}
+	}
+
+	if *rpcCertFingerprintFlag != "" {
+		config.CertificateSHA256, err = parseFingerprint(*rpcCertFingerprintFlag)
+		if err != nil {
+			return fmt.Errorf("rpccertfingerprint: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// parseFingerprint decodes a hex SHA-256 certificate fingerprint.  The bytes
+// may be separated by colons, as printed by openssl x509 -fingerprint.
+func parseFingerprint(s string) ([]byte, error) {
+	fingerprint, err := hex.DecodeString(strings.Replace(s, ":", "", -1))
+	if err != nil {
+		return nil, err
+	}
+	if len(fingerprint) != sha256.Size {
+		return nil, fmt.Errorf("fingerprint must be %d bytes", sha256.Size)
+	}
+	return fingerprint, nil
+}
+
+// isLoopback reports whether hostport refers to the local host.
+func isLoopback(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"sort"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+)
+
+// Wallet is the wallet backend the commands create, fund, sign and publish
+// transactions with.  Implementations exist for the Electrum daemon, Bitcoin
+// Core, the built-in HD wallet and an in-memory wallet for tests.
+type Wallet interface {
+	// NewAddress returns a new address of the wallet to receive refunds
+	// and redemptions.
+	NewAddress() (btcutil.Address, error)
+
+	// PayTo returns a funded and signed transaction paying the amounts,
+	// and its fee.  The transaction is not published.
+	PayTo(amounts map[btcutil.Address]btcutil.Amount, feePerKb btcutil.Amount) (tx *wire.MsgTx, fee btcutil.Amount, err error)
+
+	// CreateSig returns the raw signature of input idx of tx, which spends
+	// an output with pkScript, by the key of addr, and the serialized
+	// compressed pubkey of that key.
+	CreateSig(tx *wire.MsgTx, idx int, pkScript []byte, addr btcutil.Address) (sig, pubkey []byte, err error)
+
+	// CreateWitnessSig and CreateForkIDSig are like CreateSig for P2WSH
+	// contract outputs and for coins with SIGHASH_FORKID signatures, and
+	// commit to the amount of the spent output.
+	CreateWitnessSig(tx *wire.MsgTx, idx int, script []byte, amount int64, addr btcutil.Address) (sig, pubkey []byte, err error)
+	CreateForkIDSig(tx *wire.MsgTx, idx int, pkScript []byte, amount int64, addr btcutil.Address) (sig, pubkey []byte, err error)
+
+	// FeeRate returns the fee rate per kilobyte estimated for a
+	// transaction to confirm within confTarget blocks.
+	FeeRate(confTarget int) (btcutil.Amount, error)
+
+	// Broadcast publishes tx and returns its hash.
+	Broadcast(tx *wire.MsgTx) (*chainhash.Hash, error)
+
+	// Transaction looks up the transaction with the passed hash.
+	Transaction(txHash *chainhash.Hash) (*wire.MsgTx, error)
+
+	// ListUnspent returns the unspent outputs of the wallet, including
+	// locked outputs.
+	ListUnspent() ([]*unspentOutput, error)
+
+	// LockOutPoint excludes a wallet output from funding transactions
+	// until UnlockOutPoint is called for it.
+	LockOutPoint(outPoint *wire.OutPoint) error
+	UnlockOutPoint(outPoint *wire.OutPoint) error
+}
+
+// unspentOutput is an unspent output of a wallet.
+type unspentOutput struct {
+	OutPoint wire.OutPoint
+	Value    btcutil.Amount
+}
+
+// signWithKey creates the raw signature of input idx of tx with privKey, over
+// the signature hash of the coin in use, and returns it with the serialized
+// compressed pubkey.  It is shared by the wallets that sign in the client.
+func signWithKey(tx *wire.MsgTx, idx int, pkScript []byte, privKey *btcec.PrivateKey) (sig, pubkey []byte, err error) {
+	if txHashes != nil {
+		sig, err = txHashes.RawTxInSignature(tx, idx, pkScript, 0, privKey)
+	} else {
+		sig, err = txscript.RawTxInSignature(tx, idx, pkScript, txscript.SigHashAll, privKey)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return sig, privKey.PubKey().SerializeCompressed(), nil
+}
+
+// signWitnessWithKey is like signWithKey for the BIP143 signature of input
+// idx of tx spending amount from a P2WSH output with the witness script.
+func signWitnessWithKey(tx *wire.MsgTx, idx int, script []byte, amount int64, privKey *btcec.PrivateKey) (sig, pubkey []byte, err error) {
+	sig, err = txscript.RawTxInWitnessSignature(tx, txscript.NewTxSigHashes(tx), idx, amount,
+		script, txscript.SigHashAll, privKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sig, privKey.PubKey().SerializeCompressed(), nil
+}
+
+// signForkIDWithKey is like signWithKey for the SIGHASH_FORKID signature of
+// input idx of tx spending amount.
+func signForkIDWithKey(tx *wire.MsgTx, idx int, pkScript []byte, amount int64, privKey *btcec.PrivateKey) (sig, pubkey []byte, err error) {
+	sig, err = atomicswap.RawTxInForkIDSignature(tx, idx, pkScript, amount, privKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sig, privKey.PubKey().SerializeCompressed(), nil
+}
+
+// sortedOutputs returns the outputs paying amounts, ordered by address so the
+// transaction does not depend on map iteration order.
+func sortedOutputs(amounts map[btcutil.Address]btcutil.Amount) ([]*wire.TxOut, error) {
+	addrs := make([]btcutil.Address, 0, len(amounts))
+	for addr := range amounts {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].EncodeAddress() < addrs[j].EncodeAddress()
+	})
+	outputs := make([]*wire.TxOut, 0, len(addrs))
+	for _, addr := range addrs {
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, wire.NewTxOut(int64(amounts[addr]), pkScript))
+	}
+	return outputs, nil
+}
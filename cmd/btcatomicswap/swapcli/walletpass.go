@@ -0,0 +1,77 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// walletPassEnv is the environment variable holding the wallet password.
+const walletPassEnv = "BTCATOMICSWAP_WALLETPASS"
+
+// readWalletPass returns the password of an encrypted Electrum wallet from
+// the first configured source: the -walletpass flag, the -walletpassfile file,
+// the environment or an interactive prompt.  An empty password is returned
+// when no source is configured.
+func readWalletPass() (string, error) {
+	sources := 0
+	for _, set := range []bool{*walletPassFlag != "", *walletPassFileFlag != "", *walletPassPromptFlag} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return "", errors.New("-walletpass, -walletpassfile and " +
+			"-walletpassprompt are mutually exclusive")
+	}
+
+	switch {
+	case *walletPassFlag != "":
+		return *walletPassFlag, nil
+
+	case *walletPassFileFlag != "":
+		b, err := ioutil.ReadFile(*walletPassFileFlag)
+		if err != nil {
+			return "", err
+		}
+		// Only the line terminator is stripped, passwords may
+		// start or end with other white space.
+		pass := strings.TrimRight(string(b), "\r\n")
+		if pass == "" {
+			return "", fmt.Errorf("%s is empty", *walletPassFileFlag)
+		}
+		return pass, nil
+
+	case *walletPassPromptFlag:
+		return promptWalletPass()
+	}
+
+	return os.Getenv(walletPassEnv), nil
+}
+
+// promptWalletPass reads the wallet password from the terminal without
+// echoing it.
+func promptWalletPass() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", errors.New("-walletpassprompt requires a terminal")
+	}
+	fmt.Print("Wallet password: ")
+	pass, err := terminal.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	if len(pass) == 0 {
+		return "", errors.New("empty password")
+	}
+	return string(pass), nil
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadWalletPass(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		err := ioutil.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	passFile := writeFile("pass", " secret \r\n")
+	emptyFile := writeFile("empty", "\n")
+
+	tests := []struct {
+		name   string
+		pass   string
+		file   string
+		prompt bool
+		env    string
+		want   string
+		err    string
+	}{
+		{
+			name: "no source",
+		},
+		{
+			name: "flag",
+			pass: "secret",
+			env:  "other",
+			want: "secret",
+		},
+		{
+			// Only the line terminator is stripped.
+			name: "file",
+			file: passFile,
+			env:  "other",
+			want: " secret ",
+		},
+		{
+			name: "empty file",
+			file: emptyFile,
+			err:  "is empty",
+		},
+		{
+			name: "missing file",
+			file: filepath.Join(dir, "missing"),
+			err:  "no such file",
+		},
+		{
+			name: "environment",
+			env:  "secret",
+			want: "secret",
+		},
+		{
+			name: "flag and file",
+			pass: "secret",
+			file: passFile,
+			err:  "mutually exclusive",
+		},
+		{
+			name:   "file and prompt",
+			file:   passFile,
+			prompt: true,
+			err:    "mutually exclusive",
+		},
+	}
+
+	oldPass, oldFile, oldPrompt := *walletPassFlag, *walletPassFileFlag, *walletPassPromptFlag
+	oldEnv, envSet := os.LookupEnv(walletPassEnv)
+	defer func() {
+		*walletPassFlag, *walletPassFileFlag, *walletPassPromptFlag = oldPass, oldFile, oldPrompt
+		if envSet {
+			os.Setenv(walletPassEnv, oldEnv)
+		} else {
+			os.Unsetenv(walletPassEnv)
+		}
+	}()
+
+	for _, test := range tests {
+		*walletPassFlag, *walletPassFileFlag, *walletPassPromptFlag = test.pass, test.file, test.prompt
+		os.Setenv(walletPassEnv, test.env)
+
+		pass, err := readWalletPass()
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error is %v, want %s", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if pass != test.want {
+			t.Errorf("%s: password is %q, want %q", test.name, pass, test.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package swapcli
+
+import (
+	"fmt"
+
+	rpc "github.com/robvanmieghem/electrumatomicswap/cmd/btcatomicswap/rpcclient"
+)
+
+func (cmd *listWalletsCmd) runCommand(w Wallet) error {
+	c, err := electrumClient(w)
+	if err != nil {
+		return err
+	}
+	wallets, err := c.ListWallets()
+	if err != nil {
+		return fmt.Errorf("list_wallets: %w", err)
+	}
+	if len(wallets) == 0 {
+		fmt.Println("No wallets loaded")
+		return nil
+	}
+	for _, w := range wallets {
+		status := "synchronized"
+		if !w.Synchronized {
+			status = "synchronizing"
+		}
+		selected := ""
+		if w.Path == *walletFlag {
+			selected = " (selected with -wallet)"
+		}
+		fmt.Printf("%s: %s%s\n", w.Path, status, selected)
+	}
+	return nil
+}
+
+func (cmd *loadWalletCmd) runCommand(w Wallet) error {
+	c, err := electrumClient(w)
+	if err != nil {
+		return err
+	}
+	err = c.LoadWallet(cmd.walletPath)
+	if err != nil {
+		return fmt.Errorf("load_wallet: %w", err)
+	}
+	fmt.Printf("Loaded wallet %s\n", cmd.walletPath)
+	if *walletFlag != cmd.walletPath {
+		fmt.Printf("Select it for other commands with -wallet %s\n", cmd.walletPath)
+	}
+	return nil
+}
+
+// walletLoaded reports whether the wallet at path is one of wallets.
+func walletLoaded(wallets []rpc.WalletInfo, path string) bool {
+	for _, w := range wallets {
+		if w.Path == path {
+			return true
+		}
+	}
+	return false
+}
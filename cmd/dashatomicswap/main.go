@@ -0,0 +1,16 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Command dashatomicswap performs atomic swaps of Dash with Electrum-Dash.
+// It is btcatomicswap with the Dash definition of the coins directory.
+package main
+
+import (
+	"github.com/robvanmieghem/electrumatomicswap/cmd/btcatomicswap/swapcli"
+	"github.com/robvanmieghem/electrumatomicswap/coins"
+)
+
+func main() {
+	swapcli.Main("dashatomicswap", coins.Dash)
+}
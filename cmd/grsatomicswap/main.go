@@ -0,0 +1,17 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Command grsatomicswap performs atomic swaps of Groestlcoin with Electrum-GRS.
+// It is btcatomicswap with the Groestlcoin definition of the coins
+// directory.
+package main
+
+import (
+	"github.com/robvanmieghem/electrumatomicswap/cmd/btcatomicswap/swapcli"
+	"github.com/robvanmieghem/electrumatomicswap/coins"
+)
+
+func main() {
+	swapcli.Main("grsatomicswap", coins.Groestlcoin)
+}
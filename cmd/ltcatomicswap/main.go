@@ -20,24 +20,17 @@ import (
 	"strings"
 	"time"
 
-	"github.com/ltcsuite/ltcd/chaincfg"
-	"github.com/ltcsuite/ltcd/chaincfg/chainhash"
-	rpc "github.com/ltcsuite/ltcd/rpcclient"
-	"github.com/ltcsuite/ltcd/txscript"
-	"github.com/ltcsuite/ltcd/wire"
-	"github.com/ltcsuite/ltcutil"
-	"github.com/ltcsuite/ltcwallet/wallet/txrules"
-	"golang.org/x/crypto/ripemd160"
+	"github.com/btcsuite/btcd/chaincfg"
+	rpc "github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+	"github.com/robvanmieghem/electrumatomicswap/feepolicy"
 )
 
-const verify = true
-
-const secretSize = 32
-
-const txVersion = 2
-
 var (
-	chainParams = &chaincfg.MainNetParams
+	chainParams = &litecoinMainNetParams
 )
 
 var (
@@ -46,6 +39,9 @@ var (
 	rpcuserFlag = flagset.String("rpcuser", "", "username for wallet RPC authentication")
 	rpcpassFlag = flagset.String("rpcpass", "", "password for wallet RPC authentication")
 	testnetFlag = flagset.Bool("testnet", false, "use testnet network")
+	legacyFlag  = flagset.Bool("legacy", false, "build P2SH contracts and use P2PKH wallet addresses instead of P2WSH and bech32")
+
+	feePolicy feepolicy.Policy
 )
 
 // There are two directions that the atomic swap can be performed, as the
@@ -71,6 +67,8 @@ var (
 //   cp2 redeems ltc with S
 
 func init() {
+	feePolicy.RegisterFlags(flagset)
+
 	flagset.Usage = func() {
 		fmt.Println("Usage: ltcatomicswap [flags] cmd [cmd args]")
 		fmt.Println()
@@ -88,7 +86,7 @@ func init() {
 }
 
 type command interface {
-	runCommand(*rpc.Client) error
+	runCommand(*wallet) error
 }
 
 // offline commands don't require wallet RPC.
@@ -98,13 +96,13 @@ type offlineCommand interface {
 }
 
 type initiateCmd struct {
-	cp2Addr *ltcutil.AddressPubKeyHash
-	amount  ltcutil.Amount
+	cp2Addr *btcutil.AddressPubKeyHash
+	amount  btcutil.Amount
 }
 
 type participateCmd struct {
-	cp1Addr    *ltcutil.AddressPubKeyHash
-	amount     ltcutil.Amount
+	cp1Addr    *btcutil.AddressPubKeyHash
+	amount     btcutil.Amount
 	secretHash []byte
 }
 
@@ -187,13 +185,13 @@ func run() (err error, showUsage bool) {
 	}
 
 	if *testnetFlag {
-		chainParams = &chaincfg.TestNet4Params
+		chainParams = &litecoinTestNet4Params
 	}
 
 	var cmd command
 	switch args[0] {
 	case "initiate":
-		cp2Addr, err := ltcutil.DecodeAddress(args[1], chainParams)
+		cp2Addr, err := btcutil.DecodeAddress(args[1], chainParams)
 		if err != nil {
 			return fmt.Errorf("failed to decode participant address: %v", err), true
 		}
@@ -201,16 +199,17 @@ func run() (err error, showUsage bool) {
 			return fmt.Errorf("participant address is not "+
 				"intended for use on %v", chainParams.Name), true
 		}
-		cp2AddrP2PKH, ok := cp2Addr.(*ltcutil.AddressPubKeyHash)
-		if !ok {
-			return errors.New("participant address is not P2PKH"), true
+		// Contracts pay to the key hash of P2PKH and P2WPKH addresses.
+		cp2AddrP2PKH, err := atomicswap.KeyHashAddress(cp2Addr, chainParams)
+		if err != nil {
+			return fmt.Errorf("participant address: %v", err), true
 		}
 
 		amountF64, err := strconv.ParseFloat(args[2], 64)
 		if err != nil {
 			return fmt.Errorf("failed to decode amount: %v", err), true
 		}
-		amount, err := ltcutil.NewAmount(amountF64)
+		amount, err := btcutil.NewAmount(amountF64)
 		if err != nil {
 			return err, true
 		}
@@ -218,7 +217,7 @@ func run() (err error, showUsage bool) {
 		cmd = &initiateCmd{cp2Addr: cp2AddrP2PKH, amount: amount}
 
 	case "participate":
-		cp1Addr, err := ltcutil.DecodeAddress(args[1], chainParams)
+		cp1Addr, err := btcutil.DecodeAddress(args[1], chainParams)
 		if err != nil {
 			return fmt.Errorf("failed to decode initiator address: %v", err), true
 		}
@@ -226,16 +225,17 @@ func run() (err error, showUsage bool) {
 			return fmt.Errorf("initiator address is not "+
 				"intended for use on %v", chainParams.Name), true
 		}
-		cp1AddrP2PKH, ok := cp1Addr.(*ltcutil.AddressPubKeyHash)
-		if !ok {
-			return errors.New("initiator address is not P2PKH"), true
+		// Contracts pay to the key hash of P2PKH and P2WPKH addresses.
+		cp1AddrP2PKH, err := atomicswap.KeyHashAddress(cp1Addr, chainParams)
+		if err != nil {
+			return fmt.Errorf("initiator address: %v", err), true
 		}
 
 		amountF64, err := strconv.ParseFloat(args[2], 64)
 		if err != nil {
 			return fmt.Errorf("failed to decode amount: %v", err), true
 		}
-		amount, err := ltcutil.NewAmount(amountF64)
+		amount, err := btcutil.NewAmount(amountF64)
 		if err != nil {
 			return err, true
 		}
@@ -357,7 +357,7 @@ func run() (err error, showUsage bool) {
 		client.WaitForShutdown()
 	}()
 
-	err = cmd.runCommand(client)
+	err = cmd.runCommand(&wallet{client})
 	return err, false
 }
 
@@ -376,23 +376,27 @@ func normalizeAddress(addr string, defaultPort string) (hostport string, err err
 
 func walletPort(params *chaincfg.Params) string {
 	switch params {
-	case &chaincfg.MainNetParams:
+	case &litecoinMainNetParams:
 		return "9332"
-	case &chaincfg.TestNet4Params:
+	case &litecoinTestNet4Params:
 		return "19332"
 	default:
 		return ""
 	}
 }
 
-// createSig creates and returns the serialized raw signature and compressed
+// wallet is the Litecoin Core wallet the swap transactions are funded and
+// signed with, used through its JSON-RPC interface.
+type wallet struct {
+	c *rpc.Client
+}
+
+// CreateSig creates and returns the serialized raw signature and compressed
 // pubkey for a transaction input signature.  Due to limitations of the Litecoin
 // Core RPC API, this requires dumping a private key and signing in the client,
 // rather than letting the wallet sign.
-func createSig(tx *wire.MsgTx, idx int, pkScript []byte, addr ltcutil.Address,
-	c *rpc.Client) (sig, pubkey []byte, err error) {
-
-	wif, err := c.DumpPrivKey(addr)
+func (w *wallet) CreateSig(tx *wire.MsgTx, idx int, pkScript []byte, addr btcutil.Address) (sig, pubkey []byte, err error) {
+	wif, err := w.c.DumpPrivKey(addr)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -403,10 +407,53 @@ func createSig(tx *wire.MsgTx, idx int, pkScript []byte, addr ltcutil.Address,
 	return sig, wif.PrivKey.PubKey().SerializeCompressed(), nil
 }
 
+// CreateWitnessSig creates and returns the serialized BIP143 signature and
+// compressed pubkey for the input of a P2WSH contract output.  Like CreateSig
+// it signs in the client with a dumped private key.
+func (w *wallet) CreateWitnessSig(tx *wire.MsgTx, idx int, script []byte, amount int64,
+	addr btcutil.Address) (sig, pubkey []byte, err error) {
+
+	wif, err := w.c.DumpPrivKey(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	sig, err = txscript.RawTxInWitnessSignature(tx, txscript.NewTxSigHashes(tx), idx, amount,
+		script, txscript.SigHashAll, wif.PrivKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sig, wif.PrivKey.PubKey().SerializeCompressed(), nil
+}
+
+// PayTo funds a transaction paying the amounts with fundrawtransaction and
+// signs it with the wallet.
+func (w *wallet) PayTo(amounts map[btcutil.Address]btcutil.Amount, feePerKb btcutil.Amount) (*wire.MsgTx, btcutil.Amount, error) {
+	unsignedTx := wire.NewMsgTx(atomicswap.TxVersion)
+	for addr, amount := range amounts {
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, 0, err
+		}
+		unsignedTx.AddTxOut(wire.NewTxOut(int64(amount), pkScript))
+	}
+	unsignedTx, fee, err := w.fundRawTransaction(unsignedTx, feePerKb)
+	if err != nil {
+		return nil, 0, fmt.Errorf("fundrawtransaction: %v", err)
+	}
+	signedTx, complete, err := w.c.SignRawTransaction(unsignedTx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("signrawtransaction: %v", err)
+	}
+	if !complete {
+		return nil, 0, errors.New("signrawtransaction: failed to completely sign transaction")
+	}
+	return signedTx, fee, nil
+}
+
 // fundRawTransaction calls the fundrawtransaction JSON-RPC method.  It is
 // implemented manually as client support is currently missing from the
-// ltcd/rpcclient package.
-func fundRawTransaction(c *rpc.Client, tx *wire.MsgTx, feePerKb ltcutil.Amount) (fundedTx *wire.MsgTx, fee ltcutil.Amount, err error) {
+// btcd/rpcclient package.
+func (w *wallet) fundRawTransaction(tx *wire.MsgTx, feePerKb btcutil.Amount) (fundedTx *wire.MsgTx, fee btcutil.Amount, err error) {
 	var buf bytes.Buffer
 	buf.Grow(tx.SerializeSize())
 	tx.Serialize(&buf)
@@ -423,7 +470,7 @@ func fundRawTransaction(c *rpc.Client, tx *wire.MsgTx, feePerKb ltcutil.Amount)
 		return nil, 0, err
 	}
 	params := []json.RawMessage{param0, param1}
-	rawResp, err := c.RawRequest("fundrawtransaction", params)
+	rawResp, err := w.c.RawRequest("fundrawtransaction", params)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -445,7 +492,7 @@ func fundRawTransaction(c *rpc.Client, tx *wire.MsgTx, feePerKb ltcutil.Amount)
 	if err != nil {
 		return nil, 0, err
 	}
-	feeAmount, err := ltcutil.NewAmount(resp.Fee)
+	feeAmount, err := btcutil.NewAmount(resp.Fee)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -453,10 +500,13 @@ func fundRawTransaction(c *rpc.Client, tx *wire.MsgTx, feePerKb ltcutil.Amount)
 }
 
 // getFeePerKb queries the wallet for the transaction relay fee/kB to use and
-// the minimum mempool relay fee.  It first tries to get the user-set fee in the
-// wallet.  If unset, it attempts to find an estimate using estimatefee 6.  If
-// both of these fail, it falls back to mempool relay fee policy.
-func getFeePerKb(c *rpc.Client) (useFee, relayFee ltcutil.Amount, err error) {
+// the minimum mempool relay fee.  An explicit fee rate from the fee policy
+// takes precedence.  Otherwise it first tries to get the user-set fee in the
+// wallet.  If unset, it attempts to find an estimate using estimatesmartfee for
+// the configured confirmation target.  If both of these fail, it falls back to
+// mempool relay fee policy.  The relay fee is never below the minimum relay
+// fee rate of the fee policy.
+func (w *wallet) getFeePerKb() (useFee, relayFee btcutil.Amount, err error) {
 	var netInfoResp struct {
 		RelayFee float64 `json:"relayfee"`
 	}
@@ -467,14 +517,14 @@ func getFeePerKb(c *rpc.Client) (useFee, relayFee ltcutil.Amount, err error) {
 		FeeRate float64 `json:"feerate"`
 	}
 
-	netInfoRawResp, err := c.RawRequest("getnetworkinfo", nil)
+	netInfoRawResp, err := w.c.RawRequest("getnetworkinfo", nil)
 	if err == nil {
 		err = json.Unmarshal(netInfoRawResp, &netInfoResp)
 		if err != nil {
 			return 0, 0, err
 		}
 	}
-	walletInfoRawResp, err := c.RawRequest("getwalletinfo", nil)
+	walletInfoRawResp, err := w.c.RawRequest("getwalletinfo", nil)
 	if err == nil {
 		err = json.Unmarshal(walletInfoRawResp, &walletInfoResp)
 		if err != nil {
@@ -482,11 +532,21 @@ func getFeePerKb(c *rpc.Client) (useFee, relayFee ltcutil.Amount, err error) {
 		}
 	}
 
-	relayFee, err = ltcutil.NewAmount(netInfoResp.RelayFee)
+	relayFee, err = btcutil.NewAmount(netInfoResp.RelayFee)
 	if err != nil {
 		return 0, 0, err
 	}
-	payTxFee, err := ltcutil.NewAmount(walletInfoResp.PayTxFee)
+	if minFee := btcutil.Amount(feePolicy.MinFeePerKb()); relayFee < minFee {
+		relayFee = minFee
+	}
+	if explicitFee, ok := feePolicy.ExplicitFeePerKb(); ok {
+		useFee = btcutil.Amount(explicitFee)
+		if relayFee > useFee {
+			useFee = relayFee
+		}
+		return useFee, relayFee, nil
+	}
+	payTxFee, err := btcutil.NewAmount(walletInfoResp.PayTxFee)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -501,15 +561,19 @@ func getFeePerKb(c *rpc.Client) (useFee, relayFee ltcutil.Amount, err error) {
 		return maxFee, relayFee, nil
 	}
 
-	params := []json.RawMessage{[]byte("6")}
-	estimateRawResp, err := c.RawRequest("estimatesmartfee", params)
+	confTarget := feePolicy.ConfTarget
+	if confTarget <= 0 {
+		confTarget = feepolicy.DefaultConfTarget
+	}
+	params := []json.RawMessage{[]byte(strconv.Itoa(confTarget))}
+	estimateRawResp, err := w.c.RawRequest("estimatesmartfee", params)
 	if err != nil {
 		return 0, 0, err
 	}
 
 	err = json.Unmarshal(estimateRawResp, &estimateResp)
 	if err == nil && estimateResp.FeeRate > 0 {
-		useFee, err = ltcutil.NewAmount(estimateResp.FeeRate)
+		useFee, err = btcutil.NewAmount(estimateResp.FeeRate)
 		if relayFee > useFee {
 			useFee = relayFee
 		}
@@ -520,12 +584,25 @@ func getFeePerKb(c *rpc.Client) (useFee, relayFee ltcutil.Amount, err error) {
 	return relayFee, relayFee, nil
 }
 
-// getRawChangeAddress calls the getrawchangeaddress JSON-RPC method.  It is
-// implemented manually as the rpcclient implementation always passes the
-// account parameter which was removed in Litecoin Core 0.15.
-func getRawChangeAddress(c *rpc.Client) (ltcutil.Address, error) {
-	params := []json.RawMessage{[]byte(`"legacy"`)}
-	rawResp, err := c.RawRequest("getrawchangeaddress", params)
+// NewAddress calls the getrawchangeaddress JSON-RPC method for a bech32
+// address, or a legacy address with -legacy.  It is implemented manually as
+// the rpcclient implementation always passes the account parameter which was
+// removed in Litecoin Core 0.15.  Litecoin Core before 0.17 takes no address
+// type and only hands out legacy addresses, so a legacy address is requested
+// without one when the address type is rejected.
+func (w *wallet) NewAddress() (btcutil.Address, error) {
+	params := []json.RawMessage{[]byte(`"bech32"`)}
+	if *legacyFlag {
+		params = []json.RawMessage{[]byte(`"legacy"`)}
+	}
+	rawResp, err := w.c.RawRequest("getrawchangeaddress", params)
+	if err != nil && *legacyFlag {
+		var noTypeErr error
+		rawResp, noTypeErr = w.c.RawRequest("getrawchangeaddress", nil)
+		if noTypeErr == nil {
+			err = nil
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -534,7 +611,7 @@ func getRawChangeAddress(c *rpc.Client) (ltcutil.Address, error) {
 	if err != nil {
 		return nil, err
 	}
-	addr, err := ltcutil.DecodeAddress(addrStr, chainParams)
+	addr, err := btcutil.DecodeAddress(addrStr, chainParams)
 	if err != nil {
 		return nil, err
 	}
@@ -542,14 +619,19 @@ func getRawChangeAddress(c *rpc.Client) (ltcutil.Address, error) {
 		return nil, fmt.Errorf("address %v is not intended for use on %v",
 			addrStr, chainParams.Name)
 	}
-	if _, ok := addr.(*ltcutil.AddressPubKeyHash); !ok {
-		return nil, fmt.Errorf("getrawchangeaddress: address %v is not P2PKH",
+	if _, ok := addr.(*btcutil.AddressPubKeyHash); !ok && *legacyFlag {
+		return nil, fmt.Errorf("getrawchangeaddress: address %v is not P2PKH", addr)
+	}
+	switch addr.(type) {
+	case *btcutil.AddressPubKeyHash, *btcutil.AddressWitnessPubKeyHash:
+	default:
+		return nil, fmt.Errorf("getrawchangeaddress: address %v is not P2PKH or P2WPKH",
 			addr)
 	}
 	return addr, nil
 }
 
-func promptPublishTx(c *rpc.Client, tx *wire.MsgTx, name string) error {
+func promptPublishTx(w *wallet, tx *wire.MsgTx, name string) error {
 	reader := bufio.NewReader(os.Stdin)
 	for {
 		fmt.Printf("Publish %s transaction? [y/N] ", name)
@@ -568,7 +650,7 @@ func promptPublishTx(c *rpc.Client, tx *wire.MsgTx, name string) error {
 			continue
 		}
 
-		txHash, err := c.SendRawTransaction(tx, false)
+		txHash, err := w.c.SendRawTransaction(tx, false)
 		if err != nil {
 			return fmt.Errorf("sendrawtransaction: %v", err)
 		}
@@ -577,175 +659,33 @@ func promptPublishTx(c *rpc.Client, tx *wire.MsgTx, name string) error {
 	}
 }
 
-// contractArgs specifies the common parameters used to create the initiator's
-// and participant's contract.
-type contractArgs struct {
-	them       *ltcutil.AddressPubKeyHash
-	amount     ltcutil.Amount
-	locktime   int64
-	secretHash []byte
-}
-
-// builtContract houses the details regarding a contract and the contract
-// payment transaction, as well as the transaction to perform a refund.
-type builtContract struct {
-	contract       []byte
-	contractP2SH   ltcutil.Address
-	contractTxHash *chainhash.Hash
-	contractTx     *wire.MsgTx
-	contractFee    ltcutil.Amount
-	refundTx       *wire.MsgTx
-	refundFee      ltcutil.Amount
+// swapBuilder returns the builder of the swap transactions, which pays
+// contracts to P2WSH outputs unless -legacy is set, checks outputs for dust
+// against the relay fee and applies the fee ceilings of the fee policy.
+func swapBuilder(relayFeePerKb btcutil.Amount) *atomicswap.Builder {
+	return &atomicswap.Builder{
+		Params:        chainParams,
+		RelayFeePerKb: relayFeePerKb,
+		CheckFee:      feePolicy.CheckFee,
+		SegWit:        !*legacyFlag,
+	}
 }
 
 // buildContract creates a contract for the parameters specified in args, using
 // wallet RPC to generate an internal address to redeem the refund and to sign
 // the payment to the contract transaction.
-func buildContract(c *rpc.Client, args *contractArgs) (*builtContract, error) {
-	refundAddr, err := getRawChangeAddress(c)
-	if err != nil {
-		return nil, fmt.Errorf("getrawchangeaddress: %v", err)
-	}
-	refundAddrH, ok := refundAddr.(interface {
-		Hash160() *[ripemd160.Size]byte
-	})
-	if !ok {
-		return nil, errors.New("unable to create hash160 from change address")
-	}
-
-	contract, err := atomicSwapContract(refundAddrH.Hash160(), args.them.Hash160(),
-		args.locktime, args.secretHash)
-	if err != nil {
-		return nil, err
-	}
-	contractP2SH, err := ltcutil.NewAddressScriptHash(contract, chainParams)
-	if err != nil {
-		return nil, err
-	}
-	contractP2SHPkScript, err := txscript.PayToAddrScript(contractP2SH)
-	if err != nil {
-		return nil, err
-	}
-
-	feePerKb, minFeePerKb, err := getFeePerKb(c)
-	if err != nil {
-		return nil, err
-	}
-
-	unsignedContract := wire.NewMsgTx(txVersion)
-	unsignedContract.AddTxOut(wire.NewTxOut(int64(args.amount), contractP2SHPkScript))
-	unsignedContract, contractFee, err := fundRawTransaction(c, unsignedContract, feePerKb)
-	if err != nil {
-		return nil, fmt.Errorf("fundrawtransaction: %v", err)
-	}
-	contractTx, complete, err := c.SignRawTransaction(unsignedContract)
-	if err != nil {
-		return nil, fmt.Errorf("signrawtransaction: %v", err)
-	}
-	if !complete {
-		return nil, errors.New("signrawtransaction: failed to completely sign contract transaction")
-	}
-
-	contractTxHash := contractTx.TxHash()
-
-	refundTx, refundFee, err := buildRefund(c, contract, contractTx, feePerKb, minFeePerKb)
+func buildContract(w *wallet, args *atomicswap.ContractArgs) (*atomicswap.BuiltContract, error) {
+	feePerKb, minFeePerKb, err := w.getFeePerKb()
 	if err != nil {
 		return nil, err
 	}
-
-	return &builtContract{
-		contract,
-		contractP2SH,
-		&contractTxHash,
-		contractTx,
-		contractFee,
-		refundTx,
-		refundFee,
-	}, nil
+	return swapBuilder(minFeePerKb).BuildContract(w, args, feePerKb)
 }
 
-func buildRefund(c *rpc.Client, contract []byte, contractTx *wire.MsgTx, feePerKb, minFeePerKb ltcutil.Amount) (
-	refundTx *wire.MsgTx, refundFee ltcutil.Amount, err error) {
-
-	contractP2SH, err := ltcutil.NewAddressScriptHash(contract, chainParams)
-	if err != nil {
-		return nil, 0, err
-	}
-	contractP2SHPkScript, err := txscript.PayToAddrScript(contractP2SH)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	contractTxHash := contractTx.TxHash()
-	contractOutPoint := wire.OutPoint{Hash: contractTxHash, Index: ^uint32(0)}
-	for i, o := range contractTx.TxOut {
-		if bytes.Equal(o.PkScript, contractP2SHPkScript) {
-			contractOutPoint.Index = uint32(i)
-			break
-		}
-	}
-	if contractOutPoint.Index == ^uint32(0) {
-		return nil, 0, errors.New("contract tx does not contain a P2SH contract payment")
-	}
-
-	refundAddress, err := getRawChangeAddress(c)
-	if err != nil {
-		return nil, 0, fmt.Errorf("getrawchangeaddress: %v", err)
-	}
-	refundOutScript, err := txscript.PayToAddrScript(refundAddress)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	pushes, err := txscript.ExtractAtomicSwapDataPushes(0, contract)
-	if err != nil {
-		// expected to only be called with good input
-		panic(err)
-	}
-
-	refundAddr, err := ltcutil.NewAddressPubKeyHash(pushes.RefundHash160[:], chainParams)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	refundTx = wire.NewMsgTx(txVersion)
-	refundTx.LockTime = uint32(pushes.LockTime)
-	refundTx.AddTxOut(wire.NewTxOut(0, refundOutScript)) // amount set below
-	refundSize := estimateRefundSerializeSize(contract, refundTx.TxOut)
-	refundFee = txrules.FeeForSerializeSize(feePerKb, refundSize)
-	refundTx.TxOut[0].Value = contractTx.TxOut[contractOutPoint.Index].Value - int64(refundFee)
-	if txrules.IsDustOutput(refundTx.TxOut[0], minFeePerKb) {
-		return nil, 0, fmt.Errorf("refund output value of %v is dust", ltcutil.Amount(refundTx.TxOut[0].Value))
-	}
-
-	txIn := wire.NewTxIn(&contractOutPoint, nil, nil)
-	txIn.Sequence = 0
-	refundTx.AddTxIn(txIn)
-
-	refundSig, refundPubKey, err := createSig(refundTx, 0, contract, refundAddr, c)
-	if err != nil {
-		return nil, 0, err
-	}
-	refundSigScript, err := refundP2SHContract(contract, refundSig, refundPubKey)
-	if err != nil {
-		return nil, 0, err
-	}
-	refundTx.TxIn[0].SignatureScript = refundSigScript
-
-	if verify {
-		e, err := txscript.NewEngine(contractTx.TxOut[contractOutPoint.Index].PkScript,
-			refundTx, 0, txscript.StandardVerifyFlags, txscript.NewSigCache(10),
-			txscript.NewTxSigHashes(refundTx), contractTx.TxOut[contractOutPoint.Index].Value)
-		if err != nil {
-			panic(err)
-		}
-		err = e.Execute()
-		if err != nil {
-			panic(err)
-		}
-	}
-
-	return refundTx, refundFee, nil
+// formatAmount formats an amount in LTC, as btcutil.Amount is always
+// formatted in BTC.
+func formatAmount(amount btcutil.Amount) string {
+	return strconv.FormatFloat(amount.ToBTC(), 'f', -1, 64) + " LTC"
 }
 
 func sha256Hash(x []byte) []byte {
@@ -753,12 +693,14 @@ func sha256Hash(x []byte) []byte {
 	return h[:]
 }
 
-func calcFeePerKb(absoluteFee ltcutil.Amount, serializeSize int) float64 {
-	return float64(absoluteFee) / float64(serializeSize) / 1e5
+// calcFeePerKb returns the fee rate of a transaction in LTC per kilobyte of
+// its virtual size, which is its weight divided by four.
+func calcFeePerKb(absoluteFee btcutil.Amount, tx *wire.MsgTx) float64 {
+	return float64(absoluteFee) / float64(atomicswap.VirtualSize(tx)) / 1e5
 }
 
-func (cmd *initiateCmd) runCommand(c *rpc.Client) error {
-	var secret [secretSize]byte
+func (cmd *initiateCmd) runCommand(w *wallet) error {
+	var secret [atomicswap.SecretSize]byte
 	_, err := rand.Read(secret[:])
 	if err != nil {
 		return err
@@ -769,186 +711,108 @@ func (cmd *initiateCmd) runCommand(c *rpc.Client) error {
 	// as a unix time rather than a block height.
 	locktime := time.Now().Add(48 * time.Hour).Unix()
 
-	b, err := buildContract(c, &contractArgs{
-		them:       cmd.cp2Addr,
-		amount:     cmd.amount,
-		locktime:   locktime,
-		secretHash: secretHash,
+	b, err := buildContract(w, &atomicswap.ContractArgs{
+		Them:       cmd.cp2Addr,
+		Amount:     cmd.amount,
+		LockTime:   locktime,
+		SecretHash: secretHash,
 	})
 	if err != nil {
 		return err
 	}
 
-	refundTxHash := b.refundTx.TxHash()
-	contractFeePerKb := calcFeePerKb(b.contractFee, b.contractTx.SerializeSize())
-	refundFeePerKb := calcFeePerKb(b.refundFee, b.refundTx.SerializeSize())
-
 	fmt.Printf("Secret:      %x\n", secret)
 	fmt.Printf("Secret hash: %x\n\n", secretHash)
-	fmt.Printf("Contract fee: %v (%0.8f LTC/kB)\n", b.contractFee, contractFeePerKb)
-	fmt.Printf("Refund fee:   %v (%0.8f LTC/kB)\n\n", b.refundFee, refundFeePerKb)
-	fmt.Printf("Contract (%v):\n", b.contractP2SH)
-	fmt.Printf("%x\n\n", b.contract)
-	var contractBuf bytes.Buffer
-	contractBuf.Grow(b.contractTx.SerializeSize())
-	b.contractTx.Serialize(&contractBuf)
-	fmt.Printf("Contract transaction (%v):\n", b.contractTxHash)
-	fmt.Printf("%x\n\n", contractBuf.Bytes())
-	var refundBuf bytes.Buffer
-	refundBuf.Grow(b.refundTx.SerializeSize())
-	b.refundTx.Serialize(&refundBuf)
-	fmt.Printf("Refund transaction (%v):\n", &refundTxHash)
-	fmt.Printf("%x\n\n", refundBuf.Bytes())
+	printBuiltContract(b)
 
-	return promptPublishTx(c, b.contractTx, "contract")
+	return promptPublishTx(w, b.ContractTx, "contract")
 }
 
-func (cmd *participateCmd) runCommand(c *rpc.Client) error {
+func (cmd *participateCmd) runCommand(w *wallet) error {
 	// locktime after 500,000,000 (Tue Nov  5 00:53:20 1985 UTC) is interpreted
 	// as a unix time rather than a block height.
 	locktime := time.Now().Add(24 * time.Hour).Unix()
 
-	b, err := buildContract(c, &contractArgs{
-		them:       cmd.cp1Addr,
-		amount:     cmd.amount,
-		locktime:   locktime,
-		secretHash: cmd.secretHash,
+	b, err := buildContract(w, &atomicswap.ContractArgs{
+		Them:       cmd.cp1Addr,
+		Amount:     cmd.amount,
+		LockTime:   locktime,
+		SecretHash: cmd.secretHash,
 	})
 	if err != nil {
 		return err
 	}
 
-	refundTxHash := b.refundTx.TxHash()
-	contractFeePerKb := calcFeePerKb(b.contractFee, b.contractTx.SerializeSize())
-	refundFeePerKb := calcFeePerKb(b.refundFee, b.refundTx.SerializeSize())
+	printBuiltContract(b)
+
+	return promptPublishTx(w, b.ContractTx, "contract")
+}
+
+// printBuiltContract prints the fees, the contract and the contract and refund
+// transactions of a new contract.
+func printBuiltContract(b *atomicswap.BuiltContract) {
+	refundTxHash := b.RefundTx.TxHash()
+	contractFeePerKb := calcFeePerKb(b.ContractFee, b.ContractTx)
+	refundFeePerKb := calcFeePerKb(b.RefundFee, b.RefundTx)
 
-	fmt.Printf("Contract fee: %v (%0.8f LTC/kB)\n", b.contractFee, contractFeePerKb)
-	fmt.Printf("Refund fee:   %v (%0.8f LTC/kB)\n\n", b.refundFee, refundFeePerKb)
-	fmt.Printf("Contract (%v):\n", b.contractP2SH)
-	fmt.Printf("%x\n\n", b.contract)
+	fmt.Printf("Contract fee: %v (%0.8f LTC/kB)\n", formatAmount(b.ContractFee), contractFeePerKb)
+	fmt.Printf("Refund fee:   %v (%0.8f LTC/kB)\n\n", formatAmount(b.RefundFee), refundFeePerKb)
+	fmt.Printf("Contract (%v):\n", b.ContractAddress)
+	fmt.Printf("%x\n\n", b.Contract.Script)
 	var contractBuf bytes.Buffer
-	contractBuf.Grow(b.contractTx.SerializeSize())
-	b.contractTx.Serialize(&contractBuf)
-	fmt.Printf("Contract transaction (%v):\n", b.contractTxHash)
+	contractBuf.Grow(b.ContractTx.SerializeSize())
+	b.ContractTx.Serialize(&contractBuf)
+	fmt.Printf("Contract transaction (%v):\n", &b.ContractTxHash)
 	fmt.Printf("%x\n\n", contractBuf.Bytes())
 	var refundBuf bytes.Buffer
-	refundBuf.Grow(b.refundTx.SerializeSize())
-	b.refundTx.Serialize(&refundBuf)
+	refundBuf.Grow(b.RefundTx.SerializeSize())
+	b.RefundTx.Serialize(&refundBuf)
 	fmt.Printf("Refund transaction (%v):\n", &refundTxHash)
 	fmt.Printf("%x\n\n", refundBuf.Bytes())
-
-	return promptPublishTx(c, b.contractTx, "contract")
 }
 
-func (cmd *redeemCmd) runCommand(c *rpc.Client) error {
-	pushes, err := txscript.ExtractAtomicSwapDataPushes(0, cmd.contract)
-	if err != nil {
-		return err
-	}
-	if pushes == nil {
-		return errors.New("contract is not an atomic swap script recognized by this tool")
-	}
-	recipientAddr, err := ltcutil.NewAddressPubKeyHash(pushes.RecipientHash160[:],
-		chainParams)
+func (cmd *redeemCmd) runCommand(w *wallet) error {
+	contract, err := atomicswap.ParseContract(cmd.contract)
 	if err != nil {
 		return err
 	}
-	contractHash := ltcutil.Hash160(cmd.contract)
-	contractOut := -1
-	for i, out := range cmd.contractTx.TxOut {
-		sc, addrs, _, _ := txscript.ExtractPkScriptAddrs(out.PkScript, chainParams)
-		if sc == txscript.ScriptHashTy &&
-			bytes.Equal(addrs[0].(*ltcutil.AddressScriptHash).Hash160()[:], contractHash) {
-			contractOut = i
-			break
-		}
-	}
-	if contractOut == -1 {
-		return errors.New("transaction does not contain a contract output")
-	}
 
-	addr, err := getRawChangeAddress(c)
-	if err != nil {
-		return fmt.Errorf("getrawchangeaddres: %v", err)
-	}
-	outScript, err := txscript.PayToAddrScript(addr)
+	feePerKb, minFeePerKb, err := w.getFeePerKb()
 	if err != nil {
 		return err
 	}
 
-	contractTxHash := cmd.contractTx.TxHash()
-	contractOutPoint := wire.OutPoint{
-		Hash:  contractTxHash,
-		Index: uint32(contractOut),
-	}
-
-	feePerKb, minFeePerKb, err := getFeePerKb(c)
+	redeemTx, fee, err := swapBuilder(minFeePerKb).BuildRedeem(w, contract, cmd.contractTx,
+		cmd.secret, feePerKb)
 	if err != nil {
 		return err
 	}
 
-	redeemTx := wire.NewMsgTx(txVersion)
-	redeemTx.LockTime = uint32(pushes.LockTime)
-	redeemTx.AddTxIn(wire.NewTxIn(&contractOutPoint, nil, nil))
-	redeemTx.AddTxOut(wire.NewTxOut(0, outScript)) // amount set below
-	redeemSize := estimateRedeemSerializeSize(cmd.contract, redeemTx.TxOut)
-	fee := txrules.FeeForSerializeSize(feePerKb, redeemSize)
-	redeemTx.TxOut[0].Value = cmd.contractTx.TxOut[contractOut].Value - int64(fee)
-	if txrules.IsDustOutput(redeemTx.TxOut[0], minFeePerKb) {
-		return fmt.Errorf("redeem output value of %v is dust", ltcutil.Amount(redeemTx.TxOut[0].Value))
-	}
-
-	redeemSig, redeemPubKey, err := createSig(redeemTx, 0, cmd.contract, recipientAddr, c)
-	if err != nil {
-		return err
-	}
-	redeemSigScript, err := redeemP2SHContract(cmd.contract, redeemSig, redeemPubKey, cmd.secret)
-	if err != nil {
-		return err
-	}
-	redeemTx.TxIn[0].SignatureScript = redeemSigScript
-
 	redeemTxHash := redeemTx.TxHash()
-	redeemFeePerKb := calcFeePerKb(fee, redeemTx.SerializeSize())
+	redeemFeePerKb := calcFeePerKb(fee, redeemTx)
 
 	var buf bytes.Buffer
 	buf.Grow(redeemTx.SerializeSize())
 	redeemTx.Serialize(&buf)
-	fmt.Printf("Redeem fee: %v (%0.8f LTC/kB)\n\n", fee, redeemFeePerKb)
+	fmt.Printf("Redeem fee: %v (%0.8f LTC/kB)\n\n", formatAmount(fee), redeemFeePerKb)
 	fmt.Printf("Redeem transaction (%v):\n", &redeemTxHash)
 	fmt.Printf("%x\n\n", buf.Bytes())
 
-	if verify {
-		e, err := txscript.NewEngine(cmd.contractTx.TxOut[contractOutPoint.Index].PkScript,
-			redeemTx, 0, txscript.StandardVerifyFlags, txscript.NewSigCache(10),
-			txscript.NewTxSigHashes(redeemTx), cmd.contractTx.TxOut[contractOut].Value)
-		if err != nil {
-			panic(err)
-		}
-		err = e.Execute()
-		if err != nil {
-			panic(err)
-		}
-	}
-
-	return promptPublishTx(c, redeemTx, "redeem")
+	return promptPublishTx(w, redeemTx, "redeem")
 }
 
-func (cmd *refundCmd) runCommand(c *rpc.Client) error {
-	pushes, err := txscript.ExtractAtomicSwapDataPushes(0, cmd.contract)
+func (cmd *refundCmd) runCommand(w *wallet) error {
+	contract, err := atomicswap.ParseContract(cmd.contract)
 	if err != nil {
 		return err
 	}
-	if pushes == nil {
-		return errors.New("contract is not an atomic swap script recognized by this tool")
-	}
 
-	feePerKb, minFeePerKb, err := getFeePerKb(c)
+	feePerKb, minFeePerKb, err := w.getFeePerKb()
 	if err != nil {
 		return err
 	}
 
-	refundTx, refundFee, err := buildRefund(c, cmd.contract, cmd.contractTx, feePerKb, minFeePerKb)
+	refundTx, refundFee, err := swapBuilder(minFeePerKb).BuildRefund(w, contract, cmd.contractTx, feePerKb)
 	if err != nil {
 		return err
 	}
@@ -957,96 +821,47 @@ func (cmd *refundCmd) runCommand(c *rpc.Client) error {
 	buf.Grow(refundTx.SerializeSize())
 	refundTx.Serialize(&buf)
 
-	refundFeePerKb := calcFeePerKb(refundFee, refundTx.SerializeSize())
+	refundFeePerKb := calcFeePerKb(refundFee, refundTx)
 
-	fmt.Printf("Refund fee: %v (%0.8f LTC/kB)\n\n", refundFee, refundFeePerKb)
+	fmt.Printf("Refund fee: %v (%0.8f LTC/kB)\n\n", formatAmount(refundFee), refundFeePerKb)
 	fmt.Printf("Refund transaction (%v):\n", &refundTxHash)
 	fmt.Printf("%x\n\n", buf.Bytes())
 
-	return promptPublishTx(c, refundTx, "refund")
+	return promptPublishTx(w, refundTx, "refund")
 }
 
-func (cmd *extractSecretCmd) runCommand(c *rpc.Client) error {
+func (cmd *extractSecretCmd) runCommand(w *wallet) error {
 	return cmd.runOfflineCommand()
 }
 
 func (cmd *extractSecretCmd) runOfflineCommand() error {
-	// Loop over all pushed data from all inputs, searching for one that hashes
-	// to the expected hash.  By searching through all data pushes, we avoid any
-	// issues that could be caused by the initiator redeeming the participant's
-	// contract with some "nonstandard" or unrecognized transaction or script
-	// type.
-	for _, in := range cmd.redemptionTx.TxIn {
-		pushes, err := txscript.PushedData(in.SignatureScript)
-		if err != nil {
-			return err
-		}
-		for _, push := range pushes {
-			if bytes.Equal(sha256Hash(push), cmd.secretHash) {
-				fmt.Printf("Secret: %x\n", push)
-				return nil
-			}
-		}
+	secret, err := atomicswap.ExtractSecret(cmd.redemptionTx, cmd.secretHash)
+	if err != nil {
+		return err
 	}
-	return errors.New("transaction does not contain the secret")
+	fmt.Printf("Secret: %x\n", secret)
+	return nil
 }
 
-func (cmd *auditContractCmd) runCommand(c *rpc.Client) error {
+func (cmd *auditContractCmd) runCommand(w *wallet) error {
 	return cmd.runOfflineCommand()
 }
 
 func (cmd *auditContractCmd) runOfflineCommand() error {
-	contractHash160 := ltcutil.Hash160(cmd.contract)
-	contractOut := -1
-	for i, out := range cmd.contractTx.TxOut {
-		sc, addrs, _, err := txscript.ExtractPkScriptAddrs(out.PkScript, chainParams)
-		if err != nil || sc != txscript.ScriptHashTy {
-			continue
-		}
-		if bytes.Equal(addrs[0].(*ltcutil.AddressScriptHash).Hash160()[:], contractHash160) {
-			contractOut = i
-			break
-		}
-	}
-	if contractOut == -1 {
-		return errors.New("transaction does not contain the contract output")
-	}
-
-	pushes, err := txscript.ExtractAtomicSwapDataPushes(0, cmd.contract)
+	audit, err := atomicswap.AuditContract(chainParams, cmd.contract, cmd.contractTx)
 	if err != nil {
 		return err
 	}
-	if pushes == nil {
-		return errors.New("contract is not an atomic swap script recognized by this tool")
-	}
-	if pushes.SecretSize != secretSize {
-		return fmt.Errorf("contract specifies strange secret size %v", pushes.SecretSize)
-	}
 
-	contractAddr, err := ltcutil.NewAddressScriptHash(cmd.contract, chainParams)
-	if err != nil {
-		return err
-	}
-	recipientAddr, err := ltcutil.NewAddressPubKeyHash(pushes.RecipientHash160[:],
-		chainParams)
-	if err != nil {
-		return err
-	}
-	refundAddr, err := ltcutil.NewAddressPubKeyHash(pushes.RefundHash160[:],
-		chainParams)
-	if err != nil {
-		return err
-	}
-
-	fmt.Printf("Contract address:        %v\n", contractAddr)
-	fmt.Printf("Contract value:          %v\n", ltcutil.Amount(cmd.contractTx.TxOut[contractOut].Value))
-	fmt.Printf("Recipient address:       %v\n", recipientAddr)
-	fmt.Printf("Author's refund address: %v\n\n", refundAddr)
+	fmt.Printf("Contract address:        %v\n", audit.ContractAddress)
+	fmt.Printf("Contract value:          %v\n", formatAmount(audit.Value))
+	fmt.Printf("Recipient address:       %v\n", audit.RecipientAddress)
+	fmt.Printf("Author's refund address: %v\n\n", audit.RefundAddress)
 
-	fmt.Printf("Secret hash: %x\n\n", pushes.SecretHash[:])
+	fmt.Printf("Secret hash: %x\n\n", audit.Contract.SecretHash[:])
 
-	if pushes.LockTime >= int64(txscript.LockTimeThreshold) {
-		t := time.Unix(pushes.LockTime, 0)
+	if audit.Contract.LockTimeIsTime() {
+		t := time.Unix(audit.Contract.LockTime, 0)
 		fmt.Printf("Locktime: %v\n", t.UTC())
 		reachedAt := time.Until(t).Truncate(time.Second)
 		if reachedAt > 0 {
@@ -1055,92 +870,8 @@ func (cmd *auditContractCmd) runOfflineCommand() error {
 			fmt.Printf("Contract refund time lock has expired\n")
 		}
 	} else {
-		fmt.Printf("Locktime: block %v\n", pushes.LockTime)
+		fmt.Printf("Locktime: block %v\n", audit.Contract.LockTime)
 	}
 
 	return nil
 }
-
-// atomicSwapContract returns an output script that may be redeemed by one of
-// two signature scripts:
-//
-//   <their sig> <their pubkey> <initiator secret> 1
-//
-//   <my sig> <my pubkey> 0
-//
-// The first signature script is the normal redemption path done by the other
-// party and requires the initiator's secret.  The second signature script is
-// the refund path performed by us, but the refund can only be performed after
-// locktime.
-func atomicSwapContract(pkhMe, pkhThem *[ripemd160.Size]byte, locktime int64, secretHash []byte) ([]byte, error) {
-	b := txscript.NewScriptBuilder()
-
-	b.AddOp(txscript.OP_IF) // Normal redeem path
-	{
-		// Require initiator's secret to be a known length that the redeeming
-		// party can audit.  This is used to prevent fraud attacks between two
-		// currencies that have different maximum data sizes.
-		b.AddOp(txscript.OP_SIZE)
-		b.AddInt64(secretSize)
-		b.AddOp(txscript.OP_EQUALVERIFY)
-
-		// Require initiator's secret to be known to redeem the output.
-		b.AddOp(txscript.OP_SHA256)
-		b.AddData(secretHash)
-		b.AddOp(txscript.OP_EQUALVERIFY)
-
-		// Verify their signature is being used to redeem the output.  This
-		// would normally end with OP_EQUALVERIFY OP_CHECKSIG but this has been
-		// moved outside of the branch to save a couple bytes.
-		b.AddOp(txscript.OP_DUP)
-		b.AddOp(txscript.OP_HASH160)
-		b.AddData(pkhThem[:])
-	}
-	b.AddOp(txscript.OP_ELSE) // Refund path
-	{
-		// Verify locktime and drop it off the stack (which is not done by
-		// CLTV).
-		b.AddInt64(locktime)
-		b.AddOp(txscript.OP_CHECKLOCKTIMEVERIFY)
-		b.AddOp(txscript.OP_DROP)
-
-		// Verify our signature is being used to redeem the output.  This would
-		// normally end with OP_EQUALVERIFY OP_CHECKSIG but this has been moved
-		// outside of the branch to save a couple bytes.
-		b.AddOp(txscript.OP_DUP)
-		b.AddOp(txscript.OP_HASH160)
-		b.AddData(pkhMe[:])
-	}
-	b.AddOp(txscript.OP_ENDIF)
-
-	// Complete the signature check.
-	b.AddOp(txscript.OP_EQUALVERIFY)
-	b.AddOp(txscript.OP_CHECKSIG)
-
-	return b.Script()
-}
-
-// redeemP2SHContract returns the signature script to redeem a contract output
-// using the redeemer's signature and the initiator's secret.  This function
-// assumes P2SH and appends the contract as the final data push.
-func redeemP2SHContract(contract, sig, pubkey, secret []byte) ([]byte, error) {
-	b := txscript.NewScriptBuilder()
-	b.AddData(sig)
-	b.AddData(pubkey)
-	b.AddData(secret)
-	b.AddInt64(1)
-	b.AddData(contract)
-	return b.Script()
-}
-
-// refundP2SHContract returns the signature script to refund a contract output
-// using the contract author's signature after the locktime has been reached.
-// This function assumes P2SH and appends the contract as the final data push.
-func refundP2SHContract(contract, sig, pubkey []byte) ([]byte, error) {
-	b := txscript.NewScriptBuilder()
-	b.AddData(sig)
-	b.AddData(pubkey)
-	b.AddInt64(0)
-	b.AddData(contract)
-	return b.Script()
-}
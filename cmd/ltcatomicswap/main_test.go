@@ -0,0 +1,319 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/btcjson"
+	rpc "github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"github.com/robvanmieghem/electrumatomicswap/atomicswap"
+)
+
+// newTestWallet returns a wallet using a Litecoin Core test server that
+// answers each request with the result or the error returned by respond for
+// its method and parameters.
+func newTestWallet(t *testing.T, respond func(method string, params []json.RawMessage) (interface{}, *btcjson.RPCError)) *wallet {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		result, rpcErr := respond(req.Method, req.Params)
+		resp, err := json.Marshal(struct {
+			Result interface{}       `json:"result"`
+			Error  *btcjson.RPCError `json:"error"`
+			ID     json.RawMessage   `json:"id"`
+		}{result, rpcErr, req.ID})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Write(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := rpc.New(&rpc.ConnConfig{
+		Host:         strings.TrimPrefix(srv.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		DisableTLS:   true,
+		HTTPPostMode: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Shutdown)
+	return &wallet{c}
+}
+
+// newTestKey returns a new compressed private key of the network in use.
+func newTestKey(t *testing.T) *btcutil.WIF {
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wif, err := btcutil.NewWIF(privKey, chainParams, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wif
+}
+
+// useLegacy sets -legacy to legacy and restores it when the test ends.
+func useLegacy(t *testing.T, legacy bool) {
+	old := *legacyFlag
+	*legacyFlag = legacy
+	t.Cleanup(func() { *legacyFlag = old })
+}
+
+func TestNewAddress(t *testing.T) {
+	hash := bytes.Repeat([]byte{0x01}, 20)
+	p2pkh, err := btcutil.NewAddressPubKeyHash(hash, chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2wpkh, err := btcutil.NewAddressWitnessPubKeyHash(hash, chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testnet, err := btcutil.NewAddressWitnessPubKeyHash(hash, &litecoinTestNet4Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		legacy bool
+		// noTypes rejects address types as Litecoin Core before 0.17.
+		noTypes bool
+		result  btcutil.Address
+		err     bool
+	}{
+		{name: "bech32", result: p2wpkh},
+		{name: "legacy", legacy: true, result: p2pkh},
+		{name: "legacy without address types", legacy: true, noTypes: true, result: p2pkh},
+		{name: "bech32 without address types", noTypes: true, result: p2pkh, err: true},
+		{name: "legacy given a bech32 address", legacy: true, result: p2wpkh, err: true},
+		{name: "address of another network", result: testnet, err: true},
+	}
+	for _, test := range tests {
+		useLegacy(t, test.legacy)
+		w := newTestWallet(t, func(method string, params []json.RawMessage) (interface{}, *btcjson.RPCError) {
+			if method != "getrawchangeaddress" {
+				return nil, btcjson.ErrRPCMethodNotFound
+			}
+			if test.noTypes && len(params) != 0 {
+				return nil, &btcjson.RPCError{Code: btcjson.ErrRPCMisc,
+					Message: "getrawchangeaddress\nReturns a new Litecoin address, for receiving change."}
+			}
+			return test.result.EncodeAddress(), nil
+		})
+
+		addr, err := w.NewAddress()
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: address %v was accepted", test.name, addr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if addr.EncodeAddress() != test.result.EncodeAddress() {
+			t.Errorf("%s: address is %v, want %v", test.name, addr, test.result)
+		}
+	}
+}
+
+// TestWitnessSpends checks that the P2WSH contract outputs are redeemed and
+// refunded with valid witnesses, and that the fees are paid for the worst
+// case virtual size estimates of the transactions.
+func TestWitnessSpends(t *testing.T) {
+	useLegacy(t, false)
+
+	recipientKey, refundKey, payoutKey := newTestKey(t), newTestKey(t), newTestKey(t)
+	keys := make(map[string]*btcutil.WIF)
+	addrs := make(map[*btcutil.WIF]*btcutil.AddressPubKeyHash)
+	for _, wif := range []*btcutil.WIF{recipientKey, refundKey} {
+		addr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(wif.SerializePubKey()), chainParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[addr.EncodeAddress()] = wif
+		addrs[wif] = addr
+	}
+	payoutAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		btcutil.Hash160(payoutKey.SerializePubKey()), chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payoutScript, err := txscript.PayToAddrScript(payoutAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := newTestWallet(t, func(method string, params []json.RawMessage) (interface{}, *btcjson.RPCError) {
+		switch method {
+		case "getrawchangeaddress":
+			return payoutAddr.EncodeAddress(), nil
+		case "dumpprivkey":
+			var addr string
+			err := json.Unmarshal(params[0], &addr)
+			if err != nil {
+				return nil, &btcjson.RPCError{Code: btcjson.ErrRPCInvalidParameter, Message: err.Error()}
+			}
+			wif, ok := keys[addr]
+			if !ok {
+				return nil, &btcjson.RPCError{Code: btcjson.ErrRPCWallet,
+					Message: "Private key for address " + addr + " is not known"}
+			}
+			return wif.String(), nil
+		}
+		return nil, btcjson.ErrRPCMethodNotFound
+	})
+
+	var secret [atomicswap.SecretSize]byte
+	_, err = rand.Read(secret[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockTime := time.Now().Add(-time.Hour).Unix()
+	contract, err := atomicswap.NewContract(addrs[refundKey].Hash160(), addrs[recipientKey].Hash160(),
+		lockTime, sha256Hash(secret[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contractAddr, err := contract.WitnessAddress(chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contractScript, err := txscript.PayToAddrScript(contractAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const contractValue = 1e8
+	contractTx := wire.NewMsgTx(atomicswap.TxVersion)
+	contractTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil, nil))
+	contractTx.AddTxOut(wire.NewTxOut(contractValue, []byte{txscript.OP_TRUE}))
+	contractTx.AddTxOut(wire.NewTxOut(contractValue, contractScript))
+
+	const feePerKb = 1e5
+	b := swapBuilder(1e4)
+	tests := []struct {
+		name     string
+		build    func() (*wire.MsgTx, btcutil.Amount, error)
+		estimate func(contract []byte, txOuts []*wire.TxOut) int
+		// witness is the number of witness items, the last of which
+		// is the contract.
+		witness int
+	}{
+		{
+			name: "redeem",
+			build: func() (*wire.MsgTx, btcutil.Amount, error) {
+				return b.BuildRedeem(w, contract, contractTx, secret[:], feePerKb)
+			},
+			estimate: atomicswap.EstimateRedeemWitnessVSize,
+			witness:  5,
+		},
+		{
+			name: "refund",
+			build: func() (*wire.MsgTx, btcutil.Amount, error) {
+				return b.BuildRefund(w, contract, contractTx, feePerKb)
+			},
+			estimate: atomicswap.EstimateRefundWitnessVSize,
+			witness:  4,
+		},
+	}
+	for _, test := range tests {
+		tx, fee, err := test.build()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(tx.TxIn) != 1 || len(tx.TxOut) != 1 {
+			t.Errorf("%s: transaction has %d inputs and %d outputs", test.name, len(tx.TxIn), len(tx.TxOut))
+			continue
+		}
+		txIn := tx.TxIn[0]
+		if txIn.PreviousOutPoint != (wire.OutPoint{Hash: contractTx.TxHash(), Index: 1}) {
+			t.Errorf("%s: transaction spends %v", test.name, txIn.PreviousOutPoint)
+		}
+		if len(txIn.SignatureScript) != 0 || len(txIn.Witness) != test.witness ||
+			!bytes.Equal(txIn.Witness[test.witness-1], contract.Script) {
+			t.Errorf("%s: input has signature script %x and witness %x", test.name,
+				txIn.SignatureScript, txIn.Witness)
+			continue
+		}
+		switch test.name {
+		case "redeem":
+			if !bytes.Equal(txIn.Witness[2], secret[:]) {
+				t.Errorf("redeem: witness reveals %x, want the secret %x", txIn.Witness[2], secret)
+			}
+		case "refund":
+			if tx.LockTime != uint32(lockTime) || txIn.Sequence != 0 {
+				t.Errorf("refund: locktime %d, sequence %d", tx.LockTime, txIn.Sequence)
+			}
+		}
+		vm, err := txscript.NewEngine(contractScript, tx, 0, txscript.StandardVerifyFlags, nil,
+			txscript.NewTxSigHashes(tx), contractValue)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		err = vm.Execute()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+
+		// The estimate is at most a byte above the virtual size, as the
+		// signature is at most two bytes shorter than in the worst case.
+		vsize := atomicswap.VirtualSize(tx)
+		if vsize <= tx.SerializeSizeStripped() || vsize >= tx.SerializeSize() {
+			t.Errorf("%s: virtual size %d, size %d without witnesses and %d with", test.name,
+				vsize, tx.SerializeSizeStripped(), tx.SerializeSize())
+		}
+		estimate := test.estimate(contract.Script, tx.TxOut)
+		if estimate < vsize || estimate > vsize+1 {
+			t.Errorf("%s: virtual size %d is estimated as %d", test.name, vsize, estimate)
+		}
+		if fee != txrules.FeeForSerializeSize(feePerKb, estimate) {
+			t.Errorf("%s: fee %v for an estimated virtual size of %d", test.name, fee, estimate)
+		}
+		if calcFeePerKb(fee, tx) < btcutil.Amount(feePerKb).ToBTC() {
+			t.Errorf("%s: fee rate %v LTC/kB is below %v", test.name, calcFeePerKb(fee, tx),
+				btcutil.Amount(feePerKb).ToBTC())
+		}
+		txOut := tx.TxOut[0]
+		if txOut.Value != contractValue-int64(fee) || !bytes.Equal(txOut.PkScript, payoutScript) {
+			t.Errorf("%s: transaction pays %d to %x", test.name, txOut.Value, txOut.PkScript)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// The Litecoin networks are described with btcd chain parameters so the swap
+// library can be used with them.  Only the parameters used for addresses and
+// keys are set.
+var (
+	litecoinMainNetParams = chaincfg.Params{
+		Name:        "mainnet",
+		Net:         wire.BitcoinNet(0xdbb6c0fb),
+		DefaultPort: "9333",
+
+		Bech32HRPSegwit: "ltc",
+
+		PubKeyHashAddrID: 0x30, // starts with L
+		ScriptHashAddrID: 0x32, // starts with M
+		PrivateKeyID:     0xb0, // starts with 6 (uncompressed) or T (compressed)
+
+		HDPrivateKeyID: [4]byte{0x01, 0x9d, 0x9c, 0xfe}, // starts with Ltpv
+		HDPublicKeyID:  [4]byte{0x01, 0x9d, 0xa4, 0x62}, // starts with Ltub
+		HDCoinType:     2,
+	}
+
+	litecoinTestNet4Params = chaincfg.Params{
+		Name:        "testnet4",
+		Net:         wire.BitcoinNet(0xf1c8d2fd),
+		DefaultPort: "19335",
+
+		Bech32HRPSegwit: "tltc",
+
+		PubKeyHashAddrID: 0x6f, // starts with m or n
+		ScriptHashAddrID: 0x3a, // starts with Q
+		PrivateKeyID:     0xef, // starts with 9 (uncompressed) or c (compressed)
+
+		HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x94}, // starts with tprv
+		HDPublicKeyID:  [4]byte{0x04, 0x35, 0x87, 0xcf}, // starts with tpub
+		HDCoinType:     1,
+	}
+)
+
+func init() {
+	// Registering the networks makes btcutil recognize their bech32
+	// addresses.
+	for _, params := range []*chaincfg.Params{&litecoinMainNetParams, &litecoinTestNet4Params} {
+		if err := chaincfg.Register(params); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package coindef loads coin definitions describing chains derived from
+// Bitcoin, so the atomic swap tools can be used with them without changing the
+// code.  A definition is a JSON file:
+//
+//	{
+//		"name": "Examplecoin",
+//		"symbol": "EXC",
+//		"sighash": "all",
+//		"addressformat": "base58",
+//		"scriptfeatures": ["cltv", "sha256", "segwit"],
+//		"minrelayfee": 1,
+//		"dustrelayfee": 3,
+//		"networks": {
+//			"mainnet": {
+//				"net": "0xe8c1b7dc",
+//				"pubkeyhashaddrid": 0,
+//				"scripthashaddrid": 5,
+//				"privatekeyid": 128,
+//				"bech32hrp": "exc",
+//				"hdprivatekeyid": "0488ade4",
+//				"hdpublickeyid": "0488b21e",
+//				"hdcointype": 0,
+//				"port": "8333",
+//				"rpcport": "8332",
+//				"electrumport": "50001",
+//				"electrumtlsport": "50002"
+//			},
+//			"testnet": { ... }
+//		}
+//	}
+//
+// Version bytes are decimal numbers, the network magic a hex or decimal string
+// and the HD key version bytes hex strings.  Fee rates are in satoshi per
+// virtual byte.  The network magics must differ from each other, and those of
+// segwit coins from the networks of Bitcoin, as they identify the chain
+// parameters registered for bech32 addresses.  Coins with cashaddr addresses
+// set the prefix of each network with "cashaddrprefix", and coins hashing like
+// Groestlcoin set "txhash" and "base58checksum".  The coins directory of the
+// repository holds the definitions of the supported coins.
+package coindef
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Signature hash variants.
+const (
+	// SigHashAll is the legacy Bitcoin signature hash with SIGHASH_ALL.
+	SigHashAll = "all"
+
+	// SigHashForkID is the BIP143 signature hash with
+	// SIGHASH_ALL|SIGHASH_FORKID of replay protected chains such as
+	// Bitcoin Cash.  It can not be combined with FeatureSegWit.
+	SigHashForkID = "forkid"
+)
+
+// Address formats.
+const (
+	// AddressBase58 is the base58check encoding of Bitcoin.
+	AddressBase58 = "base58"
+
+	// AddressCashAddr is the cashaddr encoding of Bitcoin Cash, with the
+	// cashaddr prefix of the network.  Private keys are encoded as on
+	// Bitcoin.
+	AddressCashAddr = "cashaddr"
+)
+
+// Transaction hashes.
+const (
+	// TxHashDoubleSHA256 is the double SHA256 of Bitcoin.
+	TxHashDoubleSHA256 = "sha256d"
+
+	// TxHashSHA256 is a single SHA256, used by Groestlcoin for the ids
+	// of transactions and the digests of their legacy signatures.
+	TxHashSHA256 = "sha256"
+)
+
+// Checksums of base58 encodings.
+const (
+	// ChecksumDoubleSHA256 is the double SHA256 checksum of Bitcoin.
+	ChecksumDoubleSHA256 = "sha256d"
+
+	// ChecksumGroestl is the double Groestl-512 checksum of Groestlcoin,
+	// used for base58 addresses and private keys.
+	ChecksumGroestl = "groestl"
+)
+
+// Script features.
+const (
+	// FeatureCLTV is OP_CHECKLOCKTIMEVERIFY (BIP65), used by the refund
+	// path of the contracts.
+	FeatureCLTV = "cltv"
+
+	// FeatureSHA256 is OP_SHA256, used to check the secret of the
+	// contracts.
+	FeatureSHA256 = "sha256"
+
+	// FeatureSegWit is segregated witness (BIP141).  Contracts of coins
+	// supporting it are paid to P2WSH outputs, and the bech32 HRP of a
+	// network is only used by them.
+	FeatureSegWit = "segwit"
+)
+
+// Network names of the networks selected by the tools.
+const (
+	MainNet = "mainnet"
+	TestNet = "testnet"
+)
+
+// Coin is a coin definition.
+type Coin struct {
+	// Name is the name of the coin.
+	Name string `json:"name"`
+
+	// Symbol is the ticker symbol amounts are displayed with.
+	Symbol string `json:"symbol"`
+
+	// SigHash is the signature hash variant of the coin, SigHashAll or
+	// SigHashForkID.  It defaults to SigHashAll.
+	SigHash string `json:"sighash"`
+
+	// AddressFormat is the encoding of the addresses of the coin,
+	// AddressBase58 or AddressCashAddr.  It defaults to AddressBase58.
+	AddressFormat string `json:"addressformat"`
+
+	// TxHash is the hash identifying the transactions of the coin and
+	// signed by legacy signatures, TxHashDoubleSHA256 or TxHashSHA256.
+	// It defaults to TxHashDoubleSHA256.
+	TxHash string `json:"txhash"`
+
+	// Base58Checksum is the checksum of base58 addresses and private keys,
+	// ChecksumDoubleSHA256 or ChecksumGroestl.  It defaults to
+	// ChecksumDoubleSHA256.
+	Base58Checksum string `json:"base58checksum"`
+
+	// ScriptFeatures lists the script features supported by the coin.
+	// FeatureCLTV and FeatureSHA256 are required.
+	ScriptFeatures []string `json:"scriptfeatures"`
+
+	// MinRelayFee is the minimum relay fee rate in satoshi per virtual
+	// byte.  Zero leaves the default of the fee policy.
+	MinRelayFee float64 `json:"minrelayfee"`
+
+	// DustRelayFee is the relay fee rate in satoshi per virtual byte
+	// outputs are checked against to not be dust.  As in txrules, an
+	// output is dust when spending it costs more than a third of its
+	// value at this rate, so it is a third of the dust relay fee of the
+	// node, such as 1 for the 3 of Bitcoin Core.  Zero uses the fee rate
+	// of the transaction.
+	DustRelayFee float64 `json:"dustrelayfee"`
+
+	// NoFeeEstimates is set for coins whose wallets do not estimate fees.
+	// The minimum relay fee rate is used unless a fee rate is configured.
+	NoFeeEstimates bool `json:"nofeeestimates"`
+
+	// ElectrumVersion is the Electrum version whose JSON-RPC interface is
+	// implemented by the Electrum fork of the coin, when the version
+	// numbers of the fork do not identify it, such as "3.0" for Electron
+	// Cash.  It is queried from the daemon when empty.
+	ElectrumVersion string `json:"electrumversion"`
+
+	// Networks maps the network names to their parameters.
+	Networks map[string]*Network `json:"networks"`
+}
+
+// Network describes one network of a coin.
+type Network struct {
+	Net              string `json:"net"`
+	PubKeyHashAddrID byte   `json:"pubkeyhashaddrid"`
+	ScriptHashAddrID byte   `json:"scripthashaddrid"`
+	PrivateKeyID     byte   `json:"privatekeyid"`
+	Bech32HRP        string `json:"bech32hrp"`
+	CashAddrPrefix   string `json:"cashaddrprefix"`
+	HDPrivateKeyID   string `json:"hdprivatekeyid"`
+	HDPublicKeyID    string `json:"hdpublickeyid"`
+	HDCoinType       uint32 `json:"hdcointype"`
+
+	// Port is the peer-to-peer port of the nodes of the network.
+	Port string `json:"port"`
+
+	// RPCPort is the default port of the wallet RPC server.
+	RPCPort string `json:"rpcport"`
+
+	// ElectrumPort and ElectrumTLSPort are the default ports of the
+	// Electrum servers of the network, without and with TLS.
+	ElectrumPort    string `json:"electrumport"`
+	ElectrumTLSPort string `json:"electrumtlsport"`
+
+	params *chaincfg.Params
+}
+
+// Load reads and validates the coin definition in the file at path.
+func Load(path string) (*Coin, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Parse decodes and validates the JSON coin definition b.
+func Parse(b []byte) (*Coin, error) {
+	var c Coin
+	err := json.Unmarshal(b, &c)
+	if err != nil {
+		return nil, err
+	}
+	err = c.init()
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// HasFeature returns whether the coin supports the script feature.
+func (c *Coin) HasFeature(feature string) bool {
+	for _, f := range c.ScriptFeatures {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// init validates the definition and creates the chain parameters of its
+// networks.
+func (c *Coin) init() error {
+	if c.Name == "" {
+		return errors.New("coin has no name")
+	}
+	if c.Symbol == "" {
+		return errors.New("coin has no symbol")
+	}
+	switch c.SigHash {
+	case "":
+		c.SigHash = SigHashAll
+	case SigHashAll, SigHashForkID:
+	default:
+		return fmt.Errorf("unsupported sighash variant %q", c.SigHash)
+	}
+	for _, f := range c.ScriptFeatures {
+		switch f {
+		case FeatureCLTV, FeatureSHA256, FeatureSegWit:
+		default:
+			return fmt.Errorf("unknown script feature %q", f)
+		}
+	}
+	// The contracts can not be used on chains missing these opcodes.
+	if !c.HasFeature(FeatureCLTV) {
+		return errors.New("coin does not support OP_CHECKLOCKTIMEVERIFY, required by the contracts")
+	}
+	if !c.HasFeature(FeatureSHA256) {
+		return errors.New("coin does not support OP_SHA256, required by the contracts")
+	}
+	if c.SigHash == SigHashForkID && c.HasFeature(FeatureSegWit) {
+		return errors.New("segwit is not supported with forkid signatures")
+	}
+	switch c.AddressFormat {
+	case "":
+		c.AddressFormat = AddressBase58
+	case AddressBase58, AddressCashAddr:
+	default:
+		return fmt.Errorf("unsupported address format %q", c.AddressFormat)
+	}
+	switch c.TxHash {
+	case "":
+		c.TxHash = TxHashDoubleSHA256
+	case TxHashDoubleSHA256, TxHashSHA256:
+	default:
+		return fmt.Errorf("unsupported transaction hash %q", c.TxHash)
+	}
+	if c.TxHash != TxHashDoubleSHA256 && (c.SigHash == SigHashForkID || c.HasFeature(FeatureSegWit)) {
+		return fmt.Errorf("%s transaction hashes are only supported with legacy signatures", c.TxHash)
+	}
+	switch c.Base58Checksum {
+	case "":
+		c.Base58Checksum = ChecksumDoubleSHA256
+	case ChecksumDoubleSHA256, ChecksumGroestl:
+	default:
+		return fmt.Errorf("unsupported base58 checksum %q", c.Base58Checksum)
+	}
+	if c.MinRelayFee < 0 || c.DustRelayFee < 0 {
+		return errors.New("negative fee rate")
+	}
+	if len(c.Networks) == 0 {
+		return errors.New("coin has no networks")
+	}
+	names := make([]string, 0, len(c.Networks))
+	for name := range c.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	nets := make(map[wire.BitcoinNet]string, len(names))
+	for _, name := range names {
+		n := c.Networks[name]
+		params, err := c.newParams(name, n)
+		if err != nil {
+			return fmt.Errorf("network %s: %v", name, err)
+		}
+		// Only the parameters of segwit coins are registered, so other
+		// coins may share the magic of a Bitcoin network, as the
+		// Groestlcoin testnet does.
+		if other := bitcoinNetName(params.Net); other != "" && c.HasFeature(FeatureSegWit) {
+			return fmt.Errorf("network %s: magic %s is the magic of Bitcoin %s",
+				name, n.Net, other)
+		}
+		if other, ok := nets[params.Net]; ok {
+			return fmt.Errorf("networks %s and %s have the same magic %s",
+				other, name, n.Net)
+		}
+		nets[params.Net] = name
+		n.params = params
+	}
+	return nil
+}
+
+// bitcoinNets are the networks of Bitcoin registered with chaincfg.
+var bitcoinNets = []*chaincfg.Params{
+	&chaincfg.MainNetParams,
+	&chaincfg.TestNet3Params,
+	&chaincfg.RegressionNetParams,
+	&chaincfg.SimNetParams,
+	&chaincfg.SigNetParams,
+}
+
+// bitcoinNetName returns the name of the Bitcoin network with magic net, or
+// the empty string when there is none.
+func bitcoinNetName(net wire.BitcoinNet) string {
+	for _, params := range bitcoinNets {
+		if params.Net == net {
+			return params.Name
+		}
+	}
+	return ""
+}
+
+// newParams returns the chain parameters of network n named name.
+func (c *Coin) newParams(name string, n *Network) (*chaincfg.Params, error) {
+	net, err := strconv.ParseUint(n.Net, 0, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network magic %q", n.Net)
+	}
+	params := &chaincfg.Params{
+		Name:             strings.ToLower(c.Symbol) + "-" + name,
+		Net:              wire.BitcoinNet(net),
+		DefaultPort:      n.Port,
+		PubKeyHashAddrID: n.PubKeyHashAddrID,
+		ScriptHashAddrID: n.ScriptHashAddrID,
+		PrivateKeyID:     n.PrivateKeyID,
+		HDCoinType:       n.HDCoinType,
+	}
+	if n.PubKeyHashAddrID == n.ScriptHashAddrID {
+		return nil, errors.New("P2PKH and P2SH addresses use the same version byte")
+	}
+	if c.HasFeature(FeatureSegWit) {
+		if n.Bech32HRP == "" {
+			return nil, errors.New("segwit coin has no bech32 HRP")
+		}
+		params.Bech32HRPSegwit = n.Bech32HRP
+	}
+	if c.AddressFormat == AddressCashAddr && n.CashAddrPrefix == "" {
+		return nil, errors.New("cashaddr coin has no cashaddr prefix")
+	}
+	err = decodeKeyID(params.HDPrivateKeyID[:], n.HDPrivateKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("HD private key ID: %v", err)
+	}
+	err = decodeKeyID(params.HDPublicKeyID[:], n.HDPublicKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("HD public key ID: %v", err)
+	}
+	return params, nil
+}
+
+func decodeKeyID(id []byte, s string) error {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	if len(b) != len(id) {
+		return fmt.Errorf("%q is not %d bytes", s, len(id))
+	}
+	copy(id, b)
+	return nil
+}
+
+// Network returns the network named name, or an error when the coin does not
+// define it.
+func (c *Coin) Network(name string) (*Network, error) {
+	n, ok := c.Networks[name]
+	if !ok {
+		return nil, fmt.Errorf("coin %s does not define network %s", c.Name, name)
+	}
+	return n, nil
+}
+
+// Params returns the chain parameters of the network.  The parameters are
+// registered with chaincfg on first use, which is required for the bech32
+// addresses of the network to be decoded.
+func (n *Network) Params() (*chaincfg.Params, error) {
+	if n.params.Bech32HRPSegwit != "" && !chaincfg.IsBech32SegwitPrefix(n.params.Bech32HRPSegwit+"1") {
+		err := chaincfg.Register(n.params)
+		if err != nil {
+			return nil, fmt.Errorf("register %s: %v", n.params.Name, err)
+		}
+	}
+	return n.params, nil
+}
+
+// MinFeePerKb returns the minimum relay fee rate of the coin in satoshi per
+// kilobyte.
+func (c *Coin) MinFeePerKb() int64 {
+	return int64(c.MinRelayFee * 1000)
+}
+
+// DustFeePerKb returns the dust relay fee rate of the coin in satoshi per
+// kilobyte.
+func (c *Coin) DustFeePerKb() int64 {
+	return int64(c.DustRelayFee * 1000)
+}
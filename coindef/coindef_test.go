@@ -0,0 +1,205 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package coindef
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcutil"
+)
+
+// exampleCoin is the definition of the package documentation with its
+// testnet filled in.
+const exampleCoin = `{
+	"name": "Examplecoin",
+	"symbol": "EXC",
+	"sighash": "all",
+	"scriptfeatures": ["cltv", "sha256", "segwit"],
+	"minrelayfee": 1,
+	"dustrelayfee": 3,
+	"networks": {
+		"mainnet": {
+			"net": "0xe8c1b7dc",
+			"pubkeyhashaddrid": 0,
+			"scripthashaddrid": 5,
+			"privatekeyid": 128,
+			"bech32hrp": "exc",
+			"hdprivatekeyid": "0488ade4",
+			"hdpublickeyid": "0488b21e",
+			"hdcointype": 0,
+			"port": "8333",
+			"rpcport": "8332",
+			"electrumport": "50001",
+			"electrumtlsport": "50002"
+		},
+		"testnet": {
+			"net": "0xe9c2b8dd",
+			"pubkeyhashaddrid": 111,
+			"scripthashaddrid": 196,
+			"privatekeyid": 239,
+			"bech32hrp": "texc",
+			"hdprivatekeyid": "04358394",
+			"hdpublickeyid": "043587cf",
+			"hdcointype": 1,
+			"port": "18333",
+			"rpcport": "18332"
+		}
+	}
+}`
+
+// writeCoin writes the definition def to a temporary file and returns its
+// path.
+func writeCoin(t *testing.T, def string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "coin.json")
+	err := os.WriteFile(path, []byte(def), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadExample(t *testing.T) {
+	c, err := Load(writeCoin(t, exampleCoin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.SigHash != SigHashAll || !c.HasFeature(FeatureSegWit) {
+		t.Fatalf("sighash %q, features %v", c.SigHash, c.ScriptFeatures)
+	}
+	if c.MinFeePerKb() != 1000 || c.DustFeePerKb() != 3000 {
+		t.Fatalf("fee rates are %d and %d per kB", c.MinFeePerKb(), c.DustFeePerKb())
+	}
+
+	n, err := c.Network(MainNet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err := n.Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Registering again on later uses is not an error.
+	_, err = n.Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Name != "exc-mainnet" {
+		t.Fatalf("network name is %q", params.Name)
+	}
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := btcutil.DecodeAddress(addr.EncodeAddress(), params)
+	if err != nil {
+		t.Fatalf("decode %v: %v", addr, err)
+	}
+	if !decoded.IsForNet(params) {
+		t.Fatalf("%v is not for %s", decoded, params.Name)
+	}
+
+	_, err = c.Network("regtest")
+	if err == nil {
+		t.Fatal("undefined network returned")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace []string
+		err     string
+	}{
+		{
+			name:    "bitcoin magic",
+			replace: []string{"0xe8c1b7dc", "0xd9b4bef9"},
+			err:     "magic of Bitcoin mainnet",
+		},
+		{
+			name:    "duplicate magic",
+			replace: []string{"0xe9c2b8dd", "0xe8c1b7dc"},
+			err:     "networks mainnet and testnet have the same magic",
+		},
+		{
+			name:    "unknown sighash",
+			replace: []string{`"sighash": "all"`, `"sighash": "single"`},
+			err:     "unsupported sighash",
+		},
+		{
+			name:    "forkid with segwit",
+			replace: []string{`"sighash": "all"`, `"sighash": "forkid"`},
+			err:     "segwit is not supported with forkid",
+		},
+		{
+			name:    "missing opcode",
+			replace: []string{`"cltv", `, ""},
+			err:     "OP_CHECKLOCKTIMEVERIFY",
+		},
+		{
+			name:    "unknown address format",
+			replace: []string{`"sighash": "all"`, `"sighash": "all", "addressformat": "bech32m"`},
+			err:     "unsupported address format",
+		},
+		{
+			name:    "cashaddr without prefix",
+			replace: []string{`"sighash": "all"`, `"sighash": "all", "addressformat": "cashaddr"`},
+			err:     "network mainnet: cashaddr coin has no cashaddr prefix",
+		},
+		{
+			name:    "sha256 transaction hash with segwit",
+			replace: []string{`"sighash": "all"`, `"sighash": "all", "txhash": "sha256"`},
+			err:     "sha256 transaction hashes are only supported with legacy signatures",
+		},
+		{
+			name:    "unknown base58 checksum",
+			replace: []string{`"sighash": "all"`, `"sighash": "all", "base58checksum": "blake"`},
+			err:     "unsupported base58 checksum",
+		},
+		{
+			name:    "segwit without HRP",
+			replace: []string{`"bech32hrp": "texc",`, ""},
+			err:     "network testnet: segwit coin has no bech32 HRP",
+		},
+	}
+	for _, test := range tests {
+		def := strings.Replace(exampleCoin, test.replace[0], test.replace[1], 1)
+		_, err := Load(writeCoin(t, def))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error is %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestLoadCoins(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "coins", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no coin definitions")
+	}
+	for _, path := range paths {
+		c, err := Load(path)
+		if err != nil {
+			t.Errorf("%v", err)
+			continue
+		}
+		for _, name := range []string{MainNet, TestNet} {
+			n, err := c.Network(name)
+			if err != nil {
+				t.Errorf("%s: %v", path, err)
+				continue
+			}
+			_, err = n.Params()
+			if err != nil {
+				t.Errorf("%s: %v", path, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package coins embeds the coin definitions of the coins with their own
+// atomic swap command.
+package coins
+
+import _ "embed"
+
+// BitcoinCash is the definition of Bitcoin Cash.
+//
+//go:embed bitcoincash.json
+var BitcoinCash []byte
+
+// Dash is the definition of Dash.
+//
+//go:embed dash.json
+var Dash []byte
+
+// Groestlcoin is the definition of Groestlcoin.
+//
+//go:embed groestlcoin.json
+var Groestlcoin []byte
@@ -0,0 +1,294 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package electrumx implements a client for the Electrum server protocol
+// spoken by ElectrumX and compatible servers: JSON-RPC 2.0 requests and
+// responses delimited by newlines over a TCP or TLS connection.  It gives the
+// atomic swap tools access to the chain without an Electrum wallet daemon.
+//
+// A Client can be created on top of any net.Conn, so it can be exercised
+// against an in-process server using net.Pipe.
+package electrumx
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// ProtocolVersion is the Electrum protocol version negotiated by Handshake.
+const ProtocolVersion = "1.4"
+
+// maxLineSize is the maximum size of a single message received from the
+// server.  Raw transactions and histories can be large, so it is generous.
+const maxLineSize = 16 * 1024 * 1024
+
+// ErrClosed is returned by calls made on, or pending when closing, a closed
+// client.
+var ErrClosed = errors.New("electrumx: client closed")
+
+// ServerError is an error response of the server.
+type ServerError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("electrumx: %s (code %d)", e.Message, e.Code)
+}
+
+// request is a JSON-RPC 2.0 request.
+type request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// message is a JSON-RPC 2.0 response, or a notification when ID is nil.
+type message struct {
+	ID     *uint64         `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *ServerError    `json:"error"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// response is the result or error of a call.
+type response struct {
+	result json.RawMessage
+	err    error
+}
+
+// Client is a connection to an Electrum server.  It is safe for concurrent
+// use; requests are multiplexed over the single connection and answered as
+// the server responds.
+type Client struct {
+	conn net.Conn
+
+	// writeMtx serializes the requests written to conn.
+	writeMtx sync.Mutex
+
+	mtx     sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan *response
+
+	// scriptHashSubs and headerSubs receive the notifications of the
+	// subscriptions made on the client.
+	scriptHashSubs map[string]chan string
+	headerSubs     []chan *Header
+
+	closing bool
+	done    chan struct{}
+	err     error
+}
+
+// Dial connects to the Electrum server at addr, using TLS when tlsConfig is
+// not nil, and returns a client for it.  Handshake should be called before
+// any other request.
+func Dial(ctx context.Context, addr string, tlsConfig *tls.Config) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		tlsConn := tls.Client(conn, tlsConfig)
+		err = tlsConn.HandshakeContext(ctx)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient returns a client speaking the Electrum protocol over conn.  The
+// client owns conn and closes it when the client is closed.
+func NewClient(conn net.Conn) *Client {
+	c := &Client{
+		conn:           conn,
+		pending:        make(map[uint64]chan *response),
+		scriptHashSubs: make(map[string]chan string),
+		done:           make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Close closes the connection.  Pending calls return ErrClosed and the
+// notification channels of all subscriptions are closed.
+func (c *Client) Close() error {
+	c.mtx.Lock()
+	c.closing = true
+	c.mtx.Unlock()
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
+
+// Done returns a channel that is closed once the connection is lost or the
+// client is closed.  Err returns the reason afterwards.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error the connection ended with, or nil while it is open.
+func (c *Client) Err() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.err
+}
+
+// readLoop reads the messages sent by the server and delivers responses to
+// the pending calls and notifications to the subscriptions until the
+// connection is closed.  It must be run as a goroutine.
+func (c *Client) readLoop() {
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	var err error
+	for scanner.Scan() {
+		var msg message
+		err = json.Unmarshal(scanner.Bytes(), &msg)
+		if err != nil {
+			err = fmt.Errorf("electrumx: invalid message: %v", err)
+			break
+		}
+		if msg.ID == nil {
+			c.notify(&msg)
+			continue
+		}
+		c.respond(&msg)
+	}
+	if err == nil {
+		err = scanner.Err()
+	}
+	c.mtx.Lock()
+	if err == nil || c.closing {
+		err = ErrClosed
+	}
+	c.mtx.Unlock()
+	c.shutdown(err)
+}
+
+// shutdown fails the pending calls with err and closes the subscription
+// channels.
+func (c *Client) shutdown(err error) {
+	c.conn.Close()
+
+	c.mtx.Lock()
+	c.err = err
+	for id, ch := range c.pending {
+		ch <- &response{err: err}
+		delete(c.pending, id)
+	}
+	for scriptHash, ch := range c.scriptHashSubs {
+		close(ch)
+		delete(c.scriptHashSubs, scriptHash)
+	}
+	for _, ch := range c.headerSubs {
+		close(ch)
+	}
+	c.headerSubs = nil
+	c.mtx.Unlock()
+
+	close(c.done)
+}
+
+// respond delivers the response msg to the pending call with the same id.
+// Responses to calls that were abandoned are dropped.
+func (c *Client) respond(msg *message) {
+	c.mtx.Lock()
+	ch, ok := c.pending[*msg.ID]
+	delete(c.pending, *msg.ID)
+	c.mtx.Unlock()
+	if !ok {
+		return
+	}
+
+	if msg.Error != nil {
+		ch <- &response{err: msg.Error}
+		return
+	}
+	ch <- &response{result: msg.Result}
+}
+
+// call sends a request for method and unmarshals its result into result,
+// which may be nil to ignore the result.
+func (c *Client) call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	ch := make(chan *response, 1)
+
+	c.mtx.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mtx.Unlock()
+		return err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mtx.Unlock()
+
+	b, err := json.Marshal(&request{
+		JSONRPC: "2.0",
+		ID:      id,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		c.forget(id)
+		return err
+	}
+	b = append(b, '\n')
+
+	c.writeMtx.Lock()
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
+		c.conn.SetWriteDeadline(deadline)
+	}
+	_, err = c.conn.Write(b)
+	if hasDeadline {
+		c.conn.SetWriteDeadline(time.Time{})
+	}
+	c.writeMtx.Unlock()
+	if err != nil {
+		c.forget(id)
+		return err
+	}
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return r.err
+		}
+		if result == nil {
+			return nil
+		}
+		err = json.Unmarshal(r.result, result)
+		if err != nil {
+			return fmt.Errorf("electrumx: invalid %s result: %v", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		c.forget(id)
+		return ctx.Err()
+	}
+}
+
+// forget removes the pending call with the passed id.
+func (c *Client) forget(id uint64) {
+	c.mtx.Lock()
+	delete(c.pending, id)
+	c.mtx.Unlock()
+}
@@ -0,0 +1,398 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package electrumx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// testTimeout bounds every wait of the tests so a broken client fails the
+// test instead of hanging it.
+const testTimeout = 5 * time.Second
+
+// serverRequest is a request as read by the fake server.
+type serverRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// fakeServer is the server end of a client connected with net.Pipe.  The
+// tests drive it from the test goroutine while the client calls are made in
+// other goroutines.
+type fakeServer struct {
+	t       *testing.T
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+func newTestClient(t *testing.T) (*Client, *fakeServer) {
+	clientConn, serverConn := net.Pipe()
+	c := NewClient(clientConn)
+	s := &fakeServer{t: t, conn: serverConn, scanner: bufio.NewScanner(serverConn)}
+	t.Cleanup(func() {
+		serverConn.Close()
+		c.Close()
+	})
+	return c, s
+}
+
+// read returns the next request sent by the client.
+func (s *fakeServer) read() *serverRequest {
+	s.t.Helper()
+	s.conn.SetReadDeadline(time.Now().Add(testTimeout))
+	if !s.scanner.Scan() {
+		s.t.Fatalf("read request: %v", s.scanner.Err())
+	}
+	var req serverRequest
+	err := json.Unmarshal(s.scanner.Bytes(), &req)
+	if err != nil {
+		s.t.Fatalf("invalid request %s: %v", s.scanner.Bytes(), err)
+	}
+	if req.JSONRPC != "2.0" {
+		s.t.Fatalf("request has jsonrpc %q", req.JSONRPC)
+	}
+	return &req
+}
+
+// send writes msg to the client as a single line.
+func (s *fakeServer) send(msg interface{}) {
+	s.t.Helper()
+	b, err := json.Marshal(msg)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(testTimeout))
+	_, err = s.conn.Write(append(b, '\n'))
+	if err != nil {
+		s.t.Fatalf("write: %v", err)
+	}
+}
+
+// respond answers the request with the passed id with result.
+func (s *fakeServer) respond(id uint64, result interface{}) {
+	s.t.Helper()
+	s.send(map[string]interface{}{"jsonrpc": "2.0", "id": id, "result": result})
+}
+
+// notify sends a notification of method with params.
+func (s *fakeServer) notify(method string, params ...interface{}) {
+	s.t.Helper()
+	s.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// goCall runs fn in a new goroutine and returns the channel its error is
+// delivered on.
+func goCall(fn func() error) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- fn()
+	}()
+	return errc
+}
+
+// wait returns the error delivered on errc.
+func wait(t *testing.T, errc <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(testTimeout):
+		t.Fatal("call did not return")
+		return nil
+	}
+}
+
+func TestCall(t *testing.T) {
+	c, s := newTestClient(t)
+	ctx := context.Background()
+
+	var version string
+	errc := goCall(func() (err error) {
+		version, err = c.Handshake(ctx, "test")
+		return err
+	})
+	req := s.read()
+	if req.Method != "server.version" {
+		t.Fatalf("method is %q, want server.version", req.Method)
+	}
+	if string(req.Params) != `["test","`+ProtocolVersion+`"]` {
+		t.Fatalf("params are %s", req.Params)
+	}
+	s.respond(req.ID, []string{"ElectrumX 1.16.0", ProtocolVersion})
+	if err := wait(t, errc); err != nil {
+		t.Fatal(err)
+	}
+	if version != "ElectrumX 1.16.0" {
+		t.Fatalf("server version is %q", version)
+	}
+
+	var feeRate int64
+	errc = goCall(func() (err error) {
+		feeRate, err = c.EstimateFee(ctx, 6)
+		return err
+	})
+	req = s.read()
+	if req.Method != "blockchain.estimatefee" || string(req.Params) != "[6]" {
+		t.Fatalf("request is %s %s", req.Method, req.Params)
+	}
+	s.respond(req.ID, 0.00012345)
+	if err := wait(t, errc); err != nil {
+		t.Fatal(err)
+	}
+	if feeRate != 12345 {
+		t.Fatalf("fee rate is %d, want 12345", feeRate)
+	}
+
+	errc = goCall(func() error {
+		_, err := c.EstimateFee(ctx, 6)
+		return err
+	})
+	req = s.read()
+	s.respond(req.ID, -1)
+	if err := wait(t, errc); err != ErrNoFeeEstimate {
+		t.Fatalf("error is %v, want ErrNoFeeEstimate", err)
+	}
+}
+
+func TestServerError(t *testing.T) {
+	c, s := newTestClient(t)
+
+	errc := goCall(func() error {
+		return c.Ping(context.Background())
+	})
+	req := s.read()
+	s.send(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      req.ID,
+		"error":   map[string]interface{}{"code": -32601, "message": "unknown method"},
+	})
+	err := wait(t, errc)
+	var serverErr *ServerError
+	if !errors.As(err, &serverErr) {
+		t.Fatalf("error is %v, want a ServerError", err)
+	}
+	if serverErr.Code != -32601 || serverErr.Message != "unknown method" {
+		t.Fatalf("server error is %+v", serverErr)
+	}
+
+	// An error does not affect the following calls.
+	errc = goCall(func() error {
+		return c.Ping(context.Background())
+	})
+	req = s.read()
+	s.respond(req.ID, nil)
+	if err := wait(t, errc); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOutOfOrderResponses(t *testing.T) {
+	c, s := newTestClient(t)
+	ctx := context.Background()
+
+	const calls = 3
+	fees := make([]int64, calls)
+	errcs := make([]<-chan error, calls)
+	requests := make(map[string]uint64)
+	for i := 0; i < calls; i++ {
+		i := i
+		errcs[i] = goCall(func() (err error) {
+			fees[i], err = c.EstimateFee(ctx, i+1)
+			return err
+		})
+		// Reading the request before making the next call orders the
+		// requests on the connection.
+		req := s.read()
+		requests[string(req.Params)] = req.ID
+	}
+
+	// Answer in reverse order with a rate identifying the request.
+	for i := calls - 1; i >= 0; i-- {
+		blocks, _ := json.Marshal([]int{i + 1})
+		id, ok := requests[string(blocks)]
+		if !ok {
+			t.Fatalf("no request for %d blocks", i+1)
+		}
+		s.respond(id, float64(i+1)*1e-5)
+	}
+	for i := 0; i < calls; i++ {
+		if err := wait(t, errcs[i]); err != nil {
+			t.Fatal(err)
+		}
+		if want := int64(i+1) * 1000; fees[i] != want {
+			t.Errorf("call %d got fee rate %d, want %d", i, fees[i], want)
+		}
+	}
+}
+
+func TestScriptHashNotifications(t *testing.T) {
+	c, s := newTestClient(t)
+	scriptHash := ScriptHash([]byte{0x51})
+
+	var status string
+	var statuses <-chan string
+	errc := goCall(func() (err error) {
+		status, statuses, err = c.SubscribeScriptHash(context.Background(), scriptHash)
+		return err
+	})
+	req := s.read()
+	if req.Method != "blockchain.scripthash.subscribe" {
+		t.Fatalf("method is %q", req.Method)
+	}
+	// A script hash without history has a null status.
+	s.respond(req.ID, nil)
+	if err := wait(t, errc); err != nil {
+		t.Fatal(err)
+	}
+	if status != "" {
+		t.Fatalf("status is %q, want empty", status)
+	}
+
+	// Notifications for other script hashes are not delivered, and only
+	// the latest status is kept for a receiver falling behind.
+	s.notify("blockchain.scripthash.subscribe", ScriptHash([]byte{0x52}), "other")
+	s.notify("blockchain.scripthash.subscribe", scriptHash, "first")
+	s.notify("blockchain.scripthash.subscribe", scriptHash, "second")
+
+	// A response after the notifications guarantees they were handled.
+	errc = goCall(func() error {
+		return c.Ping(context.Background())
+	})
+	s.respond(s.read().ID, nil)
+	if err := wait(t, errc); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case status := <-statuses:
+		if status != "second" {
+			t.Fatalf("status is %q, want second", status)
+		}
+	default:
+		t.Fatal("no status notified")
+	}
+	select {
+	case status := <-statuses:
+		t.Fatalf("unexpected status %q", status)
+	default:
+	}
+}
+
+func TestHeaderNotifications(t *testing.T) {
+	c, s := newTestClient(t)
+
+	genesis := &chaincfg.MainNetParams.GenesisBlock.Header
+	var buf bytes.Buffer
+	err := genesis.Serialize(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headerHex := hex.EncodeToString(buf.Bytes())
+
+	var tip *Header
+	var headers <-chan *Header
+	errc := goCall(func() (err error) {
+		tip, headers, err = c.SubscribeHeaders(context.Background())
+		return err
+	})
+	req := s.read()
+	if req.Method != "blockchain.headers.subscribe" {
+		t.Fatalf("method is %q", req.Method)
+	}
+	s.respond(req.ID, map[string]interface{}{"height": 100, "hex": headerHex})
+	if err := wait(t, errc); err != nil {
+		t.Fatal(err)
+	}
+	if tip.Height != 100 || tip.Header.BlockHash() != genesis.BlockHash() {
+		t.Fatalf("tip is %d %v", tip.Height, tip.Header.BlockHash())
+	}
+
+	s.notify("blockchain.headers.subscribe", map[string]interface{}{"height": 101, "hex": headerHex})
+	select {
+	case header := <-headers:
+		if header.Height != 101 || header.Header.BlockHash() != genesis.BlockHash() {
+			t.Fatalf("header is %d %v", header.Height, header.Header.BlockHash())
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("no header notified")
+	}
+}
+
+func TestCloseDrainsPendingCalls(t *testing.T) {
+	c, s := newTestClient(t)
+	ctx := context.Background()
+
+	var statuses <-chan string
+	errc := goCall(func() (err error) {
+		_, statuses, err = c.SubscribeScriptHash(ctx, ScriptHash([]byte{0x51}))
+		return err
+	})
+	s.respond(s.read().ID, "status")
+	if err := wait(t, errc); err != nil {
+		t.Fatal(err)
+	}
+
+	// Calls the server never answers are pending when the client closes.
+	pending := []<-chan error{
+		goCall(func() error { return c.Ping(ctx) }),
+		goCall(func() error { return c.Ping(ctx) }),
+	}
+	s.read()
+	s.read()
+
+	err := c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, errc := range pending {
+		if err := wait(t, errc); err != ErrClosed {
+			t.Fatalf("pending call returned %v, want ErrClosed", err)
+		}
+	}
+	select {
+	case <-c.Done():
+	default:
+		t.Fatal("done channel is not closed")
+	}
+	if c.Err() != ErrClosed {
+		t.Fatalf("Err is %v, want ErrClosed", c.Err())
+	}
+	if _, ok := <-statuses; ok {
+		t.Fatal("subscription channel is not closed")
+	}
+	if err := c.Ping(ctx); err != ErrClosed {
+		t.Fatalf("call after close returned %v, want ErrClosed", err)
+	}
+}
+
+func TestServerDisconnect(t *testing.T) {
+	c, s := newTestClient(t)
+
+	errc := goCall(func() error {
+		return c.Ping(context.Background())
+	})
+	s.read()
+	s.conn.Close()
+
+	if err := wait(t, errc); err != ErrClosed {
+		t.Fatalf("pending call returned %v, want ErrClosed", err)
+	}
+	select {
+	case <-c.Done():
+	case <-time.After(testTimeout):
+		t.Fatal("done channel is not closed")
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package electrumx
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ScriptHash returns the script hash identifying pkScript in the Electrum
+// protocol: the SHA-256 hash of the script, hex encoded in reverse byte order.
+func ScriptHash(pkScript []byte) string {
+	hash := sha256.Sum256(pkScript)
+	for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+		hash[i], hash[j] = hash[j], hash[i]
+	}
+	return hex.EncodeToString(hash[:])
+}
+
+// Handshake negotiates the protocol version with the server.  It must be the
+// first request on a new connection.  It returns the server software version.
+func (c *Client) Handshake(ctx context.Context, clientName string) (string, error) {
+	var result []string
+	err := c.call(ctx, "server.version", &result, clientName, ProtocolVersion)
+	if err != nil {
+		return "", err
+	}
+	if len(result) != 2 {
+		return "", fmt.Errorf("electrumx: invalid server.version result %v", result)
+	}
+	return result[0], nil
+}
+
+// Ping checks the connection to the server is alive.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.call(ctx, "server.ping", nil)
+}
+
+// HistoryTx is a transaction in the history of a script hash.  Height is zero
+// for unconfirmed transactions and -1 for unconfirmed transactions spending
+// unconfirmed outputs.
+type HistoryTx struct {
+	TxHash *chainhash.Hash
+	Height int32
+}
+
+// GetHistory returns the confirmed and unconfirmed transactions paying to or
+// spending from the script hash.
+func (c *Client) GetHistory(ctx context.Context, scriptHash string) ([]*HistoryTx, error) {
+	var result []struct {
+		TxHash string `json:"tx_hash"`
+		Height int32  `json:"height"`
+	}
+	err := c.call(ctx, "blockchain.scripthash.get_history", &result, scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	history := make([]*HistoryTx, 0, len(result))
+	for _, entry := range result {
+		txHash, err := chainhash.NewHashFromStr(entry.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, &HistoryTx{TxHash: txHash, Height: entry.Height})
+	}
+	return history, nil
+}
+
+// UnspentOutput is an unspent output paying to a script hash.  Height is zero
+// for unconfirmed outputs.
+type UnspentOutput struct {
+	OutPoint *wire.OutPoint
+	Value    int64
+	Height   int32
+}
+
+// ListUnspent returns the unspent outputs paying to the script hash.
+func (c *Client) ListUnspent(ctx context.Context, scriptHash string) ([]*UnspentOutput, error) {
+	var result []struct {
+		TxHash string `json:"tx_hash"`
+		TxPos  uint32 `json:"tx_pos"`
+		Value  int64  `json:"value"`
+		Height int32  `json:"height"`
+	}
+	err := c.call(ctx, "blockchain.scripthash.listunspent", &result, scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	utxos := make([]*UnspentOutput, 0, len(result))
+	for _, entry := range result {
+		txHash, err := chainhash.NewHashFromStr(entry.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		utxos = append(utxos, &UnspentOutput{
+			OutPoint: wire.NewOutPoint(txHash, entry.TxPos),
+			Value:    entry.Value,
+			Height:   entry.Height,
+		})
+	}
+	return utxos, nil
+}
+
+// GetTransaction returns the transaction with the passed hash.
+func (c *Client) GetTransaction(ctx context.Context, txHash *chainhash.Hash) (*wire.MsgTx, error) {
+	var txHex string
+	err := c.call(ctx, "blockchain.transaction.get", &txHex, txHash.String())
+	if err != nil {
+		return nil, err
+	}
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, err
+	}
+	tx := &wire.MsgTx{}
+	err = tx.Deserialize(bytes.NewReader(txBytes))
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Broadcast submits the transaction to the network and returns its hash.
+func (c *Client) Broadcast(ctx context.Context, tx *wire.MsgTx) (*chainhash.Hash, error) {
+	var buf bytes.Buffer
+	buf.Grow(tx.SerializeSize())
+	err := tx.Serialize(&buf)
+	if err != nil {
+		return nil, err
+	}
+	var txID string
+	err = c.call(ctx, "blockchain.transaction.broadcast", &txID,
+		hex.EncodeToString(buf.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	return chainhash.NewHashFromStr(txID)
+}
+
+// ErrNoFeeEstimate is returned by EstimateFee when the server has not
+// gathered enough data to estimate the fee rate.
+var ErrNoFeeEstimate = errors.New("electrumx: no fee estimate available")
+
+// EstimateFee returns the fee rate, in satoshi per kilobyte, estimated for
+// a transaction to be confirmed within the passed number of blocks.
+func (c *Client) EstimateFee(ctx context.Context, blocks int) (int64, error) {
+	var feeRate float64
+	err := c.call(ctx, "blockchain.estimatefee", &feeRate, blocks)
+	if err != nil {
+		return 0, err
+	}
+	// The server reports the fee rate in coins per kilobyte, and -1
+	// when it has no estimate.
+	if feeRate <= 0 {
+		return 0, ErrNoFeeEstimate
+	}
+	return int64(math.Round(feeRate * 1e8)), nil
+}
+
+// SubscribeScriptHash subscribes to changes of the history of the script
+// hash.  It returns the current status, an empty string when the script hash
+// has no history, and a channel receiving the new status after every change.
+// Only the latest status is kept when the receiver falls behind, as the
+// history can always be queried with GetHistory.  The channel is closed when
+// the connection ends.
+func (c *Client) SubscribeScriptHash(ctx context.Context, scriptHash string) (string, <-chan string, error) {
+	c.mtx.Lock()
+	ch, ok := c.scriptHashSubs[scriptHash]
+	if !ok && c.err == nil {
+		ch = make(chan string, 1)
+		c.scriptHashSubs[scriptHash] = ch
+	}
+	c.mtx.Unlock()
+
+	var status *string
+	err := c.call(ctx, "blockchain.scripthash.subscribe", &status, scriptHash)
+	if err != nil {
+		if !ok {
+			c.mtx.Lock()
+			if c.scriptHashSubs[scriptHash] == ch {
+				delete(c.scriptHashSubs, scriptHash)
+			}
+			c.mtx.Unlock()
+		}
+		return "", nil, err
+	}
+	if status == nil {
+		return "", ch, nil
+	}
+	return *status, ch, nil
+}
+
+// Header is a block header notified by the server.
+type Header struct {
+	Height int32
+	Header *wire.BlockHeader
+}
+
+// rawHeader is the header notification and subscription result.
+type rawHeader struct {
+	Height int32  `json:"height"`
+	Hex    string `json:"hex"`
+}
+
+func (h *rawHeader) decode() (*Header, error) {
+	b, err := hex.DecodeString(h.Hex)
+	if err != nil {
+		return nil, err
+	}
+	header := &wire.BlockHeader{}
+	err = header.Deserialize(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return &Header{Height: h.Height, Header: header}, nil
+}
+
+// SubscribeHeaders subscribes to new blocks.  It returns the current tip and
+// a channel receiving every new tip.  Only the latest tip is kept when the
+// receiver falls behind.  The channel is closed when the connection ends.
+func (c *Client) SubscribeHeaders(ctx context.Context) (*Header, <-chan *Header, error) {
+	ch := make(chan *Header, 1)
+	c.mtx.Lock()
+	if c.err == nil {
+		c.headerSubs = append(c.headerSubs, ch)
+	}
+	c.mtx.Unlock()
+
+	var result rawHeader
+	err := c.call(ctx, "blockchain.headers.subscribe", &result)
+	if err != nil {
+		c.mtx.Lock()
+		for i, sub := range c.headerSubs {
+			if sub == ch {
+				c.headerSubs = append(c.headerSubs[:i], c.headerSubs[i+1:]...)
+				break
+			}
+		}
+		c.mtx.Unlock()
+		return nil, nil, err
+	}
+	tip, err := result.decode()
+	if err != nil {
+		return nil, nil, err
+	}
+	return tip, ch, nil
+}
+
+// notify delivers a notification to the subscriptions it is for.
+// Notifications that can not be decoded are dropped.
+func (c *Client) notify(msg *message) {
+	switch msg.Method {
+	case "blockchain.scripthash.subscribe":
+		var params []*string
+		if json.Unmarshal(msg.Params, &params) != nil || len(params) != 2 ||
+			params[0] == nil {
+			return
+		}
+		status := ""
+		if params[1] != nil {
+			status = *params[1]
+		}
+		c.mtx.Lock()
+		if ch, ok := c.scriptHashSubs[*params[0]]; ok {
+			sendLatestStatus(ch, status)
+		}
+		c.mtx.Unlock()
+
+	case "blockchain.headers.subscribe":
+		var params []*rawHeader
+		if json.Unmarshal(msg.Params, &params) != nil || len(params) != 1 ||
+			params[0] == nil {
+			return
+		}
+		header, err := params[0].decode()
+		if err != nil {
+			return
+		}
+		c.mtx.Lock()
+		for _, ch := range c.headerSubs {
+			sendLatestHeader(ch, header)
+		}
+		c.mtx.Unlock()
+	}
+}
+
+// sendLatestStatus sends status on the buffered channel ch, replacing a status
+// the receiver has not picked up yet.
+func sendLatestStatus(ch chan string, status string) {
+	for {
+		select {
+		case ch <- status:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// sendLatestHeader sends header on the buffered channel ch, replacing a header
+// the receiver has not picked up yet.
+func sendLatestHeader(ch chan *Header, header *Header) {
+	for {
+		select {
+		case ch <- header:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package feepolicy implements the fee rate selection and fee ceilings shared
+// by the atomic swap tools.  All amounts are expressed in the smallest unit of
+// the coin (satoshi) so the package can be used independent of the chain.
+package feepolicy
+
+import (
+	"flag"
+	"fmt"
+	"math"
+
+	"github.com/btcsuite/btcutil"
+)
+
+// DefaultConfTarget is the confirmation target, in blocks, used when none is
+// configured.
+const DefaultConfTarget = 6
+
+// DefaultMinFeeRate is the default minimum relay fee rate in satoshi per
+// virtual byte.
+const DefaultMinFeeRate = 1
+
+// Policy describes how the fee rate of a transaction is chosen and which
+// absolute fees are acceptable.
+type Policy struct {
+	// FeeRate is an explicit fee rate in satoshi per virtual byte.  When
+	// zero the fee rate is estimated for ConfTarget.
+	FeeRate float64
+
+	// ConfTarget is the number of blocks in which a transaction should
+	// confirm when the fee rate is estimated.
+	ConfTarget int
+
+	// MinFeeRate is the minimum relay fee rate in satoshi per virtual byte.
+	// Neither explicit nor estimated fee rates are allowed below it.
+	MinFeeRate float64
+
+	// MaxFee is the maximum absolute fee of a single transaction in whole
+	// coins.  Zero disables the ceiling.
+	MaxFee float64
+
+	// MaxFeePercent is the maximum fee of a transaction as a percentage of
+	// the contract value it creates or spends.  Zero disables the ceiling.
+	MaxFeePercent float64
+}
+
+// RegisterFlags registers the command line flags configuring p on fs.
+func (p *Policy) RegisterFlags(fs *flag.FlagSet) {
+	fs.Float64Var(&p.FeeRate, "feerate", 0, "explicit fee rate in sat/vB (default: estimate for -conf-target)")
+	fs.IntVar(&p.ConfTarget, "conf-target", DefaultConfTarget, "confirmation target in blocks used for fee estimation")
+	fs.Float64Var(&p.MinFeeRate, "minfeerate", DefaultMinFeeRate, "minimum relay fee rate in sat/vB")
+	fs.Float64Var(&p.MaxFee, "maxfee", 0, "maximum absolute fee of a transaction in coins (0 for no limit)")
+	fs.Float64Var(&p.MaxFeePercent, "maxfeepercent", 0, "maximum fee of a transaction as a percentage of the contract value (0 for no limit)")
+}
+
+// MinFeePerKb returns the minimum relay fee rate in satoshi per kilobyte.
+func (p *Policy) MinFeePerKb() int64 {
+	return int64(p.MinFeeRate * 1000)
+}
+
+// ExplicitFeePerKb returns the configured fee rate in satoshi per kilobyte,
+// raised to the minimum relay fee rate, and whether a fee rate was configured
+// at all.
+func (p *Policy) ExplicitFeePerKb() (int64, bool) {
+	if p.FeeRate <= 0 {
+		return 0, false
+	}
+	return p.floor(int64(p.FeeRate * 1000)), true
+}
+
+// FeePerKb returns the fee rate in satoshi per kilobyte to use for new
+// transactions.  The explicit fee rate is used when configured, otherwise
+// estimate is called with the confirmation target.  The result is never below
+// the minimum relay fee rate.
+func (p *Policy) FeePerKb(estimate func(confTarget int) (int64, error)) (int64, error) {
+	if feePerKb, ok := p.ExplicitFeePerKb(); ok {
+		return feePerKb, nil
+	}
+	confTarget := p.ConfTarget
+	if confTarget <= 0 {
+		confTarget = DefaultConfTarget
+	}
+	feePerKb, err := estimate(confTarget)
+	if err != nil {
+		return 0, err
+	}
+	return p.floor(feePerKb), nil
+}
+
+func (p *Policy) floor(feePerKb int64) int64 {
+	if min := p.MinFeePerKb(); feePerKb < min {
+		return min
+	}
+	return feePerKb
+}
+
+// CeilingError describes a transaction fee that exceeds one of the ceilings
+// of a Policy.
+type CeilingError struct {
+	// Description names the transaction the fee was calculated for.
+	Description string
+
+	// Fee is the fee of the transaction in satoshi.
+	Fee int64
+
+	// Limit is the ceiling that was exceeded in satoshi.
+	Limit int64
+
+	// Flag is the name of the flag configuring the exceeded ceiling.
+	Flag string
+}
+
+// Error satisfies the error interface.
+func (e *CeilingError) Error() string {
+	return fmt.Sprintf("%s fee of %.8f exceeds the -%s ceiling of %.8f",
+		e.Description, float64(e.Fee)/1e8, e.Flag, float64(e.Limit)/1e8)
+}
+
+// CheckFee returns a *CeilingError when fee exceeds the maximum absolute fee
+// or the maximum percentage of value.  Both amounts are in satoshi and
+// description names the transaction in the error message.
+func (p *Policy) CheckFee(description string, fee, value int64) error {
+	if p.MaxFee > 0 {
+		// NewAmount rounds, so a ceiling such as 0.29 is not truncated
+		// to one satoshi less by the floating point error.
+		limit, err := btcutil.NewAmount(p.MaxFee)
+		if err != nil {
+			return fmt.Errorf("-maxfee: %v", err)
+		}
+		if fee > int64(limit) {
+			return &CeilingError{description, fee, int64(limit), "maxfee"}
+		}
+	}
+	if p.MaxFeePercent > 0 {
+		limit := int64(math.Round(float64(value) * p.MaxFeePercent / 100))
+		if fee > limit {
+			return &CeilingError{description, fee, limit, "maxfeepercent"}
+		}
+	}
+	return nil
+}
+
+// ElectrumFeeLevel maps a confirmation target to the fee level of Electrum's
+// "eta" fee estimation mode, which selects between the estimates for 25, 10,
+// 5, 2 and 1 blocks.
+func ElectrumFeeLevel(confTarget int) float64 {
+	switch {
+	case confTarget >= 25:
+		return 0
+	case confTarget >= 10:
+		return 0.25
+	case confTarget >= 5:
+		return 0.5
+	case confTarget >= 2:
+		return 0.75
+	default:
+		return 1
+	}
+}
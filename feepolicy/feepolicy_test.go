@@ -0,0 +1,194 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package feepolicy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFeePerKb(t *testing.T) {
+	errEstimate := errors.New("no estimate")
+	tests := []struct {
+		name       string
+		policy     Policy
+		estimate   int64
+		err        error
+		want       int64
+		wantTarget int
+	}{
+		{
+			name:   "explicit rate",
+			policy: Policy{FeeRate: 5, ConfTarget: 2, MinFeeRate: 1},
+			want:   5000,
+		},
+		{
+			name:   "explicit rate below the floor",
+			policy: Policy{FeeRate: 0.5, MinFeeRate: 1},
+			want:   1000,
+		},
+		{
+			name:   "fractional explicit rate",
+			policy: Policy{FeeRate: 1.5, MinFeeRate: 1},
+			want:   1500,
+		},
+		{
+			name:       "estimate",
+			policy:     Policy{ConfTarget: 2, MinFeeRate: 1},
+			estimate:   12000,
+			want:       12000,
+			wantTarget: 2,
+		},
+		{
+			name:       "estimate below the floor",
+			policy:     Policy{ConfTarget: 2, MinFeeRate: 1},
+			estimate:   999,
+			want:       1000,
+			wantTarget: 2,
+		},
+		{
+			name:       "estimate at the floor",
+			policy:     Policy{ConfTarget: 2, MinFeeRate: 1},
+			estimate:   1000,
+			want:       1000,
+			wantTarget: 2,
+		},
+		{
+			name:       "default confirmation target",
+			policy:     Policy{MinFeeRate: 1},
+			estimate:   3000,
+			want:       3000,
+			wantTarget: DefaultConfTarget,
+		},
+		{
+			name:       "estimate failure",
+			policy:     Policy{ConfTarget: 25, MinFeeRate: 1},
+			err:        errEstimate,
+			wantTarget: 25,
+		},
+	}
+	for _, test := range tests {
+		target := 0
+		got, err := test.policy.FeePerKb(func(confTarget int) (int64, error) {
+			target = confTarget
+			return test.estimate, test.err
+		})
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: error is %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: fee rate is %d, want %d", test.name, got, test.want)
+		}
+		if target != test.wantTarget {
+			t.Errorf("%s: estimated for %d blocks, want %d", test.name, target, test.wantTarget)
+		}
+	}
+}
+
+func TestElectrumFeeLevel(t *testing.T) {
+	tests := []struct {
+		confTarget int
+		want       float64
+	}{
+		{0, 1},
+		{1, 1},
+		{2, 0.75},
+		{4, 0.75},
+		{5, 0.5},
+		{DefaultConfTarget, 0.5},
+		{9, 0.5},
+		{10, 0.25},
+		{24, 0.25},
+		{25, 0},
+		{144, 0},
+	}
+	for _, test := range tests {
+		if got := ElectrumFeeLevel(test.confTarget); got != test.want {
+			t.Errorf("ElectrumFeeLevel(%d) is %v, want %v", test.confTarget, got, test.want)
+		}
+	}
+}
+
+func TestCheckFee(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		fee    int64
+		value  int64
+		flag   string
+	}{
+		{
+			name: "no ceilings",
+			fee:  1e8,
+		},
+		{
+			name:   "at the absolute ceiling",
+			policy: Policy{MaxFee: 0.29},
+			fee:    29000000,
+		},
+		{
+			name:   "above the absolute ceiling",
+			policy: Policy{MaxFee: 0.29},
+			fee:    29000001,
+			flag:   "maxfee",
+		},
+		{
+			name:   "at a one satoshi ceiling",
+			policy: Policy{MaxFee: 0.00000001},
+			fee:    1,
+		},
+		{
+			name:   "at the percent ceiling",
+			policy: Policy{MaxFeePercent: 0.29},
+			fee:    290000,
+			value:  1e8,
+		},
+		{
+			name:   "above the percent ceiling",
+			policy: Policy{MaxFeePercent: 0.29},
+			fee:    290001,
+			value:  1e8,
+			flag:   "maxfeepercent",
+		},
+		{
+			name:   "within both ceilings",
+			policy: Policy{MaxFee: 0.01, MaxFeePercent: 1},
+			fee:    1e6,
+			value:  1e8,
+		},
+		{
+			name:   "above the absolute ceiling first",
+			policy: Policy{MaxFee: 0.001, MaxFeePercent: 0.5},
+			fee:    1e6,
+			value:  1e8,
+			flag:   "maxfee",
+		},
+		{
+			name:   "above the percent ceiling only",
+			policy: Policy{MaxFee: 0.1, MaxFeePercent: 0.5},
+			fee:    1e6,
+			value:  1e8,
+			flag:   "maxfeepercent",
+		},
+	}
+	for _, test := range tests {
+		err := test.policy.CheckFee("contract", test.fee, test.value)
+		if test.flag == "" {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			continue
+		}
+		var ceilingErr *CeilingError
+		if !errors.As(err, &ceilingErr) {
+			t.Errorf("%s: error is %v, want a *CeilingError", test.name, err)
+			continue
+		}
+		if ceilingErr.Flag != test.flag || ceilingErr.Fee != test.fee || ceilingErr.Description != "contract" {
+			t.Errorf("%s: error is %+v", test.name, ceilingErr)
+		}
+	}
+}
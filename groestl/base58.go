@@ -0,0 +1,103 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package groestl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/base58"
+)
+
+// Groestlcoin encodes addresses and private keys like Bitcoin, except that
+// the checksum of the base58 encoding is the start of the double Groestl-512
+// hash of the payload rather than of its double SHA256 hash.
+
+// checksum returns the checksum of a version byte and payload.
+func checksum(input []byte) []byte {
+	h := Sum(input)
+	h = Sum(h[:])
+	return h[:4]
+}
+
+// CheckEncode returns the base58 encoding of the version byte and payload
+// with their Groestl-512 checksum.
+func CheckEncode(input []byte, version byte) string {
+	b := make([]byte, 0, 1+len(input)+4)
+	b = append(b, version)
+	b = append(b, input...)
+	b = append(b, checksum(b)...)
+	return base58.Encode(b)
+}
+
+// CheckDecode decodes a base58 string with a Groestl-512 checksum and returns
+// its payload and version byte.
+func CheckDecode(input string) (result []byte, version byte, err error) {
+	b := base58.Decode(input)
+	if len(b) < 5 {
+		return nil, 0, errors.New("invalid format: version and/or checksum bytes missing")
+	}
+	if !bytes.Equal(checksum(b[:len(b)-4]), b[len(b)-4:]) {
+		return nil, 0, errors.New("checksum mismatch")
+	}
+	return b[1 : len(b)-4], b[0], nil
+}
+
+// EncodeAddress returns the Groestlcoin encoding of a P2PKH or P2SH address
+// of the network with params.
+func EncodeAddress(addr btcutil.Address, params *chaincfg.Params) (string, error) {
+	switch addr := addr.(type) {
+	case *btcutil.AddressPubKeyHash:
+		return CheckEncode(addr.Hash160()[:], params.PubKeyHashAddrID), nil
+	case *btcutil.AddressScriptHash:
+		return CheckEncode(addr.Hash160()[:], params.ScriptHashAddrID), nil
+	}
+	return "", fmt.Errorf("address %v can not be encoded with a Groestl-512 checksum", addr)
+}
+
+// DecodeAddress decodes a P2PKH or P2SH address of the network with params
+// encoded with a Groestl-512 checksum.
+func DecodeAddress(addr string, params *chaincfg.Params) (btcutil.Address, error) {
+	hash, version, err := CheckDecode(addr)
+	if err != nil {
+		return nil, err
+	}
+	if len(hash) != 20 {
+		return nil, errors.New("decoded address is of unknown size")
+	}
+	switch version {
+	case params.PubKeyHashAddrID:
+		return btcutil.NewAddressPubKeyHash(hash, params)
+	case params.ScriptHashAddrID:
+		return btcutil.NewAddressScriptHashFromHash(hash, params)
+	}
+	return nil, fmt.Errorf("address is not intended for use on %v", params.Name)
+}
+
+// DecodeWIF decodes a private key of the network with params in the wallet
+// import format with a Groestl-512 checksum.
+func DecodeWIF(wif string, params *chaincfg.Params) (*btcutil.WIF, error) {
+	payload, version, err := CheckDecode(wif)
+	if err != nil {
+		return nil, err
+	}
+	if version != params.PrivateKeyID {
+		return nil, fmt.Errorf("private key is not intended for use on %v", params.Name)
+	}
+	compress := false
+	switch {
+	case len(payload) == btcec.PrivKeyBytesLen:
+	case len(payload) == btcec.PrivKeyBytesLen+1 && payload[btcec.PrivKeyBytesLen] == 0x01:
+		compress = true
+	default:
+		return nil, errors.New("malformed private key")
+	}
+	privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), payload[:btcec.PrivKeyBytesLen])
+	return btcutil.NewWIF(privKey, params, compress)
+}
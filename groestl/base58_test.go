@@ -0,0 +1,91 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package groestl
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/base58"
+)
+
+func TestCheckEncodeDecode(t *testing.T) {
+	payload := bytes.Repeat([]byte{0xab}, 20)
+	s := CheckEncode(payload, 0x24)
+	result, version, err := CheckDecode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 0x24 || !bytes.Equal(result, payload) {
+		t.Fatalf("%s decoded to version %#x and %x", s, version, result)
+	}
+
+	// Encodings with the double SHA256 checksum of Bitcoin are rejected.
+	_, _, err = CheckDecode(base58.CheckEncode(payload, 0x24))
+	if err == nil {
+		t.Fatal("Bitcoin checksum accepted")
+	}
+	_, _, err = CheckDecode("F")
+	if err == nil {
+		t.Fatal("truncated encoding decoded")
+	}
+}
+
+func TestAddress(t *testing.T) {
+	params := chaincfg.MainNetParams
+	params.PubKeyHashAddrID = 0x24
+	hash := bytes.Repeat([]byte{0x01}, 20)
+	pkh, err := btcutil.NewAddressPubKeyHash(hash, &params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh, err := btcutil.NewAddressScriptHashFromHash(hash, &params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range []btcutil.Address{pkh, sh} {
+		s, err := EncodeAddress(addr, &params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := DecodeAddress(s, &params)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if decoded.EncodeAddress() != addr.EncodeAddress() {
+			t.Errorf("%s decoded to %v, want %v", s, decoded, addr)
+		}
+	}
+
+	// Addresses of other networks are rejected.
+	_, err = DecodeAddress(CheckEncode(hash, 0x6f), &params)
+	if err == nil {
+		t.Error("testnet address decoded for mainnet")
+	}
+}
+
+func TestDecodeWIF(t *testing.T) {
+	key := bytes.Repeat([]byte{0x01}, 32)
+	for _, compress := range []bool{false, true} {
+		payload := key
+		if compress {
+			payload = append(payload[:32:32], 0x01)
+		}
+		wif, err := DecodeWIF(CheckEncode(payload, 0x80), &chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(wif.PrivKey.Serialize(), key) || wif.CompressPubKey != compress {
+			t.Errorf("compressed %v: decoded %x, compressed %v", compress,
+				wif.PrivKey.Serialize(), wif.CompressPubKey)
+		}
+	}
+	_, err := DecodeWIF(CheckEncode(key, 0xef), &chaincfg.MainNetParams)
+	if err == nil {
+		t.Error("testnet private key decoded for mainnet")
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package groestl implements the Groestl-512 hash function, as submitted to
+// the final round of the SHA-3 competition.  Groestlcoin uses it for block
+// hashes and the checksums of its base58 encodings.
+package groestl
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+const (
+	// Size is the size of a Groestl-512 hash in bytes.
+	Size = 64
+
+	// BlockSize is the block size of Groestl-512 in bytes.
+	BlockSize = 128
+
+	// rounds is the number of rounds of the P and Q permutations of the
+	// 1024-bit state.
+	rounds = 14
+
+	// columns is the number of 8 byte columns of the state.
+	columns = BlockSize / 8
+)
+
+// state is the 8x16 byte matrix permuted by P and Q.  Bytes are stored column
+// by column, so byte i is in row i%8 of column i/8, which is also the order
+// message bytes are mapped into the state.
+type state [BlockSize]byte
+
+// Row shifts of the ShiftBytes transformations of P and Q.
+var (
+	shiftP = [8]int{0, 1, 2, 3, 4, 5, 6, 11}
+	shiftQ = [8]int{1, 3, 5, 11, 0, 2, 4, 6}
+)
+
+// mixRow is the first row of the circulant MixBytes matrix.
+var mixRow = [8]byte{2, 2, 3, 4, 5, 3, 5, 7}
+
+// sbox is the AES S-box used by SubBytes.
+var sbox [256]byte
+
+func init() {
+	// The S-box maps each byte to the affine transformation of its
+	// multiplicative inverse in GF(2^8).
+	for i := 0; i < 256; i++ {
+		var inv byte
+		for j := 1; j < 256 && i != 0; j++ {
+			if mul(byte(i), byte(j)) == 1 {
+				inv = byte(j)
+				break
+			}
+		}
+		b := inv
+		sbox[i] = b ^ rotl(b, 1) ^ rotl(b, 2) ^ rotl(b, 3) ^ rotl(b, 4) ^ 0x63
+	}
+}
+
+func rotl(b byte, n uint) byte {
+	return b<<n | b>>(8-n)
+}
+
+// mul multiplies a and b in GF(2^8) with the AES polynomial
+// x^8 + x^4 + x^3 + x + 1.
+func mul(a, b byte) byte {
+	var p byte
+	for b != 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+// permute applies P to s when q is false and Q otherwise.
+func (s *state) permute(q bool) {
+	shift := &shiftP
+	if q {
+		shift = &shiftQ
+	}
+	var t state
+	for r := 0; r < rounds; r++ {
+		// AddRoundConstant
+		for c := 0; c < columns; c++ {
+			col := s[c*8 : c*8+8]
+			if q {
+				for i := 0; i < 7; i++ {
+					col[i] ^= 0xff
+				}
+				col[7] ^= 0xff ^ byte(c<<4) ^ byte(r)
+			} else {
+				col[0] ^= byte(c<<4) ^ byte(r)
+			}
+		}
+
+		// SubBytes and ShiftBytes
+		for c := 0; c < columns; c++ {
+			for i := 0; i < 8; i++ {
+				t[c*8+i] = sbox[s[((c+shift[i])%columns)*8+i]]
+			}
+		}
+
+		// MixBytes
+		for c := 0; c < columns; c++ {
+			col := t[c*8 : c*8+8]
+			for i := 0; i < 8; i++ {
+				var b byte
+				for k := 0; k < 8; k++ {
+					b ^= mul(mixRow[(k-i+8)%8], col[k])
+				}
+				s[c*8+i] = b
+			}
+		}
+	}
+}
+
+// digest is the hash.Hash computing Groestl-512.
+type digest struct {
+	h      state
+	buf    [BlockSize]byte
+	nbuf   int
+	blocks uint64
+}
+
+// New returns a new hash.Hash computing Groestl-512.
+func New() hash.Hash {
+	d := new(digest)
+	d.Reset()
+	return d
+}
+
+// Sum returns the Groestl-512 hash of data.
+func Sum(data []byte) [Size]byte {
+	d := new(digest)
+	d.Reset()
+	d.Write(data)
+	var sum [Size]byte
+	d.checkSum(sum[:0])
+	return sum
+}
+
+func (d *digest) Reset() {
+	d.h = state{}
+	// The initial value encodes the output size in bits.
+	binary.BigEndian.PutUint64(d.h[BlockSize-8:], Size*8)
+	d.nbuf = 0
+	d.blocks = 0
+}
+
+func (d *digest) Size() int { return Size }
+
+func (d *digest) BlockSize() int { return BlockSize }
+
+func (d *digest) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		c := copy(d.buf[d.nbuf:], p)
+		d.nbuf += c
+		p = p[c:]
+		if d.nbuf == BlockSize {
+			d.compress(d.buf[:])
+			d.nbuf = 0
+		}
+	}
+	return n, nil
+}
+
+func (d *digest) Sum(b []byte) []byte {
+	// Finish a copy so the caller can keep writing.
+	d0 := *d
+	return d0.checkSum(b)
+}
+
+// compress updates the chaining value with a message block.
+func (d *digest) compress(block []byte) {
+	var p, q state
+	for i := range p {
+		p[i] = d.h[i] ^ block[i]
+		q[i] = block[i]
+	}
+	p.permute(false)
+	q.permute(true)
+	for i := range d.h {
+		d.h[i] ^= p[i] ^ q[i]
+	}
+	d.blocks++
+}
+
+// checkSum pads the message, applies the output transformation and appends
+// the hash to b.
+func (d *digest) checkSum(b []byte) []byte {
+	// The message is padded with a one bit, zeros and the 64-bit number
+	// of blocks including the padding.
+	padBlocks := uint64(1)
+	if d.nbuf > BlockSize-9 {
+		padBlocks = 2
+	}
+	var pad [2 * BlockSize]byte
+	pad[0] = 0x80
+	padLen := int(padBlocks)*BlockSize - d.nbuf
+	binary.BigEndian.PutUint64(pad[padLen-8:padLen], d.blocks+padBlocks)
+	d.Write(pad[:padLen])
+
+	out := d.h
+	out.permute(false)
+	for i := range out {
+		out[i] ^= d.h[i]
+	}
+	return append(b, out[BlockSize-Size:]...)
+}
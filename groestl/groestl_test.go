@@ -0,0 +1,65 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package groestl
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{
+			in:   "",
+			want: "6d3ad29d279110eef3adbd66de2a0345a77baede1557f5d099fce0c03d6dc2ba8e6d4a6633dfbd66053c20faa87d1a11f39a7fbe4a6c2f009801370308fc4ad8",
+		},
+		{
+			in:   "The quick brown fox jumps over the lazy dog",
+			want: "badc1f70ccd69e0cf3760c3f93884289da84ec13c70b3d12a53a7a8a4a513f99715d46288f55e1dbf926e6d084a0538e4eebfc91cf2b21452921ccde9131718d",
+		},
+	}
+	for _, test := range tests {
+		sum := Sum([]byte(test.in))
+		if got := hex.EncodeToString(sum[:]); got != test.want {
+			t.Errorf("Sum(%q) is %s, want %s", test.in, got, test.want)
+		}
+		h := New()
+		h.Write([]byte(test.in))
+		if got := hex.EncodeToString(h.Sum(nil)); got != test.want {
+			t.Errorf("New().Sum(%q) is %s, want %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestWriteSplits(t *testing.T) {
+	// The lengths cross the padding into a second block and the block
+	// boundaries of the writes.
+	data := bytes.Repeat([]byte("groestl"), 3*BlockSize/7+1)
+	for _, n := range []int{BlockSize - 9, BlockSize - 8, BlockSize, BlockSize + 1, len(data)} {
+		want := Sum(data[:n])
+		h := New()
+		for i := 0; i < n; i += 13 {
+			end := i + 13
+			if end > n {
+				end = n
+			}
+			h.Write(data[i:end])
+			// Summing in between does not affect the hash.
+			h.Sum(nil)
+		}
+		if got := h.Sum(nil); !bytes.Equal(got, want[:]) {
+			t.Errorf("%d bytes: split writes hash to %x, want %x", n, got, want)
+		}
+		h.Reset()
+		h.Write(data[:n])
+		if got := h.Sum(nil); !bytes.Equal(got, want[:]) {
+			t.Errorf("%d bytes: hash after Reset is %x, want %x", n, got, want)
+		}
+	}
+}
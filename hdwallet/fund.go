@@ -0,0 +1,155 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package hdwallet
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+)
+
+// txVersion is the version of the transactions created by the wallet.
+const txVersion = 2
+
+// Worst case serialize sizes of the parts of a transaction spending P2PKH
+// outputs.
+const (
+	// txOverhead is the size of the version, the locktime and the compact
+	// int input and output counts of a transaction with fewer than 253
+	// inputs and outputs.
+	txOverhead = 4 + 4 + 1 + 1
+
+	// p2pkhInputSize is the worst case size of an input spending a
+	// compressed P2PKH output: the outpoint, the compact int length and
+	// the signature script with a 72 byte DER signature, and the sequence.
+	p2pkhInputSize = 32 + 4 + 1 + (1 + 73 + 1 + 33) + 4
+
+	// p2pkhOutputSize is the size of an output with a P2PKH script.
+	p2pkhOutputSize = 8 + 1 + 25
+
+	// p2pkhScriptSize is the size of a P2PKH output script.
+	p2pkhScriptSize = 25
+)
+
+// InsufficientFundsError is returned when the spendable outputs of the
+// wallet do not cover the amount of a transaction and its fee.
+type InsufficientFundsError struct {
+	Available btcutil.Amount
+	Required  btcutil.Amount
+}
+
+func (e *InsufficientFundsError) Error() string {
+	return fmt.Sprintf("hdwallet: insufficient funds: %v available, %v required",
+		e.Available, e.Required)
+}
+
+// Fund returns a signed transaction paying the outputs, funded by unspent
+// outputs of the wallet, and its fee.  The wallet is synced first.  Inputs
+// are selected largest first and change is paid to a new change address
+// unless it would be dust, in which case it is left to the fee.  Locked
+// outputs are never spent.
+//
+// The returned transaction is not published and its inputs are not locked;
+// callers that publish it later should lock them to prevent them from being
+// spent twice.
+func (w *Wallet) Fund(ctx context.Context, outputs []*wire.TxOut, feePerKb btcutil.Amount) (*wire.MsgTx, btcutil.Amount, error) {
+	err := w.Sync(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var spendable []*UnspentOutput
+	var available btcutil.Amount
+	for _, utxo := range w.utxos {
+		if w.IsLocked(&utxo.OutPoint) {
+			continue
+		}
+		spendable = append(spendable, utxo)
+		available += utxo.Value
+	}
+	sort.Slice(spendable, func(i, j int) bool {
+		return spendable[i].Value > spendable[j].Value
+	})
+
+	var amount btcutil.Amount
+	size := txOverhead
+	for _, out := range outputs {
+		amount += btcutil.Amount(out.Value)
+		size += out.SerializeSize()
+	}
+
+	var selected []*UnspentOutput
+	var total, fee, change btcutil.Amount
+	for _, utxo := range spendable {
+		selected = append(selected, utxo)
+		total += utxo.Value
+		size += p2pkhInputSize
+
+		fee = txrules.FeeForSerializeSize(feePerKb, size+p2pkhOutputSize)
+		if total < amount+fee {
+			continue
+		}
+		change = total - amount - fee
+		if txrules.IsDustAmount(change, p2pkhScriptSize, feePerKb) {
+			fee = total - amount
+			change = 0
+		}
+		break
+	}
+	if total < amount+fee || len(selected) == 0 {
+		return nil, 0, &InsufficientFundsError{
+			Available: available,
+			Required:  amount + txrules.FeeForSerializeSize(feePerKb, size),
+		}
+	}
+
+	tx := wire.NewMsgTx(txVersion)
+	for _, utxo := range selected {
+		tx.AddTxIn(wire.NewTxIn(&utxo.OutPoint, nil, nil))
+	}
+	for _, out := range outputs {
+		tx.AddTxOut(out)
+	}
+	if change != 0 {
+		changeAddr, err := w.ChangeAddress()
+		if err != nil {
+			return nil, 0, err
+		}
+		changeScript, err := txscript.PayToAddrScript(changeAddr)
+		if err != nil {
+			return nil, 0, err
+		}
+		tx.AddTxOut(wire.NewTxOut(int64(change), changeScript))
+	}
+
+	err = w.signInputs(tx, selected)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tx, fee, nil
+}
+
+// signInputs signs the inputs of tx, which spend the passed outputs of the
+// wallet in the same order.
+func (w *Wallet) signInputs(tx *wire.MsgTx, prevOuts []*UnspentOutput) error {
+	for i, prevOut := range prevOuts {
+		privKey, err := w.PrivKey(prevOut.Address)
+		if err != nil {
+			return err
+		}
+		sigScript, err := txscript.SignatureScript(tx, i, prevOut.PkScript,
+			txscript.SigHashAll, privKey, true)
+		if err != nil {
+			return err
+		}
+		tx.TxIn[i].SignatureScript = sigScript
+	}
+	return nil
+}
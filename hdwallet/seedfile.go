@@ -0,0 +1,157 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package hdwallet
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// seedFileVersion is the version of the wallet file format written by this
+// package.
+const seedFileVersion = 1
+
+// Default scrypt parameters used to derive the key encrypting the mnemonic.
+// They are recorded in the wallet file so they can be raised later without
+// breaking existing wallets.
+const (
+	defaultScryptN = 1 << 15
+	defaultScryptR = 8
+	defaultScryptP = 1
+)
+
+// ErrWrongPassword is returned when the wallet file can not be decrypted
+// with the passed password.
+var ErrWrongPassword = errors.New("hdwallet: wrong password")
+
+// scryptParams are the parameters of the key derivation function.
+type scryptParams struct {
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt []byte `json:"salt"`
+}
+
+// seedFile is the on disk representation of a wallet.  Only the mnemonic is
+// encrypted; the derivation state and locked outputs are not secret and are
+// updated without the password.
+type seedFile struct {
+	Version int    `json:"version"`
+	Network string `json:"network"`
+
+	Scrypt   scryptParams `json:"scrypt"`
+	Nonce    []byte       `json:"nonce"`
+	Mnemonic []byte       `json:"mnemonic"`
+
+	// NextExternal and NextInternal are the indexes of the next unused
+	// receiving and change addresses.
+	NextExternal uint32 `json:"nextexternal"`
+	NextInternal uint32 `json:"nextinternal"`
+
+	// Locked are the outpoints, in txid:index form, that must not be
+	// spent when funding transactions.
+	Locked []string `json:"locked,omitempty"`
+}
+
+// secretKey derives the key encrypting the mnemonic from the password.
+func (p *scryptParams) secretKey(password []byte) (*[32]byte, error) {
+	b, err := scrypt.Key(password, p.Salt, p.N, p.R, p.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	var key [32]byte
+	copy(key[:], b)
+	return &key, nil
+}
+
+// encryptMnemonic encrypts the mnemonic with a key derived from password
+// using fresh scrypt parameters and nonce.
+func (f *seedFile) encryptMnemonic(mnemonic string, password []byte) error {
+	f.Scrypt = scryptParams{
+		N:    defaultScryptN,
+		R:    defaultScryptR,
+		P:    defaultScryptP,
+		Salt: make([]byte, 32),
+	}
+	_, err := rand.Read(f.Scrypt.Salt)
+	if err != nil {
+		return err
+	}
+	key, err := f.Scrypt.secretKey(password)
+	if err != nil {
+		return err
+	}
+	var nonce [24]byte
+	_, err = rand.Read(nonce[:])
+	if err != nil {
+		return err
+	}
+	f.Nonce = nonce[:]
+	f.Mnemonic = secretbox.Seal(nil, []byte(mnemonic), &nonce, key)
+	return nil
+}
+
+// decryptMnemonic returns the mnemonic of the wallet, or ErrWrongPassword
+// when it can not be decrypted with password.
+func (f *seedFile) decryptMnemonic(password []byte) (string, error) {
+	if len(f.Nonce) != 24 {
+		return "", errors.New("hdwallet: invalid nonce in wallet file")
+	}
+	key, err := f.Scrypt.secretKey(password)
+	if err != nil {
+		return "", err
+	}
+	var nonce [24]byte
+	copy(nonce[:], f.Nonce)
+	mnemonic, ok := secretbox.Open(nil, f.Mnemonic, &nonce, key)
+	if !ok {
+		return "", ErrWrongPassword
+	}
+	return string(mnemonic), nil
+}
+
+// readSeedFile reads and decodes the wallet file at path.
+func readSeedFile(path string) (*seedFile, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f seedFile
+	err = json.Unmarshal(b, &f)
+	if err != nil {
+		return nil, fmt.Errorf("hdwallet: failed to decode %s: %v", path, err)
+	}
+	if f.Version != seedFileVersion {
+		return nil, fmt.Errorf("hdwallet: unsupported wallet file version %d", f.Version)
+	}
+	return &f, nil
+}
+
+// write atomically replaces the wallet file at path with f.  The file is
+// only readable by the owner.
+func (f *seedFile) write(path string) error {
+	b, err := json.MarshalIndent(f, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, b, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,424 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package hdwallet implements a hierarchical deterministic wallet that is
+// embedded in the atomic swap tools, so swaps can be performed without an
+// external wallet daemon and with keys kept apart from the main wallet.
+//
+// The wallet is derived from a BIP 39 mnemonic following BIP 44 with a
+// single account: m/44'/<coin type>'/0'/<branch>/<index>, where branch 0
+// holds the receiving addresses and branch 1 the change addresses.  Only
+// P2PKH addresses are used, as required by the swap contracts.  The mnemonic
+// is stored in a wallet file encrypted with a key derived from a password
+// using scrypt.
+//
+// The wallet does not keep a copy of the chain.  Its unspent outputs are
+// discovered through a Chain backend, such as an Electrum server, by
+// scanning the addresses of both branches up to the gap limit.
+package hdwallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/robvanmieghem/electrumatomicswap/electrumx"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// DefaultGapLimit is the number of consecutive unused addresses after which
+// address discovery stops.  It matches the limit used by most wallets.
+const DefaultGapLimit = 20
+
+// mnemonicEntropyBits is the entropy of generated mnemonics, resulting in 24
+// words.
+const mnemonicEntropyBits = 256
+
+// Branches of the account.
+const (
+	externalBranch = 0
+	internalBranch = 1
+)
+
+// ErrUnknownAddress is returned when a key is requested for an address that
+// does not belong to the wallet.
+var ErrUnknownAddress = errors.New("hdwallet: address does not belong to the wallet")
+
+// Chain is the backend the wallet uses to discover its transactions and
+// unspent outputs and to publish transactions.  It is implemented by
+// *electrumx.Client.
+type Chain interface {
+	GetHistory(ctx context.Context, scriptHash string) ([]*electrumx.HistoryTx, error)
+	ListUnspent(ctx context.Context, scriptHash string) ([]*electrumx.UnspentOutput, error)
+	GetTransaction(ctx context.Context, txHash *chainhash.Hash) (*wire.MsgTx, error)
+	Broadcast(ctx context.Context, tx *wire.MsgTx) (*chainhash.Hash, error)
+	EstimateFee(ctx context.Context, blocks int) (int64, error)
+}
+
+// keyPath locates a key in the account.
+type keyPath struct {
+	branch uint32
+	index  uint32
+}
+
+// UnspentOutput is an unspent output paying to an address of the wallet.
+// Height is zero for unconfirmed outputs.
+type UnspentOutput struct {
+	OutPoint wire.OutPoint
+	Value    btcutil.Amount
+	PkScript []byte
+	Address  btcutil.Address
+	Height   int32
+}
+
+// Wallet is an open HD wallet.  A Wallet must not be used concurrently.
+type Wallet struct {
+	path   string
+	params *chaincfg.Params
+	chain  Chain
+	file   *seedFile
+
+	// GapLimit is the number of consecutive unused addresses scanned
+	// past the last used address of a branch.
+	GapLimit uint32
+
+	// branches are the extended private keys of the receiving and change
+	// branches of the account.
+	branches [2]*hdkeychain.ExtendedKey
+
+	// addrs maps the encoded addresses derived so far to their key path.
+	addrs map[string]keyPath
+
+	utxos []*UnspentOutput
+}
+
+// NewMnemonic returns a new random 24 word BIP 39 mnemonic.
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// Create writes a new wallet file at path for the passed mnemonic, encrypted
+// with password.  An existing file is never overwritten.
+func Create(path, mnemonic string, password []byte, params *chaincfg.Params) error {
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return errors.New("hdwallet: invalid mnemonic")
+	}
+	if len(password) == 0 {
+		return errors.New("hdwallet: empty password")
+	}
+	_, err := os.Stat(path)
+	if err == nil {
+		return fmt.Errorf("hdwallet: %s already exists", path)
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	f := &seedFile{
+		Version: seedFileVersion,
+		Network: params.Name,
+	}
+	err = f.encryptMnemonic(mnemonic, password)
+	if err != nil {
+		return err
+	}
+	return f.write(path)
+}
+
+// Open decrypts the wallet file at path and returns the wallet.  The chain
+// backend is used by the methods querying the chain.
+func Open(path string, password []byte, params *chaincfg.Params, chain Chain) (*Wallet, error) {
+	f, err := readSeedFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if f.Network != params.Name {
+		return nil, fmt.Errorf("hdwallet: %s is a %s wallet, not %s",
+			path, f.Network, params.Name)
+	}
+	mnemonic, err := f.decryptMnemonic(password)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, fmt.Errorf("hdwallet: %v", err)
+	}
+
+	master, err := hdkeychain.NewMaster(seed, params)
+	if err != nil {
+		return nil, err
+	}
+	defer master.Zero()
+	account, err := derivePath(master, hdkeychain.HardenedKeyStart+44,
+		hdkeychain.HardenedKeyStart+params.HDCoinType, hdkeychain.HardenedKeyStart)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Wallet{
+		path:     path,
+		params:   params,
+		chain:    chain,
+		file:     f,
+		GapLimit: DefaultGapLimit,
+		addrs:    make(map[string]keyPath),
+	}
+	for branch := range w.branches {
+		w.branches[branch], err = account.Derive(uint32(branch))
+		if err != nil {
+			return nil, err
+		}
+	}
+	account.Zero()
+
+	// Derive the addresses handed out so far, and the lookahead past
+	// them, so their keys can be found.
+	err = w.deriveUpTo(externalBranch, f.NextExternal+w.GapLimit)
+	if err != nil {
+		return nil, err
+	}
+	err = w.deriveUpTo(internalBranch, f.NextInternal+w.GapLimit)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// derivePath derives the descendant of k at the child indexes of path, with
+// hardened indexes offset by hdkeychain.HardenedKeyStart.
+func derivePath(k *hdkeychain.ExtendedKey, path ...uint32) (*hdkeychain.ExtendedKey, error) {
+	for _, i := range path {
+		var err error
+		k, err = k.Derive(i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// Close removes the keys of the wallet from memory.
+func (w *Wallet) Close() {
+	for _, k := range w.branches {
+		if k != nil {
+			k.Zero()
+		}
+	}
+}
+
+// Params returns the network parameters of the wallet.
+func (w *Wallet) Params() *chaincfg.Params {
+	return w.params
+}
+
+// key returns the extended private key at the key path.
+func (w *Wallet) key(p keyPath) (*hdkeychain.ExtendedKey, error) {
+	return w.branches[p.branch].Derive(p.index)
+}
+
+// address derives the P2PKH address at the key path and records it.
+func (w *Wallet) address(p keyPath) (*btcutil.AddressPubKeyHash, error) {
+	k, err := w.key(p)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := k.Address(w.params)
+	if err != nil {
+		return nil, err
+	}
+	w.addrs[addr.EncodeAddress()] = p
+	return addr, nil
+}
+
+// deriveUpTo derives the addresses of the branch with an index below end.
+func (w *Wallet) deriveUpTo(branch, end uint32) error {
+	for i := uint32(0); i < end; i++ {
+		_, err := w.address(keyPath{branch, i})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextAddress returns the next unused address of the branch and records it
+// as used in the wallet file.
+func (w *Wallet) nextAddress(branch uint32) (*btcutil.AddressPubKeyHash, error) {
+	next := &w.file.NextExternal
+	if branch == internalBranch {
+		next = &w.file.NextInternal
+	}
+	addr, err := w.address(keyPath{branch, *next})
+	if err != nil {
+		return nil, err
+	}
+	*next++
+	_, err = w.address(keyPath{branch, *next + w.GapLimit - 1})
+	if err != nil {
+		return nil, err
+	}
+	return addr, w.file.write(w.path)
+}
+
+// NewAddress returns a new receiving address.
+func (w *Wallet) NewAddress() (*btcutil.AddressPubKeyHash, error) {
+	return w.nextAddress(externalBranch)
+}
+
+// ChangeAddress returns a new change address.
+func (w *Wallet) ChangeAddress() (*btcutil.AddressPubKeyHash, error) {
+	return w.nextAddress(internalBranch)
+}
+
+// PrivKey returns the private key of an address of the wallet, or
+// ErrUnknownAddress when the address is not one of the addresses derived by
+// the wallet.
+func (w *Wallet) PrivKey(addr btcutil.Address) (*btcec.PrivateKey, error) {
+	p, ok := w.addrs[addr.EncodeAddress()]
+	if !ok {
+		return nil, ErrUnknownAddress
+	}
+	k, err := w.key(p)
+	if err != nil {
+		return nil, err
+	}
+	return k.ECPrivKey()
+}
+
+// Sync discovers the used addresses of the wallet and its unspent outputs.
+// Each branch is scanned from its first address until GapLimit consecutive
+// addresses have no history.  The indexes of the next addresses handed out
+// are moved past the used addresses, which matters when restoring a wallet.
+func (w *Wallet) Sync(ctx context.Context) error {
+	var utxos []*UnspentOutput
+	for _, branch := range []uint32{externalBranch, internalBranch} {
+		next := &w.file.NextExternal
+		if branch == internalBranch {
+			next = &w.file.NextInternal
+		}
+
+		end := *next + w.GapLimit
+		for i := uint32(0); i < end; i++ {
+			addr, err := w.address(keyPath{branch, i})
+			if err != nil {
+				return err
+			}
+			pkScript, err := txscript.PayToAddrScript(addr)
+			if err != nil {
+				return err
+			}
+			scriptHash := electrumx.ScriptHash(pkScript)
+			history, err := w.chain.GetHistory(ctx, scriptHash)
+			if err != nil {
+				return err
+			}
+			if len(history) == 0 {
+				continue
+			}
+			if i >= *next {
+				*next = i + 1
+				end = *next + w.GapLimit
+			}
+
+			unspent, err := w.chain.ListUnspent(ctx, scriptHash)
+			if err != nil {
+				return err
+			}
+			for _, u := range unspent {
+				utxos = append(utxos, &UnspentOutput{
+					OutPoint: *u.OutPoint,
+					Value:    btcutil.Amount(u.Value),
+					PkScript: pkScript,
+					Address:  addr,
+					Height:   u.Height,
+				})
+			}
+		}
+	}
+	w.utxos = utxos
+	return w.file.write(w.path)
+}
+
+// ListUnspent returns the unspent outputs found by the last Sync, including
+// locked outputs.
+func (w *Wallet) ListUnspent() []*UnspentOutput {
+	utxos := make([]*UnspentOutput, len(w.utxos))
+	copy(utxos, w.utxos)
+	return utxos
+}
+
+// Balance returns the total value of the unspent outputs found by the last
+// Sync, excluding locked outputs.
+func (w *Wallet) Balance() btcutil.Amount {
+	var balance btcutil.Amount
+	for _, utxo := range w.utxos {
+		if !w.IsLocked(&utxo.OutPoint) {
+			balance += utxo.Value
+		}
+	}
+	return balance
+}
+
+// IsLocked reports whether the outpoint is locked.
+func (w *Wallet) IsLocked(outPoint *wire.OutPoint) bool {
+	op := outPoint.String()
+	for _, locked := range w.file.Locked {
+		if locked == op {
+			return true
+		}
+	}
+	return false
+}
+
+// LockOutPoint excludes the outpoint from funding new transactions until it
+// is unlocked.  Locks are kept in the wallet file.
+func (w *Wallet) LockOutPoint(outPoint *wire.OutPoint) error {
+	if w.IsLocked(outPoint) {
+		return nil
+	}
+	w.file.Locked = append(w.file.Locked, outPoint.String())
+	return w.file.write(w.path)
+}
+
+// UnlockOutPoint makes a locked outpoint available for funding again.
+func (w *Wallet) UnlockOutPoint(outPoint *wire.OutPoint) error {
+	op := outPoint.String()
+	for i, locked := range w.file.Locked {
+		if locked == op {
+			w.file.Locked = append(w.file.Locked[:i], w.file.Locked[i+1:]...)
+			return w.file.write(w.path)
+		}
+	}
+	return nil
+}
+
+// FeeRate returns the fee rate estimated by the chain backend for
+// confirmation within the passed number of blocks.
+func (w *Wallet) FeeRate(ctx context.Context, blocks int) (btcutil.Amount, error) {
+	feePerKb, err := w.chain.EstimateFee(ctx, blocks)
+	return btcutil.Amount(feePerKb), err
+}
+
+// Broadcast publishes the transaction.
+func (w *Wallet) Broadcast(ctx context.Context, tx *wire.MsgTx) (*chainhash.Hash, error) {
+	return w.chain.Broadcast(ctx, tx)
+}
+
+// GetTransaction looks up a transaction on the chain.
+func (w *Wallet) GetTransaction(ctx context.Context, txHash *chainhash.Hash) (*wire.MsgTx, error) {
+	return w.chain.GetTransaction(ctx, txHash)
+}
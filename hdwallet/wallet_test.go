@@ -0,0 +1,202 @@
+// Copyright (c) 2017 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package hdwallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// testMnemonic is the mnemonic of the BIP 39 test vectors with all zero
+// entropy.
+const testMnemonic = "abandon abandon abandon abandon abandon abandon " +
+	"abandon abandon abandon abandon abandon about"
+
+var testPassword = []byte("password")
+
+// TestDerivePath checks the derivation against test vector 1 of BIP 32.
+func TestDerivePath(t *testing.T) {
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if master.String() != "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi" {
+		t.Fatalf("master key is %v", master)
+	}
+
+	const h = hdkeychain.HardenedKeyStart
+	tests := []struct {
+		path []uint32
+		xprv string
+		xpub string
+	}{
+		{
+			path: []uint32{h},
+			xprv: "xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7",
+			xpub: "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw",
+		},
+		{
+			path: []uint32{h, 1},
+			xprv: "xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs",
+			xpub: "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ",
+		},
+		{
+			path: []uint32{h, 1, h + 2},
+			xprv: "xprv9z4pot5VBttmtdRTWfWQmoH1taj2axGVzFqSb8C9xaxKymcFzXBDptWmT7FwuEzG3ryjH4ktypQSAewRiNMjANTtpgP4mLTj34bhnZX7UiM",
+			xpub: "xpub6D4BDPcP2GT577Vvch3R8wDkScZWzQzMMUm3PWbmWvVJrZwQY4VUNgqFJPMM3No2dFDFGTsxxpG5uJh7n7epu4trkrX7x7DogT5Uv6fcLW5",
+		},
+		{
+			path: []uint32{h, 1, h + 2, 2},
+			xprv: "xprvA2JDeKCSNNZky6uBCviVfJSKyQ1mDYahRjijr5idH2WwLsEd4Hsb2Tyh8RfQMuPh7f7RtyzTtdrbdqqsunu5Mm3wDvUAKRHSC34sJ7in334",
+			xpub: "xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV",
+		},
+		{
+			path: []uint32{h, 1, h + 2, 2, 1000000000},
+			xprv: "xprvA41z7zogVVwxVSgdKUHDy1SKmdb533PjDz7J6N6mV6uS3ze1ai8FHa8kmHScGpWmj4WggLyQjgPie1rFSruoUihUZREPSL39UNdE3BBDu76",
+			xpub: "xpub6H1LXWLaKsWFhvm6RVpEL9P4KfRZSW7abD2ttkWP3SSQvnyA8FSVqNTEcYFgJS2UaFcxupHiYkro49S8yGasTvXEYBVPamhGW6cFJodrTHy",
+		},
+	}
+	for _, test := range tests {
+		k, err := derivePath(master, test.path...)
+		if err != nil {
+			t.Errorf("%v: %v", test.path, err)
+			continue
+		}
+		if k.String() != test.xprv {
+			t.Errorf("%v: private key is %v, want %v", test.path, k, test.xprv)
+		}
+		pub, err := k.Neuter()
+		if err != nil {
+			t.Errorf("%v: %v", test.path, err)
+			continue
+		}
+		if pub.String() != test.xpub {
+			t.Errorf("%v: public key is %v, want %v", test.path, pub, test.xpub)
+		}
+	}
+}
+
+// openTestWallet creates and opens a wallet for the test mnemonic.
+func openTestWallet(t *testing.T, params *chaincfg.Params) *Wallet {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	err := Create(path, testMnemonic, testPassword, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := Open(path, testPassword, params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(w.Close)
+	return w
+}
+
+// TestBIP44Addresses checks the addresses handed out against the BIP 44
+// derivation m/44'/<coin type>'/0'/<branch>/<index> of the test mnemonic.
+func TestBIP44Addresses(t *testing.T) {
+	tests := []struct {
+		params   *chaincfg.Params
+		external []string
+		internal []string
+	}{
+		{
+			params:   &chaincfg.MainNetParams,
+			external: []string{"1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA", "1Ak8PffB2meyfYnbXZR9EGfLfFZVpzJvQP"},
+			internal: []string{"1J3J6EvPrv8q6AC3VCjWV45Uf3nssNMRtH", "13vKxXzHXXd8HquAYdpkJoi9ULVXUgfpS5"},
+		},
+		{
+			params:   &chaincfg.TestNet3Params,
+			external: []string{"mkpZhYtJu2r87Js3pDiWJDmPte2NRZ8bJV", "mzpbWabUQm1w8ijuJnAof5eiSTep27deVH"},
+			internal: []string{"mi8nhzZgGZQthq6DQHbru9crMDerUdTKva", "mz9HfS6y833A8HP8bfpLikzCbjonJXaAGW"},
+		},
+	}
+	for _, test := range tests {
+		w := openTestWallet(t, test.params)
+		for i, want := range test.external {
+			addr, err := w.NewAddress()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if addr.EncodeAddress() != want {
+				t.Errorf("%s: receiving address %d is %v, want %v", test.params.Name, i, addr, want)
+			}
+		}
+		for i, want := range test.internal {
+			addr, err := w.ChangeAddress()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if addr.EncodeAddress() != want {
+				t.Errorf("%s: change address %d is %v, want %v", test.params.Name, i, addr, want)
+			}
+		}
+	}
+}
+
+func TestPrivKey(t *testing.T) {
+	w := openTestWallet(t, &chaincfg.MainNetParams)
+	addr, err := w.NewAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	privKey, err := w.PrivKey(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyAddr, err := btcutil.NewAddressPubKeyHash(
+		btcutil.Hash160(privKey.PubKey().SerializeCompressed()), w.Params())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyAddr.EncodeAddress() != addr.EncodeAddress() {
+		t.Errorf("key of %v is the key of %v", addr, keyAddr)
+	}
+
+	// Addresses not derived by the wallet have no key.
+	other, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherAddr, err := btcutil.NewAddressPubKeyHash(
+		btcutil.Hash160(other.PubKey().SerializeCompressed()), w.Params())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.PrivKey(otherAddr)
+	if !errors.Is(err, ErrUnknownAddress) {
+		t.Errorf("key of an unknown address: error is %v", err)
+	}
+}
+
+func TestOpenWrongPassword(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	err := Create(path, testMnemonic, testPassword, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Open(path, []byte("wrong"), &chaincfg.MainNetParams, nil)
+	if !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("wrong password: error is %v", err)
+	}
+	_, err = Open(path, testPassword, &chaincfg.TestNet3Params, nil)
+	if err == nil {
+		t.Error("mainnet wallet opened on testnet")
+	}
+	err = Create(path, testMnemonic, testPassword, &chaincfg.MainNetParams)
+	if err == nil {
+		t.Error("existing wallet was overwritten")
+	}
+}